@@ -3,8 +3,15 @@ module github.com/michal-franc/cir
 go 1.13
 
 require (
+	github.com/aws/aws-sdk-go-v2 v1.3.0
 	github.com/aws/aws-sdk-go-v2/config v1.1.3
 	github.com/aws/aws-sdk-go-v2/service/ec2 v1.2.0
+	github.com/aws/aws-sdk-go-v2/service/ecs v1.2.0
+	github.com/aws/aws-sdk-go-v2/service/elasticache v1.1.1
+	github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing v1.1.1
+	github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2 v1.2.0
+	github.com/aws/aws-sdk-go-v2/service/lambda v1.2.0
+	github.com/aws/aws-sdk-go-v2/service/rds v1.2.0
 	github.com/liamg/tml v0.4.0
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/sirupsen/logrus v1.8.1
@@ -12,5 +19,5 @@ require (
 	github.com/spf13/viper v1.7.1
 	github.com/stretchr/objx v0.3.0 // indirect
 	github.com/stretchr/testify v1.7.0
-	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
@@ -0,0 +1,315 @@
+package analyser
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"net"
+)
+
+// allPorts - every tcp and udp port
+var allPorts = portRange{0, 65535}
+
+// allPortsProtocols - protocols with ports, icmp has types and codes instead so it is checked with --protocol icmp
+var allPortsProtocols = []Protocol{ProtocolTCP, ProtocolUDP}
+
+// PortRange - ports of the protocol, single port if From and To are equal
+type PortRange struct {
+	Protocol Protocol
+	From     int32
+	To       int32
+}
+
+func (r PortRange) String() string {
+	if r.From == r.To {
+		return fmt.Sprintf("%s %d", r.Protocol, r.From)
+	}
+	return fmt.Sprintf("%s %d-%d", r.Protocol, r.From, r.To)
+}
+
+func toPortRanges(protocol Protocol, set portSet) []PortRange {
+	ranges := []PortRange{}
+	for _, r := range set {
+		ranges = append(ranges, PortRange{protocol, r.From, r.To})
+	}
+	return ranges
+}
+
+// AllowedPorts - tcp and udp ports which can flow from source interface to destination interface
+type AllowedPorts struct {
+	SourceID               string
+	SourceInterfaceID      string
+	SourceIP               string
+	DestinationID          string
+	DestinationInterfaceID string
+	DestinationIP          string
+	AddressFamily          AddressFamily
+	Reachable              []PortRange
+	// ports which couldnt be evaluated eg. rule pointing at prefix list which wasnt loaded
+	Unknown []PortRange
+	// full analysis of every protocol with any port allowed by security groups and network acls
+	// checked on its first port, checks not depending on the port eg. route block or make unknown the whole protocol
+	Paths []Analysis
+}
+
+// Verdict - reachable if any port can flow, unknown if some ports couldnt be evaluated and none can flow
+func (p *AllowedPorts) Verdict() Verdict {
+	if len(p.Reachable) > 0 {
+		return VerdictReachable
+	}
+	if len(p.Unknown) > 0 {
+		return VerdictUnknown
+	}
+	return VerdictUnreachable
+}
+
+// BlockingChecks - checks not depending on the port which didnt pass, each reason listed once
+func (p *AllowedPorts) BlockingChecks() []*Check {
+	checks := []*Check{}
+	seen := map[string]bool{}
+	for _, path := range p.Paths {
+		for _, check := range path.pathChecks() {
+			if check.IsPassing() || seen[check.Reason] {
+				continue
+			}
+			seen[check.Reason] = true
+			checks = append(checks, check)
+		}
+	}
+	return checks
+}
+
+// pathChecks - checks of the analysis which dont depend on the port, return traffic goes to ephemeral ports of the source
+// nat gateway subnet network acl depends on the port so it is one of the port checks
+func (a *Analysis) pathChecks() []*Check {
+	return []*Check{
+		a.SourceSubnetHasRoute,
+		a.DestinationSubnetHasRoute,
+		a.ConnectionBetweenVPCsIsValid,
+		a.ConnectionBetweenVPCsIsActive,
+		a.TransitGatewayRouteToDestination,
+		a.TransitGatewayRouteToSource,
+		a.DestinationNaclAllowsReturn,
+		a.SourceNaclAllowsReturn,
+		a.NatGatewayIsAvailable,
+		a.InternetGatewayIsReachable,
+	}
+}
+
+// RunAllPortsAnalysis - finds tcp and udp port ranges which can flow between every source and destination interface
+// port ranges allowed by security groups and network acls both ways are intersected and the path is checked once per protocol
+func RunAllPortsAnalysis(data scanner.AwsData, client scanner.EC2API) ([]AllowedPorts, error) {
+	listOfAllowedPorts := []AllowedPorts{}
+	for _, source := range interfaceMembers(data.Sources) {
+		for _, destination := range interfaceMembers(data.Destinations) {
+			for _, pair := range interfacePairs(source, destination, client) {
+				listOfAllowedPorts = append(listOfAllowedPorts, analyseAllPorts(pair[0], pair[1], client))
+			}
+		}
+	}
+	return listOfAllowedPorts, nil
+}
+
+func analyseAllPorts(source interfaceMember, destination interfaceMember, client scanner.EC2API) AllowedPorts {
+	allowedPorts := AllowedPorts{
+		SourceID:               source.Resource.ID,
+		SourceInterfaceID:      source.Interface.ID,
+		SourceIP:               source.Interface.PrivateIP,
+		DestinationID:          destination.Resource.ID,
+		DestinationInterfaceID: destination.Interface.ID,
+		DestinationIP:          destination.Interface.PrivateIP,
+		AddressFamily:          source.Family,
+		Reachable:              []PortRange{},
+		Unknown:                []PortRange{},
+		Paths:                  []Analysis{},
+	}
+
+	checks, crossedEntries := portChecks(source, destination, client)
+	for _, protocol := range allPortsProtocols {
+		reachable, possible := evaluatePortChecks(checks, protocol, portBoundaries(source.Interface, destination.Interface, crossedEntries, protocol))
+		if len(possible) == 0 {
+			continue
+		}
+
+		path := analyseInterfaces(source.Resource, source.Interface, destination.Resource, destination.Interface, client, NewPortTraffic(protocol, possible[0].From))
+		allowedPorts.Paths = append(allowedPorts.Paths, *path)
+		switch CombineStatus(path.pathChecks()...) {
+		case CheckFail:
+			continue
+		case CheckUnknown:
+			allowedPorts.Unknown = append(allowedPorts.Unknown, toPortRanges(protocol, possible)...)
+			continue
+		}
+		allowedPorts.Reachable = append(allowedPorts.Reachable, toPortRanges(protocol, reachable)...)
+		allowedPorts.Unknown = append(allowedPorts.Unknown, toPortRanges(protocol, possible.subtract(reachable))...)
+	}
+	return allowedPorts
+}
+
+// portCheck - check which result depends on the port
+type portCheck func(traffic Traffic) *Check
+
+// portChecks - security groups and network acls crossed by the traffic, the same as checked by analyseInterfaces
+// internet side has no security groups or network acls, entries of network acls crossed on the way eg. nat gateway subnet are returned to split the ports
+func portChecks(source interfaceMember, destination interfaceMember, client scanner.EC2API) ([]portCheck, []types.NetworkAclEntry) {
+	ipSource := net.ParseIP(source.Interface.PrivateIP)
+	ipDestination := net.ParseIP(destination.Interface.PrivateIP)
+	noPeer := peerGroups{IDs: []string{}}
+	checks := []portCheck{}
+
+	switch {
+	case destination.Interface.Internet:
+		checks = append(checks, func(traffic Traffic) *Check {
+			return orUnknown(checkIfSecurityGroupsAllowEgress(source.Interface, noPeer, traffic, ipDestination))
+		})
+	case source.Interface.Internet:
+		checks = append(checks, func(traffic Traffic) *Check {
+			return orUnknown(checkIfSecurityGroupsAllowIngress(destination.Interface, noPeer, traffic, ipSource))
+		})
+	default:
+		reason := referencesNotSupportedReason(source, destination, client)
+		checks = append(checks, func(traffic Traffic) *Check {
+			return orUnknown(checkIfSecurityGroupsAllowEgress(source.Interface, peerGroups{IDs: destination.Interface.SecurityGroupIDs(), ReferencesNotSupportedReason: reason}, traffic, ipDestination))
+		}, func(traffic Traffic) *Check {
+			return orUnknown(checkIfSecurityGroupsAllowIngress(destination.Interface, peerGroups{IDs: source.Interface.SecurityGroupIDs(), ReferencesNotSupportedReason: reason}, traffic, ipSource))
+		})
+	}
+
+	crossedEntries := []types.NetworkAclEntry{}
+	if natCheck, entries, ok := natSubnetPortCheck(source, destination, client); ok {
+		checks = append(checks, natCheck)
+		crossedEntries = entries
+	}
+
+	// network acls are applied only when traffic crosses the subnet boundary
+	if source.Interface.SubnetID == destination.Interface.SubnetID {
+		return checks, crossedEntries
+	}
+	if !source.Interface.Internet {
+		checks = append(checks, func(traffic Traffic) *Check {
+			return orUnknown(checkIfNetworkAclAllowsTraffic(source.Interface.NetworkAcl, true, ipDestination, traffic))
+		})
+	}
+	if !destination.Interface.Internet {
+		checks = append(checks, func(traffic Traffic) *Check {
+			return orUnknown(checkIfNetworkAclAllowsTraffic(destination.Interface.NetworkAcl, false, ipSource, traffic))
+		})
+	}
+	return checks, crossedEntries
+}
+
+// natSubnetPortCheck - network acl of the subnet of nat gateway the source reaches the internet through, with its entries
+// false if the traffic doesnt cross subnet of nat gateway, missing nat gateway is reported by the path analysis
+func natSubnetPortCheck(source interfaceMember, destination interfaceMember, client scanner.EC2API) (portCheck, []types.NetworkAclEntry, bool) {
+	if !destination.Interface.Internet {
+		return nil, nil, false
+	}
+	ipSource := net.ParseIP(source.Interface.PrivateIP)
+	ipDestination := net.ParseIP(destination.Interface.PrivateIP)
+	_, route, err := lookForRouteOutsideSubnet(source.Interface, ipDestination)
+	if err != nil || route.NatGatewayId == nil {
+		return nil, nil, false
+	}
+
+	natGateway, err := getNatGateway(client, *route.NatGatewayId)
+	if err == nil && natGateway == nil {
+		return nil, nil, false
+	}
+	var networkAcl types.NetworkAcl
+	crossed := true
+	if err == nil {
+		networkAcl, crossed, err = natSubnetNetworkAcl(client, natGateway, source.Interface)
+	}
+	if err != nil {
+		return func(traffic Traffic) *Check { return unknownFromError(err) }, nil, true
+	}
+	if !crossed {
+		return nil, nil, false
+	}
+	return func(traffic Traffic) *Check {
+		return orUnknown(checkNatSubnetTraffic(natGateway, networkAcl, traffic, ipSource, ipDestination))
+	}, networkAcl.Entries, true
+}
+
+// referencesNotSupportedReason - rules pointing at the other side security groups work only in the same vpc or over peering in the same region
+// peering which cant be read is treated as missing, the path analysis reports the error
+func referencesNotSupportedReason(source interfaceMember, destination interfaceMember, client scanner.EC2API) string {
+	if source.Resource.VpcID == destination.Resource.VpcID {
+		return ""
+	}
+
+	var peering *types.VpcPeeringConnection
+	_, route, err := lookForRouteOutsideSubnet(source.Interface, net.ParseIP(destination.Interface.PrivateIP))
+	if err == nil && route.VpcPeeringConnectionId != nil {
+		peering, _ = getVpcPeeringConnection(client, *route.VpcPeeringConnectionId)
+	}
+	return groupReferencesNotSupportedReason(false, peering)
+}
+
+// portBoundaries - port ranges of the rules which can split the traffic, rules for all protocols cover every port
+func portBoundaries(source scanner.NetworkInterface, destination scanner.NetworkInterface, crossedEntries []types.NetworkAclEntry, protocol Protocol) []portRange {
+	boundaries := []portRange{}
+	permissions := []types.IpPermission{}
+	for _, securityGroup := range source.SecurityGroups {
+		permissions = append(permissions, securityGroup.IpPermissionsEgress...)
+	}
+	for _, securityGroup := range destination.SecurityGroups {
+		permissions = append(permissions, securityGroup.IpPermissions...)
+	}
+	for _, permission := range permissions {
+		if permission.IpProtocol != nil && *permission.IpProtocol != protocolAll && protocol.matches(*permission.IpProtocol) {
+			boundaries = append(boundaries, portRange{permission.FromPort, permission.ToPort})
+		}
+	}
+
+	entries := append(sortedNetworkAclEntries(source.NetworkAcl, true), sortedNetworkAclEntries(destination.NetworkAcl, false)...)
+	entries = append(entries, crossedEntries...)
+	for _, entry := range entries {
+		if entry.Protocol != nil && *entry.Protocol != protocolAll && protocol.matches(*entry.Protocol) && entry.PortRange != nil {
+			boundaries = append(boundaries, portRange{entry.PortRange.From, entry.PortRange.To})
+		}
+	}
+	return boundaries
+}
+
+// evaluatePortChecks - every rule covers the whole piece between the boundaries or none of it so each check is run once per piece
+// returns ports passing every check and ports not failing any check
+func evaluatePortChecks(checks []portCheck, protocol Protocol, boundaries []portRange) (portSet, portSet) {
+	pieces := elementaryRanges(allPorts, boundaries)
+	reachable := newPortSet(allPorts)
+	possible := newPortSet(allPorts)
+	for _, check := range checks {
+		passing := []portRange{}
+		notFailing := []portRange{}
+		for _, piece := range pieces {
+			result := check(Traffic{Protocol: protocol, FromPort: piece.From, ToPort: piece.To})
+			if result.IsPassing() {
+				passing = append(passing, piece)
+			}
+			if result.Status != CheckFail {
+				notFailing = append(notFailing, piece)
+			}
+		}
+		reachable = reachable.intersect(newPortSet(passing...))
+		possible = possible.intersect(newPortSet(notFailing...))
+	}
+	return reachable, possible
+}
+
+// OverallAllowedPortsVerdict - reachable only if some ports can flow between every pair, no pairs means unknown
+func OverallAllowedPortsVerdict(listOfAllowedPorts []AllowedPorts) Verdict {
+	if len(listOfAllowedPorts) == 0 {
+		return VerdictUnknown
+	}
+
+	verdict := VerdictReachable
+	for _, allowedPorts := range listOfAllowedPorts {
+		switch allowedPorts.Verdict() {
+		case VerdictUnreachable:
+			return VerdictUnreachable
+		case VerdictUnknown:
+			verdict = VerdictUnknown
+		}
+	}
+	return verdict
+}
@@ -0,0 +1,102 @@
+package analyser
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// serviceInOtherSubnet - ingress open on tcp 443, tcp 8000-8100 and udp 53, network acl denies inbound tcp 8050
+func serviceInOtherSubnet() scanner.ResourceNetworkMetaData {
+	service := instanceInSubnet("i-service", "10.0.1.9", "10.0.0.0/16")
+	networkInterface := &service.NetworkInterfaces[0]
+	networkInterface.SubnetID = "subnet-2"
+	networkInterface.SecurityGroups[0].IpPermissions = append(networkInterface.SecurityGroups[0].IpPermissions,
+		types.IpPermission{IpProtocol: aws.String("tcp"), FromPort: 8000, ToPort: 8100, IpRanges: []types.IpRange{{CidrIp: aws.String("10.0.0.0/16")}}},
+		types.IpPermission{IpProtocol: aws.String("udp"), FromPort: 53, ToPort: 53, IpRanges: []types.IpRange{{CidrIp: aws.String("10.0.0.0/16")}}})
+	networkInterface.NetworkAcl = types.NetworkAcl{NetworkAclId: aws.String("acl-2"), Entries: []types.NetworkAclEntry{
+		{RuleNumber: 10, Egress: false, RuleAction: types.RuleActionDeny, CidrBlock: aws.String("0.0.0.0/0"), Protocol: aws.String("6"),
+			PortRange: &types.PortRange{From: 8050, To: 8050}},
+		{RuleNumber: 100, Egress: false, RuleAction: types.RuleActionAllow, CidrBlock: aws.String("0.0.0.0/0"), Protocol: aws.String(protocolAll)},
+		{RuleNumber: 100, Egress: true, RuleAction: types.RuleActionAllow, CidrBlock: aws.String("0.0.0.0/0"), Protocol: aws.String(protocolAll)},
+	}}
+	return service
+}
+
+func clientInSubnet() scanner.ResourceNetworkMetaData {
+	client := instanceInSubnet("i-client", "10.0.0.5", "0.0.0.0/0")
+	client.NetworkInterfaces[0].NetworkAcl = types.NetworkAcl{NetworkAclId: aws.String("acl-1"), Entries: []types.NetworkAclEntry{
+		{RuleNumber: 100, Egress: false, RuleAction: types.RuleActionAllow, CidrBlock: aws.String("0.0.0.0/0"), Protocol: aws.String(protocolAll)},
+		{RuleNumber: 100, Egress: true, RuleAction: types.RuleActionAllow, CidrBlock: aws.String("0.0.0.0/0"), Protocol: aws.String(protocolAll)},
+	}}
+	return client
+}
+
+func TestAllPortsIntersectsSecurityGroupsAndNetworkAcls(t *testing.T) {
+	data := scanner.AwsData{
+		Sources:      []scanner.ResourceNetworkMetaData{clientInSubnet()},
+		Destinations: []scanner.ResourceNetworkMetaData{serviceInOtherSubnet()},
+	}
+
+	listOfAllowedPorts, err := RunAllPortsAnalysis(data, nil)
+
+	assert.Nil(t, err)
+	assert.Len(t, listOfAllowedPorts, 1)
+	allowedPorts := listOfAllowedPorts[0]
+	assert.Equal(t, VerdictReachable, allowedPorts.Verdict())
+	assert.Equal(t, []PortRange{
+		{ProtocolTCP, 443, 443},
+		{ProtocolTCP, 8000, 8049},
+		{ProtocolTCP, 8051, 8100},
+		{ProtocolUDP, 53, 53},
+	}, allowedPorts.Reachable)
+	assert.Len(t, allowedPorts.Unknown, 0)
+	assert.Equal(t, "tcp 8000-8049", allowedPorts.Reachable[1].String())
+	assert.Len(t, allowedPorts.Paths, 2)
+	assert.Len(t, allowedPorts.BlockingChecks(), 0)
+}
+
+func TestAllPortsBlockedByMissingRoute(t *testing.T) {
+	client := clientInSubnet()
+	client.NetworkInterfaces[0].RouteTable.Routes = []types.Route{}
+	data := scanner.AwsData{
+		Sources:      []scanner.ResourceNetworkMetaData{client},
+		Destinations: []scanner.ResourceNetworkMetaData{serviceInOtherSubnet()},
+	}
+
+	listOfAllowedPorts, err := RunAllPortsAnalysis(data, nil)
+
+	assert.Nil(t, err)
+	allowedPorts := listOfAllowedPorts[0]
+	assert.Equal(t, VerdictUnreachable, allowedPorts.Verdict())
+	assert.Len(t, allowedPorts.Reachable, 0)
+	blocking := allowedPorts.BlockingChecks()
+	assert.Len(t, blocking, 1)
+	assert.Equal(t, ReasonRouteNotFound, blocking[0].ReasonCode)
+}
+
+func TestNatSubnetNetworkAclSplitsAllowedPorts(t *testing.T) {
+	denyHTTPS := func(egress bool) types.NetworkAclEntry {
+		return types.NetworkAclEntry{RuleNumber: 50, Egress: egress, RuleAction: types.RuleActionDeny, CidrBlock: aws.String("0.0.0.0/0"),
+			Protocol: aws.String("6"), PortRange: &types.PortRange{From: 443, To: 443}}
+	}
+	client := &fakeInternetGateways{natState: types.NatGatewayStateAvailable, natAclEntries: []types.NetworkAclEntry{denyHTTPS(true), denyHTTPS(false)}}
+	data := internetData(instanceRoutingToInternet(types.Route{NatGatewayId: aws.String("nat-1")}, ""))
+
+	listOfAllowedPorts, err := RunAllPortsAnalysis(data, client)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []PortRange{
+		{ProtocolTCP, 0, 442},
+		{ProtocolTCP, 444, 65535},
+		{ProtocolUDP, 0, 65535},
+	}, listOfAllowedPorts[0].Reachable)
+
+	listOfAnalysis, err := RunAnalysis(data, client, NewPortTraffic(ProtocolTCP, 443))
+	assert.Nil(t, err)
+	assert.Equal(t, VerdictUnreachable, listOfAnalysis[0].Verdict())
+	assert.Equal(t, ReasonNaclRuleDeny, listOfAnalysis[0].NatSubnetNaclAllowsTraffic.ReasonCode)
+	assert.Equal(t, ReasonNatGatewayAvailable, listOfAnalysis[0].NatGatewayIsAvailable.ReasonCode)
+}
@@ -3,6 +3,7 @@ package analyser
 import (
 	"context"
 	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/michal-franc/cir/internal/app/cir/scanner"
@@ -11,17 +12,19 @@ import (
 	"strings"
 )
 
-// Check - holder for information if specific part of the network is configured correctly with description
-type Check struct {
-	IsPassing bool
-	Reason    string
-}
-
 // Analysis - main struct holding information which indicated if connection can be established
 type Analysis struct {
-	SourceID                      string
-	DestinationID                 string
-	DestinationPort               int32
+	SourceID               string
+	SourceInterfaceID      string
+	SourceIP               string
+	SourceOwner            scanner.InterfaceOwner
+	DestinationID          string
+	DestinationInterfaceID string
+	DestinationIP          string
+	DestinationOwner       scanner.InterfaceOwner
+	// dual stack interfaces are analysed once for every address family
+	AddressFamily                 AddressFamily
+	Traffic                       Traffic
 	CanEscapeSource               *Check
 	CanEnterDestination           *Check
 	SourceSubnetHasRoute          *Check
@@ -29,16 +32,138 @@ type Analysis struct {
 	AreInTheSameVpc               bool
 	ConnectionBetweenVPCsIsValid  *Check
 	ConnectionBetweenVPCsIsActive *Check
+	// empty if vpcs are not connected using transit gateway
+	TransitGatewayID                 string
+	TransitGatewayRouteToDestination *Check
+	TransitGatewayRouteToSource      *Check
+	AreInTheSameSubnet               bool
+	SourceNaclAllowsOutbound         *Check
+	DestinationNaclAllowsInbound     *Check
+	DestinationNaclAllowsReturn      *Check
+	SourceNaclAllowsReturn           *Check
+	// true if destination is in the internet, traffic has to leave the vpc through internet gateway
+	IsToInternet bool
+	// true if source is in the internet, it reaches the public ip of the destination
+	IsFromInternet      bool
+	DestinationPublicIP string
+	// empty if traffic to the internet doesnt go through nat gateway
+	NatGatewayID               string
+	NatGatewayIsAvailable      *Check
+	NatSubnetNaclAllowsTraffic *Check
+	InternetGatewayIsReachable *Check
+	// interface pairs in the same group share the checks as they are indistinguishable for the network configuration
+	// checks are evaluated once for the first pair of the group so reasons can mention its interfaces
+	Group     int
+	GroupSize int
+	// empty if destination is not a load balancer
+	Leg Leg
+}
+
+// AddressFamily - ip version of the source and destination addresses
+type AddressFamily string
+
+const (
+	// FamilyIPv4 - both addresses are ipv4
+	FamilyIPv4 AddressFamily = "ipv4"
+	// FamilyIPv6 - both addresses are ipv6
+	FamilyIPv6 AddressFamily = "ipv6"
+)
+
+func addressFamily(ip net.IP) AddressFamily {
+	if scanner.IsIPv6(ip) {
+		return FamilyIPv6
+	}
+	return FamilyIPv4
+}
+
+// Verdict - overall result of the analysis
+type Verdict string
+
+const (
+	// VerdictReachable - all checks are passing
+	VerdictReachable Verdict = "reachable"
+	// VerdictUnreachable - at least one check is failing
+	VerdictUnreachable Verdict = "unreachable"
+	// VerdictUnknown - no check is failing but some couldnt be evaluated
+	VerdictUnknown Verdict = "unknown"
+	// VerdictPartial - only some of the pairs are reachable, used by matrix cells
+	VerdictPartial Verdict = "partial"
+)
+
+// Checks - all the checks of the analysis
+func (a *Analysis) Checks() []*Check {
+	return []*Check{
+		a.CanEscapeSource,
+		a.CanEnterDestination,
+		a.SourceSubnetHasRoute,
+		a.DestinationSubnetHasRoute,
+		a.ConnectionBetweenVPCsIsValid,
+		a.ConnectionBetweenVPCsIsActive,
+		a.TransitGatewayRouteToDestination,
+		a.TransitGatewayRouteToSource,
+		a.SourceNaclAllowsOutbound,
+		a.DestinationNaclAllowsInbound,
+		a.DestinationNaclAllowsReturn,
+		a.SourceNaclAllowsReturn,
+		a.NatGatewayIsAvailable,
+		a.NatSubnetNaclAllowsTraffic,
+		a.InternetGatewayIsReachable,
+	}
+}
+
+// checkFields - fields holding the checks, used to replace them
+func (a *Analysis) checkFields() []**Check {
+	return []**Check{
+		&a.CanEscapeSource,
+		&a.CanEnterDestination,
+		&a.SourceSubnetHasRoute,
+		&a.DestinationSubnetHasRoute,
+		&a.ConnectionBetweenVPCsIsValid,
+		&a.ConnectionBetweenVPCsIsActive,
+		&a.TransitGatewayRouteToDestination,
+		&a.TransitGatewayRouteToSource,
+		&a.SourceNaclAllowsOutbound,
+		&a.DestinationNaclAllowsInbound,
+		&a.DestinationNaclAllowsReturn,
+		&a.SourceNaclAllowsReturn,
+		&a.NatGatewayIsAvailable,
+		&a.NatSubnetNaclAllowsTraffic,
+		&a.InternetGatewayIsReachable,
+	}
 }
 
 // CanTheyConnect - return true if all the checks are passing
 func (a *Analysis) CanTheyConnect() bool {
-	return a.CanEnterDestination.IsPassing &&
-		a.CanEscapeSource.IsPassing &&
-		a.ConnectionBetweenVPCsIsActive.IsPassing &&
-		a.ConnectionBetweenVPCsIsValid.IsPassing &&
-		a.SourceSubnetHasRoute.IsPassing &&
-		a.DestinationSubnetHasRoute.IsPassing
+	return a.Verdict() == VerdictReachable
+}
+
+// Verdict - unreachable if any check fails, unknown if any check couldnt be evaluated
+func (a *Analysis) Verdict() Verdict {
+	switch CombineStatus(a.Checks()...) {
+	case CheckFail:
+		return VerdictUnreachable
+	case CheckUnknown:
+		return VerdictUnknown
+	}
+	return VerdictReachable
+}
+
+// OverallVerdict - reachable only if every analysis is reachable, no analysis means unknown
+func OverallVerdict(listOfAnalysis []Analysis) Verdict {
+	if len(listOfAnalysis) == 0 {
+		return VerdictUnknown
+	}
+
+	verdict := VerdictReachable
+	for _, a := range listOfAnalysis {
+		switch a.Verdict() {
+		case VerdictUnreachable:
+			return VerdictUnreachable
+		case VerdictUnknown:
+			verdict = VerdictUnknown
+		}
+	}
+	return verdict
 }
 
 func toStringIPPermission(ip types.IpPermission) string {
@@ -46,77 +171,145 @@ func toStringIPPermission(ip types.IpPermission) string {
 }
 
 // RunAnalysis - takes aws data with scanned resources and processes it looking if a connection can be established
-func RunAnalysis(data scanner.AwsData, client *ec2.Client, port int32) ([]Analysis, error) {
-	listOfAnalysis := &[]Analysis{}
-
-	for _, source := range data.Sources {
-		for _, destination := range data.Destinations {
-			ipDestination := net.ParseIP(destination.PrivateIP)
-			ipSource := net.ParseIP(source.PrivateIP)
-			analysis := &Analysis{
-				SourceID:        source.ID,
-				DestinationID:   destination.ID,
-				DestinationPort: port,
+func RunAnalysis(data scanner.AwsData, client scanner.EC2API, traffic Traffic) ([]Analysis, error) {
+	listOfAnalysis := []Analysis{}
+	groups := newEquivalenceGroups()
+
+	// each network interface has its own subnet and security groups so every pair is analysed separately
+	for _, source := range interfaceMembers(data.Sources) {
+		for _, destination := range interfaceMembers(data.Destinations) {
+			for _, pair := range interfacePairs(source, destination, client) {
+				source, destination := pair[0], pair[1]
+				analysis := groups.analyse(source, destination, func() *Analysis {
+					return analyseInterfaces(source.Resource, source.Interface, destination.Resource, destination.Interface, client, traffic)
+				})
+				listOfAnalysis = append(listOfAnalysis, analysis)
 			}
-			analysis.CanEscapeSource = checkIfSecurityGroupAllowsEgressForIPandPort(source.SecurityGroup, *destination.SecurityGroup.GroupId, port, ipDestination)
+		}
+	}
 
-			canEscapeSourceSubnet, routeSource := lookForRouteOutsideSubnet(source.RouteTable, ipDestination)
-			analysis.SourceSubnetHasRoute = canEscapeSourceSubnet
+	for i := range listOfAnalysis {
+		listOfAnalysis[i].GroupSize = groups.sizes[listOfAnalysis[i].Group]
+	}
+	return listOfAnalysis, nil
+}
 
-			analysis.CanEnterDestination = checkIfSecurityGroupAllowsIngressForIPandPort(destination.SecurityGroup, *source.SecurityGroup.GroupId, port, ipSource)
+func analyseInterfaces(source scanner.ResourceNetworkMetaData, sourceInterface scanner.NetworkInterface,
+	destination scanner.ResourceNetworkMetaData, destinationInterface scanner.NetworkInterface,
+	client scanner.EC2API, traffic Traffic) *Analysis {
+	ipDestination := net.ParseIP(destinationInterface.PrivateIP)
+	ipSource := net.ParseIP(sourceInterface.PrivateIP)
+	traffic = traffic.forFamily(addressFamily(ipDestination))
+	analysis := &Analysis{
+		SourceID:               source.ID,
+		SourceInterfaceID:      sourceInterface.ID,
+		SourceIP:               sourceInterface.PrivateIP,
+		SourceOwner:            sourceInterface.Owner,
+		DestinationID:          destination.ID,
+		DestinationInterfaceID: destinationInterface.ID,
+		DestinationIP:          destinationInterface.PrivateIP,
+		DestinationOwner:       destinationInterface.Owner,
+		AddressFamily:          addressFamily(ipDestination),
+		Traffic:                traffic,
+	}
 
-			canEscapeDestinationSubnet, routeDestination := lookForRouteOutsideSubnet(destination.RouteTable, ipSource)
-			analysis.DestinationSubnetHasRoute = canEscapeDestinationSubnet
+	if destinationInterface.Internet {
+		analyseInternetEgress(analysis, source, sourceInterface, destinationInterface, client, traffic)
+		return analysis
+	}
+	if sourceInterface.Internet {
+		analyseInternetIngress(analysis, destination, sourceInterface, destinationInterface, client, traffic)
+		return analysis
+	}
+	notInInternet := passed(ReasonNotApplicable, "source and destination are not in the internet")
+	analysis.NatGatewayIsAvailable = notInInternet
+	analysis.NatSubnetNaclAllowsTraffic = notInInternet
+	analysis.InternetGatewayIsReachable = notInInternet
 
-			analysis.AreInTheSameVpc = destination.VpcID == source.VpcID
+	canEscapeSourceSubnet, routeSource, err := lookForRouteOutsideSubnet(sourceInterface, ipDestination)
+	analysis.SourceSubnetHasRoute = orUnknown(canEscapeSourceSubnet, err)
 
-			if !analysis.AreInTheSameVpc {
-				analysis.ConnectionBetweenVPCsIsValid = checkIfVPCConnectionValid(routeSource, routeDestination)
-				analysis.ConnectionBetweenVPCsIsActive = checkIfVPCConnectionIsActive(routeSource, client)
-			} else {
-				analysis.ConnectionBetweenVPCsIsValid = &Check{
-					IsPassing: true,
-					Reason:    "same vpc",
-				}
-				analysis.ConnectionBetweenVPCsIsActive = &Check{
-					IsPassing: true,
-					Reason:    "same vpc",
-				}
-			}
+	canEscapeDestinationSubnet, routeDestination, err := lookForRouteOutsideSubnet(destinationInterface, ipSource)
+	analysis.DestinationSubnetHasRoute = orUnknown(canEscapeDestinationSubnet, err)
 
-			*listOfAnalysis = append(*listOfAnalysis, *analysis)
+	analysis.AreInTheSameVpc = destination.VpcID == source.VpcID
 
-		}
+	var peering *types.VpcPeeringConnection
+	var peeringErr error
+	if !analysis.AreInTheSameVpc && routeSource.VpcPeeringConnectionId != nil {
+		peering, peeringErr = getVpcPeeringConnection(client, *routeSource.VpcPeeringConnectionId)
 	}
-	return *listOfAnalysis, nil
-}
 
-//TODO: error handling instead of fatals
-func checkIfVPCConnectionIsActive(routeSource types.Route, client *ec2.Client) *Check {
-	if routeSource.VpcPeeringConnectionId != nil {
-		vpcQuery := &ec2.DescribeVpcPeeringConnectionsInput{
-			VpcPeeringConnectionIds: []string{*routeSource.VpcPeeringConnectionId},
+	if !analysis.AreInTheSameVpc {
+		analysis.ConnectionBetweenVPCsIsValid = checkIfVPCConnectionValid(routeSource, routeDestination)
+		if peeringErr != nil {
+			analysis.ConnectionBetweenVPCsIsActive = unknownFromError(peeringErr)
+		} else {
+			analysis.ConnectionBetweenVPCsIsActive = orUnknown(checkIfVPCConnectionIsActive(routeSource, peering, client,
+				connectionSide{source.VpcID, ipSource}, connectionSide{destination.VpcID, ipDestination}))
 		}
+	} else {
+		analysis.ConnectionBetweenVPCsIsValid = passed(ReasonSameVpc, "same vpc")
+		analysis.ConnectionBetweenVPCsIsActive = passed(ReasonSameVpc, "same vpc")
+	}
 
-		vpcs, err := client.DescribeVpcPeeringConnections(context.Background(), vpcQuery)
+	referencesNotSupportedReason := groupReferencesNotSupportedReason(analysis.AreInTheSameVpc, peering)
+	analysis.CanEscapeSource = orUnknown(checkIfSecurityGroupsAllowEgress(sourceInterface,
+		peerGroups{IDs: destinationInterface.SecurityGroupIDs(), ReferencesNotSupportedReason: referencesNotSupportedReason}, traffic, ipDestination))
+	analysis.CanEnterDestination = orUnknown(checkIfSecurityGroupsAllowIngress(destinationInterface,
+		peerGroups{IDs: sourceInterface.SecurityGroupIDs(), ReferencesNotSupportedReason: referencesNotSupportedReason}, traffic, ipSource))
+
+	if analysis.ConnectionBetweenVPCsIsValid.IsPassing() && routeSource.TransitGatewayId != nil {
+		analysis.TransitGatewayID = *routeSource.TransitGatewayId
+		analysis.TransitGatewayRouteToDestination = orUnknown(checkTransitGatewayPath(client, transitGatewayPath{
+			TransitGatewayID:     analysis.TransitGatewayID,
+			FromVpcID:            source.VpcID,
+			FromAvailabilityZone: sourceInterface.AvailabilityZone,
+			ToVpcID:              destination.VpcID,
+			ToIP:                 ipDestination,
+		}))
+		analysis.TransitGatewayRouteToSource = orUnknown(checkTransitGatewayPath(client, transitGatewayPath{
+			TransitGatewayID:     analysis.TransitGatewayID,
+			FromVpcID:            destination.VpcID,
+			FromAvailabilityZone: destinationInterface.AvailabilityZone,
+			ToVpcID:              source.VpcID,
+			ToIP:                 ipSource,
+		}))
+	} else {
+		noTransitGateway := passed(ReasonNotApplicable, "not connected using tgw")
+		analysis.TransitGatewayRouteToDestination = noTransitGateway
+		analysis.TransitGatewayRouteToSource = noTransitGateway
+	}
 
-		if err != nil {
-			log.Fatalf("cant find vpc peering connections - %s", err)
-		}
+	// network acls are applied only when traffic crosses the subnet boundary
+	analysis.AreInTheSameSubnet = destinationInterface.SubnetID == sourceInterface.SubnetID
 
-		//TODO: more than one vpc
-		vpcPeeringStatus := vpcs.VpcPeeringConnections[0].Status
-		if vpcPeeringStatus.Code == types.VpcPeeringConnectionStateReasonCodeActive {
-			return &Check{
-				IsPassing: true,
-				Reason:    fmt.Sprintf("vpc peering - %s - is active", *vpcs.VpcPeeringConnections[0].VpcPeeringConnectionId),
-			}
-		}
+	if !analysis.AreInTheSameSubnet {
+		analysis.SourceNaclAllowsOutbound = orUnknown(checkIfNetworkAclAllowsTraffic(sourceInterface.NetworkAcl, true, ipDestination, traffic))
+		analysis.DestinationNaclAllowsInbound = orUnknown(checkIfNetworkAclAllowsTraffic(destinationInterface.NetworkAcl, false, ipSource, traffic))
 
-		return &Check{
-			IsPassing: false,
-			Reason:    fmt.Sprintf("vpc peering - %s - is inactive", *vpcs.VpcPeeringConnections[0].VpcPeeringConnectionId),
+		if returnTraffic, ok := traffic.returnTraffic(); ok {
+			analysis.DestinationNaclAllowsReturn = orUnknown(checkIfNetworkAclAllowsTraffic(destinationInterface.NetworkAcl, true, ipSource, returnTraffic))
+			analysis.SourceNaclAllowsReturn = orUnknown(checkIfNetworkAclAllowsTraffic(sourceInterface.NetworkAcl, false, ipDestination, returnTraffic))
+		} else {
+			noReturnTraffic := passed(ReasonNoReturnTraffic, fmt.Sprintf("no return traffic expected for %s", traffic))
+			analysis.DestinationNaclAllowsReturn = noReturnTraffic
+			analysis.SourceNaclAllowsReturn = noReturnTraffic
 		}
+	} else {
+		sameSubnet := passed(ReasonSameSubnet, "same subnet - network acl not applied")
+		analysis.SourceNaclAllowsOutbound = sameSubnet
+		analysis.DestinationNaclAllowsInbound = sameSubnet
+		analysis.DestinationNaclAllowsReturn = sameSubnet
+		analysis.SourceNaclAllowsReturn = sameSubnet
+	}
+
+	return analysis
+}
+
+func checkIfVPCConnectionIsActive(routeSource types.Route, peering *types.VpcPeeringConnection, client scanner.EC2API, source connectionSide, destination connectionSide) (*Check, error) {
+	if routeSource.VpcPeeringConnectionId != nil {
+		return checkVpcPeering(*routeSource.VpcPeeringConnectionId, peering, source, destination)
 	}
 
 	if routeSource.TransitGatewayId != nil {
@@ -127,126 +320,168 @@ func checkIfVPCConnectionIsActive(routeSource types.Route, client *ec2.Client) *
 		tgws, err := client.DescribeTransitGateways(context.Background(), tgwQuery)
 
 		if err != nil {
-			log.Fatalf("cant find vpc peering connections - %s", err)
+			return nil, &APIError{"DescribeTransitGateways", err}
+		}
+
+		if len(tgws.TransitGateways) <= 0 {
+			return failed(ReasonTransitGatewayUnavailable, fmt.Sprintf("tgw - %s - not found", *routeSource.TransitGatewayId)), nil
 		}
 
-		//TODO: more than one vpc
 		tgwState := tgws.TransitGateways[0].State
 		if tgwState == types.TransitGatewayStateAvailable {
-			return &Check{
-				IsPassing: true,
-				Reason:    fmt.Sprintf("tgw - %s - is available", *tgws.TransitGateways[0].TransitGatewayId),
-			}
+			return passed(ReasonTransitGatewayAvailable, fmt.Sprintf("tgw - %s - is available", *tgws.TransitGateways[0].TransitGatewayId)), nil
 		}
 
-		return &Check{
-			IsPassing: false,
-			Reason:    fmt.Sprintf("tgw - %s - is unavailable", *tgws.TransitGateways[0].TransitGatewayId),
-		}
+		return failed(ReasonTransitGatewayUnavailable, fmt.Sprintf("tgw - %s - is unavailable", *tgws.TransitGateways[0].TransitGatewayId)), nil
 	}
 
-	return &Check{
-		IsPassing: false,
-		Reason:    "unsupported vpc connection type",
-	}
+	return unknown(ReasonUnsupported, "unsupported vpc connection type"), nil
 }
 
 func checkIfVPCConnectionValid(sourceRoute types.Route, destRoute types.Route) *Check {
+	// local route covers only the vpc itself so if it is picked for the other vpc their cidrs overlap
+	if isLocalRoute(sourceRoute) || isLocalRoute(destRoute) {
+		return failed(ReasonVpcCidrOverlap, "route check: local route picked for resource in the other vpc - vpc cidrs overlap")
+	}
+
 	if sourceRoute.CarrierGatewayId != nil || destRoute.CarrierGatewayId != nil {
 		log.Warn("route check: CarrierGateway not supported yet")
-		return &Check{false, "CarrierGateway not supported yet"}
+		return unknown(ReasonUnsupported, "CarrierGateway not supported yet")
 	}
 
 	if sourceRoute.EgressOnlyInternetGatewayId != nil || destRoute.EgressOnlyInternetGatewayId != nil {
 		log.Warn("route check: EgressOnlyIG not supported yet")
-		return &Check{false, "EgressOnlyIG not supported yet"}
+		return unknown(ReasonUnsupported, "EgressOnlyIG not supported yet")
 	}
 
 	if sourceRoute.GatewayId != nil || destRoute.GatewayId != nil {
-		return &Check{false, fmt.Sprintf("route check: Gateway not supported yet")}
+		return unknown(ReasonUnsupported, "route check: Gateway not supported yet")
 	}
 
 	if sourceRoute.LocalGatewayId != nil || destRoute.LocalGatewayId != nil {
 		log.Warn("route check: LocalGateway not supported yet")
-		return &Check{false, "LocalGateway not supported yet"}
+		return unknown(ReasonUnsupported, "LocalGateway not supported yet")
 	}
 
 	if sourceRoute.NatGatewayId != nil || destRoute.NatGatewayId != nil {
 		log.Warn("route check: NatGateway not supported yet")
-		return &Check{false, "NatGateway not supported yet"}
+		return unknown(ReasonUnsupported, "NatGateway not supported yet")
 	}
 
 	if sourceRoute.NetworkInterfaceId != nil || destRoute.NetworkInterfaceId != nil {
 		log.Warn("route check: ENI not supported yet")
-		return &Check{false, "Eni not supported yet"}
+		return unknown(ReasonUnsupported, "Eni not supported yet")
 	}
 
 	if sourceRoute.VpcPeeringConnectionId != nil && destRoute.VpcPeeringConnectionId != nil {
 		if *sourceRoute.VpcPeeringConnectionId != *destRoute.VpcPeeringConnectionId {
-			return &Check{false, fmt.Sprintf("source vpc peering id: %s - doesnt match - dest vpc peering id: %s", *sourceRoute.VpcPeeringConnectionId, *destRoute.VpcPeeringConnectionId)}
+			return failed(ReasonVpcConnectionMismatch, fmt.Sprintf("source vpc peering id: %s - doesnt match - dest vpc peering id: %s", *sourceRoute.VpcPeeringConnectionId, *destRoute.VpcPeeringConnectionId))
 		}
-		return &Check{true, fmt.Sprintf("source and dest connected using vpc peering: %s", *sourceRoute.VpcPeeringConnectionId)}
+		return passed(ReasonVpcConnectionMatch, fmt.Sprintf("source and dest connected using vpc peering: %s", *sourceRoute.VpcPeeringConnectionId))
 	}
 
 	if sourceRoute.TransitGatewayId != nil && destRoute.TransitGatewayId != nil {
 		if *sourceRoute.TransitGatewayId != *destRoute.TransitGatewayId {
-			return &Check{false, fmt.Sprintf("source tgw id: %s - doesnt match - dest tgw id: %s", *sourceRoute.TransitGatewayId, *destRoute.TransitGatewayId)}
+			return failed(ReasonVpcConnectionMismatch, fmt.Sprintf("source tgw id: %s - doesnt match - dest tgw id: %s", *sourceRoute.TransitGatewayId, *destRoute.TransitGatewayId))
 		}
-		return &Check{true, fmt.Sprintf("source and dest connected using tgw: %s", *sourceRoute.TransitGatewayId)}
+		return passed(ReasonVpcConnectionMatch, fmt.Sprintf("source and dest connected using tgw: %s", *sourceRoute.TransitGatewayId))
 	}
 
-	return &Check{false, "not compatible or supported vpc connection"}
+	return failed(ReasonVpcConnectionMismatch, "not compatible or supported vpc connection")
 }
 
-//TODO: proper error handling
-//TODO: ipv6 support
-func lookForRouteOutsideSubnet(routeTable types.RouteTable, ipDestination net.IP) (*Check, types.Route) {
-	log.Debug("Checking subnet routing table")
-	for _, r := range routeTable.Routes {
-		if r.DestinationCidrBlock != nil {
-			//TODO: ignore for now igw and 0.0.0.0/0
-			if (*r.DestinationCidrBlock) == "0.0.0.0/0" {
-				continue
-			}
+func containsGroupID(groupIDs []string, groupID string) bool {
+	for _, id := range groupIDs {
+		if strings.EqualFold(id, groupID) {
+			return true
+		}
+	}
+	return false
+}
 
-			_, cidr, err := net.ParseCIDR(*r.DestinationCidrBlock)
-			if err != nil {
-				log.Fatalf("%s", err)
-			}
+// peerGroups - security groups attached to the other side of the connection
+type peerGroups struct {
+	IDs []string
+	// empty if rules pointing at peer groups can be used
+	ReferencesNotSupportedReason string
+	// groups which can be used even if IDs cant, e.g. of the load balancer node forwarding the traffic
+	ForwarderIDs []string
+}
 
-			if cidr.Contains(ipDestination) {
-				return &Check{
-					IsPassing: true,
-					Reason:    fmt.Sprintf("found route in route table '%s' with range '%s'", *routeTable.RouteTableId, *r.DestinationCidrBlock),
-				}, r
+func (p peerGroups) usableIDs() []string {
+	ids := append([]string{}, p.ForwarderIDs...)
+	if p.ReferencesNotSupportedReason != "" {
+		return ids
+	}
+	return append(ids, p.IDs...)
+}
+
+// referencesPeerGroup - checks if any rule points at peer group, used to explain why such rule was ignored
+func (p peerGroups) referencesPeerGroup(permissions []types.IpPermission, traffic Traffic) bool {
+	for _, permission := range permissions {
+		if !traffic.matchesIPPermission(permission) {
+			continue
+		}
+		for _, userIDGroup := range permission.UserIdGroupPairs {
+			if userIDGroup.GroupId != nil && containsGroupID(p.IDs, *userIDGroup.GroupId) {
+				return true
 			}
 		}
 	}
-	return &Check{
-		IsPassing: false,
-		Reason:    "no route found in routing table allowing traffic",
-	}, types.Route{}
+	return false
+}
+
+// checkIfSecurityGroupsAllowIngress - traffic is allowed if any of the security groups attached to the interface allows it
+func checkIfSecurityGroupsAllowIngress(networkInterface scanner.NetworkInterface, peer peerGroups, traffic Traffic, ipFrom net.IP) (*Check, error) {
+	if networkInterface.WithoutSecurityGroups {
+		return passed(ReasonNotApplicable, fmt.Sprintf("%s has no security groups - traffic not filtered", networkInterface.ID)), nil
+	}
+
+	checks := []*Check{}
+	for _, securityGroup := range networkInterface.SecurityGroups {
+		check, err := checkIfSecurityGroupAllowsIngressForIPandPort(securityGroup, peer.usableIDs(), networkInterface.PrefixLists, traffic, ipFrom)
+		if err != nil {
+			return nil, err
+		}
+		check.Reason = fmt.Sprintf("%s on %s: %s", *securityGroup.GroupId, networkInterface.ID, check.Reason)
+		if !check.IsPassing() && peer.ReferencesNotSupportedReason != "" && peer.referencesPeerGroup(securityGroup.IpPermissions, traffic) {
+			check.Reason = fmt.Sprintf("%s - rule pointing to source security group ignored as %s", check.Reason, peer.ReferencesNotSupportedReason)
+		}
+		checks = append(checks, check)
+	}
+	return anyOf(checks), nil
 }
 
-//TODO: return err and add in proper error handling
-func checkIfSecurityGroupAllowsIngressForIPandPort(securityGroupTo types.SecurityGroup, securityGroupFromID string, port int32, ipFrom net.IP) *Check {
+// checkIfSecurityGroupsAllowEgress - traffic is allowed if any of the security groups attached to the interface allows it
+func checkIfSecurityGroupsAllowEgress(networkInterface scanner.NetworkInterface, peer peerGroups, traffic Traffic, ipDestination net.IP) (*Check, error) {
+	if networkInterface.WithoutSecurityGroups {
+		return passed(ReasonNotApplicable, fmt.Sprintf("%s has no security groups - traffic not filtered", networkInterface.ID)), nil
+	}
+
+	checks := []*Check{}
+	for _, securityGroup := range networkInterface.SecurityGroups {
+		check, err := checkIfSecurityGroupAllowsEgressForIPandPort(securityGroup, peer.usableIDs(), networkInterface.PrefixLists, traffic, ipDestination)
+		if err != nil {
+			return nil, err
+		}
+		check.Reason = fmt.Sprintf("%s on %s: %s", *securityGroup.GroupId, networkInterface.ID, check.Reason)
+		if !check.IsPassing() && peer.ReferencesNotSupportedReason != "" && peer.referencesPeerGroup(securityGroup.IpPermissionsEgress, traffic) {
+			check.Reason = fmt.Sprintf("%s - rule pointing to destination security group ignored as %s", check.Reason, peer.ReferencesNotSupportedReason)
+		}
+		checks = append(checks, check)
+	}
+	return anyOf(checks), nil
+}
+
+// checkIfSecurityGroupAllowsIngressForIPandPort - rules which cant be evaluated yet dont stop the search,
+// if no other rule allows the traffic the result is unknown instead of fail
+func checkIfSecurityGroupAllowsIngressForIPandPort(securityGroupTo types.SecurityGroup, securityGroupFromIDs []string, prefixLists scanner.PrefixLists,
+	traffic Traffic, ipFrom net.IP) (*Check, error) {
 	log.Debugf("Checking security group ingress - %s\n", *securityGroupTo.GroupId)
+	unsupported := []string{}
 	for _, ingress := range securityGroupTo.IpPermissions {
-		if port >= ingress.FromPort && port <= ingress.ToPort {
+		if traffic.matchesIPPermission(ingress) {
 			log.Debugf("found port opening %s", toStringIPPermission(ingress))
-			if len(ingress.Ipv6Ranges) > 0 {
-				return &Check{
-					IsPassing: false,
-					Reason:    "IPV6 is not supported yet",
-				}
-			}
-
-			if len(ingress.PrefixListIds) > 0 {
-				return &Check{
-					IsPassing: false,
-					Reason:    "PrefixListIds are not supported yet",
-				}
-			}
 			// User ids cover sestinations like security group
 			if len(ingress.UserIdGroupPairs) > 0 {
 				log.Debugf("found %d security groups matching this port", len(ingress.UserIdGroupPairs))
@@ -254,17 +489,11 @@ func checkIfSecurityGroupAllowsIngressForIPandPort(securityGroupTo types.Securit
 					log.Debugf("checking if security group id %s matches", *userIDGroup.GroupId)
 					// check if this group id is security group
 					if strings.HasPrefix(*userIDGroup.GroupId, "sg-") {
-						if strings.EqualFold(*userIDGroup.GroupId, securityGroupFromID) {
-							return &Check{
-								IsPassing: true,
-								Reason:    fmt.Sprintf("found inbound rule pointing to security group - %s", *userIDGroup.GroupId),
-							}
+						if containsGroupID(securityGroupFromIDs, *userIDGroup.GroupId) {
+							return passed(ReasonSecurityGroupRuleMatch, fmt.Sprintf("found inbound rule pointing to security group - %s", *userIDGroup.GroupId)), nil
 						}
 					} else {
-						return &Check{
-							IsPassing: false,
-							Reason:    fmt.Sprintf("this source is not supported yet - userIDGroup %s", *userIDGroup.GroupId),
-						}
+						unsupported = append(unsupported, fmt.Sprintf("this source is not supported yet - userIDGroup %s", *userIDGroup.GroupId))
 					}
 				}
 			}
@@ -273,45 +502,63 @@ func checkIfSecurityGroupAllowsIngressForIPandPort(securityGroupTo types.Securit
 			log.Debugf("ipranges ipv4 %d", len(ingress.IpRanges))
 			for _, ipRange := range ingress.IpRanges {
 				log.Debugf("Checking if security group with '%s' can handle '%s'", *ipRange.CidrIp, ipFrom)
-				_, cidr, err := net.ParseCIDR(*ipRange.CidrIp)
+				cidr, err := parseCIDR(*ipRange.CidrIp)
 				if err != nil {
-					log.Fatalf("%s", err)
+					return nil, err
 				}
 
 				if cidr.Contains(ipFrom) {
-					return &Check{
-						IsPassing: true,
-						Reason:    fmt.Sprintf("found inbound rule pointing at ipv4 cidr range %s", *ipRange.CidrIp),
-					}
+					return passed(ReasonSecurityGroupRuleMatch, fmt.Sprintf("found inbound rule pointing at ipv4 cidr range %s", *ipRange.CidrIp)), nil
+				}
+			}
+
+			log.Debugf("ipranges ipv6 %d", len(ingress.Ipv6Ranges))
+			for _, ipRange := range ingress.Ipv6Ranges {
+				log.Debugf("Checking if security group with '%s' can handle '%s'", *ipRange.CidrIpv6, ipFrom)
+				cidr, err := parseCIDR(*ipRange.CidrIpv6)
+				if err != nil {
+					return nil, err
+				}
+
+				if cidr.Contains(ipFrom) {
+					return passed(ReasonSecurityGroupRuleMatch, fmt.Sprintf("found inbound rule pointing at ipv6 cidr range %s", *ipRange.CidrIpv6)), nil
+				}
+			}
+
+			// managed prefix lists cover cidrs shared by many rules eg. office ranges
+			for _, prefixList := range ingress.PrefixListIds {
+				prefixListID := aws.ToString(prefixList.PrefixListId)
+				entry, loaded, err := prefixListEntryContaining(prefixLists, prefixListID, ipFrom)
+				if err != nil {
+					return nil, err
+				}
+				if !loaded {
+					unsupported = append(unsupported, toStringPrefixListNotLoaded(prefixListID))
+					continue
+				}
+				if entry != nil {
+					return passed(ReasonSecurityGroupRuleMatch, fmt.Sprintf("found inbound rule pointing at %s", toStringPrefixListEntry(prefixListID, *entry))), nil
 				}
 			}
 		}
 	}
-	return &Check{
-		IsPassing: false,
-		Reason:    "destination inbound security group is not allowing this traffic",
+
+	if len(unsupported) > 0 {
+		return unknown(ReasonUnsupported, strings.Join(unsupported, ", ")), nil
 	}
+
+	return failed(ReasonSecurityGroupNoRule, "destination inbound security group is not allowing this traffic"), nil
 }
 
-//TODO: return err and add in proper error handling
-func checkIfSecurityGroupAllowsEgressForIPandPort(securityGroupFrom types.SecurityGroup, securityGroupToID string, port int32, ipDestination net.IP) *Check {
+// checkIfSecurityGroupAllowsEgressForIPandPort - rules which cant be evaluated yet dont stop the search,
+// if no other rule allows the traffic the result is unknown instead of fail
+func checkIfSecurityGroupAllowsEgressForIPandPort(securityGroupFrom types.SecurityGroup, securityGroupToIDs []string, prefixLists scanner.PrefixLists,
+	traffic Traffic, ipDestination net.IP) (*Check, error) {
 	log.Debugf("Checking security group egress - %s\n", *securityGroupFrom.GroupId)
+	unsupported := []string{}
 	for _, egress := range securityGroupFrom.IpPermissionsEgress {
-		if port >= egress.FromPort && port <= egress.ToPort {
+		if traffic.matchesIPPermission(egress) {
 			log.Debugf("found port opening %s", toStringIPPermission(egress))
-			if len(egress.Ipv6Ranges) > 0 {
-				return &Check{
-					IsPassing: false,
-					Reason:    "IPV6 is not supported yet",
-				}
-			}
-
-			if len(egress.PrefixListIds) > 0 {
-				return &Check{
-					IsPassing: false,
-					Reason:    "PrefixListIds are not supported yet",
-				}
-			}
 
 			// User ids cover sestinations like security group
 			log.Debugf("user ids %d", len(egress.UserIdGroupPairs))
@@ -320,17 +567,11 @@ func checkIfSecurityGroupAllowsEgressForIPandPort(securityGroupFrom types.Securi
 					log.Debugf("group id %s", *userIDGroup.GroupId)
 					// check if this group id is security group
 					if strings.HasPrefix(*userIDGroup.GroupId, "sg-") {
-						if strings.EqualFold(*userIDGroup.GroupId, securityGroupToID) {
-							return &Check{
-								IsPassing: true,
-								Reason:    fmt.Sprintf("found outbound rule pointing tu security group - %s", *userIDGroup.GroupId),
-							}
+						if containsGroupID(securityGroupToIDs, *userIDGroup.GroupId) {
+							return passed(ReasonSecurityGroupRuleMatch, fmt.Sprintf("found outbound rule pointing tu security group - %s", *userIDGroup.GroupId)), nil
 						}
 					} else {
-						return &Check{
-							IsPassing: false,
-							Reason:    fmt.Sprintf("this destination is not supported yet - userIDGroup %s", *userIDGroup.GroupId),
-						}
+						unsupported = append(unsupported, fmt.Sprintf("this destination is not supported yet - userIDGroup %s", *userIDGroup.GroupId))
 					}
 				}
 			}
@@ -339,23 +580,50 @@ func checkIfSecurityGroupAllowsEgressForIPandPort(securityGroupFrom types.Securi
 			log.Debugf("ipranges ipv4 %d", len(egress.IpRanges))
 			for _, ipRange := range egress.IpRanges {
 				log.Debugf("Checking if security group with '%s' can handle '%s'", *ipRange.CidrIp, ipDestination)
-				_, cidr, err := net.ParseCIDR(*ipRange.CidrIp)
+				cidr, err := parseCIDR(*ipRange.CidrIp)
 				if err != nil {
-					log.Fatalf("%s", err)
+					return nil, err
 				}
 
 				if cidr.Contains(ipDestination) {
-					return &Check{
-						IsPassing: true,
-						Reason:    fmt.Sprintf("found outbound rule pointing at ipv4 cidr range %s", *ipRange.CidrIp),
-					}
+					return passed(ReasonSecurityGroupRuleMatch, fmt.Sprintf("found outbound rule pointing at ipv4 cidr range %s", *ipRange.CidrIp)), nil
+				}
+			}
+
+			log.Debugf("ipranges ipv6 %d", len(egress.Ipv6Ranges))
+			for _, ipRange := range egress.Ipv6Ranges {
+				log.Debugf("Checking if security group with '%s' can handle '%s'", *ipRange.CidrIpv6, ipDestination)
+				cidr, err := parseCIDR(*ipRange.CidrIpv6)
+				if err != nil {
+					return nil, err
+				}
+
+				if cidr.Contains(ipDestination) {
+					return passed(ReasonSecurityGroupRuleMatch, fmt.Sprintf("found outbound rule pointing at ipv6 cidr range %s", *ipRange.CidrIpv6)), nil
+				}
+			}
+
+			// managed prefix lists cover cidrs shared by many rules eg. office ranges
+			for _, prefixList := range egress.PrefixListIds {
+				prefixListID := aws.ToString(prefixList.PrefixListId)
+				entry, loaded, err := prefixListEntryContaining(prefixLists, prefixListID, ipDestination)
+				if err != nil {
+					return nil, err
+				}
+				if !loaded {
+					unsupported = append(unsupported, toStringPrefixListNotLoaded(prefixListID))
+					continue
+				}
+				if entry != nil {
+					return passed(ReasonSecurityGroupRuleMatch, fmt.Sprintf("found outbound rule pointing at %s", toStringPrefixListEntry(prefixListID, *entry))), nil
 				}
 			}
 		}
 	}
 
-	return &Check{
-		IsPassing: false,
-		Reason:    "source outbound security group is not allowing this traffic",
+	if len(unsupported) > 0 {
+		return unknown(ReasonUnsupported, strings.Join(unsupported, ", ")), nil
 	}
+
+	return failed(ReasonSecurityGroupNoRule, "source outbound security group is not allowing this traffic"), nil
 }
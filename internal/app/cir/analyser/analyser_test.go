@@ -0,0 +1,135 @@
+package analyser
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func securityGroupWithIngress(groupID string, permissions ...types.IpPermission) types.SecurityGroup {
+	return types.SecurityGroup{
+		GroupId:       aws.String(groupID),
+		IpPermissions: permissions,
+	}
+}
+
+func TestSecurityGroupsOnInterfaceAreEvaluatedAsUnion(t *testing.T) {
+	networkInterface := scanner.NetworkInterface{
+		ID: "eni-1",
+		SecurityGroups: []types.SecurityGroup{
+			securityGroupWithIngress("sg-ssh", types.IpPermission{
+				IpProtocol: aws.String("tcp"), FromPort: 22, ToPort: 22,
+				IpRanges: []types.IpRange{{CidrIp: aws.String("10.0.0.0/8")}},
+			}),
+			securityGroupWithIngress("sg-web", types.IpPermission{
+				IpProtocol: aws.String("tcp"), FromPort: 443, ToPort: 443,
+				UserIdGroupPairs: []types.UserIdGroupPair{{GroupId: aws.String("sg-lb")}},
+			}),
+		},
+	}
+
+	check, _ := checkIfSecurityGroupsAllowIngress(networkInterface, peerGroups{IDs: []string{"sg-app", "sg-lb"}}, NewPortTraffic(ProtocolTCP, 443), net.ParseIP("10.1.1.1"))
+	assert.True(t, check.IsPassing())
+	assert.Contains(t, check.Reason, "sg-web on eni-1")
+
+	check, _ = checkIfSecurityGroupsAllowIngress(networkInterface, peerGroups{IDs: []string{"sg-app"}}, NewPortTraffic(ProtocolTCP, 443), net.ParseIP("10.1.1.1"))
+	assert.False(t, check.IsPassing())
+}
+
+func TestMostSpecificRouteIsSelected(t *testing.T) {
+	networkInterface := scanner.NetworkInterface{
+		SubnetID: "subnet-1",
+		RouteTable: types.RouteTable{
+			RouteTableId: aws.String("rtb-1"),
+			Routes: []types.Route{
+				{DestinationCidrBlock: aws.String("0.0.0.0/0"), NatGatewayId: aws.String("nat-1"), State: types.RouteStateActive},
+				{DestinationCidrBlock: aws.String("10.0.0.0/8"), TransitGatewayId: aws.String("tgw-1"), State: types.RouteStateActive},
+				{DestinationCidrBlock: aws.String("10.99.0.0/16"), VpcPeeringConnectionId: aws.String("pcx-1"), State: types.RouteStateBlackhole},
+			},
+		},
+	}
+
+	check, route, _ := lookForRouteOutsideSubnet(networkInterface, net.ParseIP("10.1.0.1"))
+	assert.True(t, check.IsPassing())
+	assert.Equal(t, "tgw-1", *route.TransitGatewayId)
+
+	check, route, _ = lookForRouteOutsideSubnet(networkInterface, net.ParseIP("10.99.0.1"))
+	assert.False(t, check.IsPassing())
+	assert.Contains(t, check.Reason, "blackhole")
+
+	_, route, _ = lookForRouteOutsideSubnet(networkInterface, net.ParseIP("192.168.0.1"))
+	assert.Equal(t, "nat-1", *route.NatGatewayId)
+}
+
+func TestVpcPeeringCidrsHaveToCoverBothIPs(t *testing.T) {
+	peering := &types.VpcPeeringConnection{
+		Status: &types.VpcPeeringConnectionStateReason{Code: types.VpcPeeringConnectionStateReasonCodeActive},
+		RequesterVpcInfo: &types.VpcPeeringConnectionVpcInfo{
+			VpcId:        aws.String("vpc-a"),
+			CidrBlock:    aws.String("10.1.0.0/16"),
+			CidrBlockSet: []types.CidrBlock{{CidrBlock: aws.String("10.1.0.0/16")}, {CidrBlock: aws.String("100.64.0.0/16")}},
+			Region:       aws.String("eu-west-1"),
+		},
+		AccepterVpcInfo: &types.VpcPeeringConnectionVpcInfo{
+			VpcId:     aws.String("vpc-b"),
+			CidrBlock: aws.String("10.2.0.0/16"),
+			Region:    aws.String("us-east-1"),
+		},
+	}
+
+	check, _ := checkVpcPeering("pcx-1", peering, connectionSide{"vpc-b", net.ParseIP("10.2.0.1")}, connectionSide{"vpc-a", net.ParseIP("100.64.0.1")})
+	assert.True(t, check.IsPassing())
+	assert.Contains(t, check.Reason, "inter-region")
+
+	check, _ = checkVpcPeering("pcx-1", peering, connectionSide{"vpc-a", net.ParseIP("10.1.0.1")}, connectionSide{"vpc-b", net.ParseIP("10.3.0.1")})
+	assert.False(t, check.IsPassing())
+
+	check, _ = checkVpcPeering("pcx-1", peering, connectionSide{"vpc-a", net.ParseIP("10.1.0.1")}, connectionSide{"vpc-c", net.ParseIP("10.2.0.1")})
+	assert.False(t, check.IsPassing())
+
+	assert.NotEmpty(t, groupReferencesNotSupportedReason(false, peering))
+}
+
+func TestUnsupportedRuleIsUnknownNotFail(t *testing.T) {
+	securityGroup := securityGroupWithIngress("sg-elb", types.IpPermission{
+		IpProtocol: aws.String("tcp"), FromPort: 443, ToPort: 443,
+		UserIdGroupPairs: []types.UserIdGroupPair{{GroupId: aws.String("amazon-elb-sg")}},
+	})
+
+	check, err := checkIfSecurityGroupAllowsIngressForIPandPort(securityGroup, []string{}, nil, NewPortTraffic(ProtocolTCP, 443), net.ParseIP("10.1.1.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, CheckUnknown, check.Status)
+	assert.Equal(t, ReasonUnsupported, check.ReasonCode)
+}
+
+func TestIpv6RangesMatchOnlyIpv6Addresses(t *testing.T) {
+	securityGroup := securityGroupWithIngress("sg-v6", types.IpPermission{
+		IpProtocol: aws.String("tcp"), FromPort: 443, ToPort: 443,
+		Ipv6Ranges: []types.Ipv6Range{{CidrIpv6: aws.String("2600:1f18:1000::/56")}},
+	})
+
+	check, err := checkIfSecurityGroupAllowsIngressForIPandPort(securityGroup, []string{}, nil, NewPortTraffic(ProtocolTCP, 443), net.ParseIP("2600:1f18:1000:10::5"))
+	assert.NoError(t, err)
+	assert.True(t, check.IsPassing())
+	assert.Contains(t, check.Reason, "ipv6 cidr range 2600:1f18:1000::/56")
+
+	check, err = checkIfSecurityGroupAllowsIngressForIPandPort(securityGroup, []string{}, nil, NewPortTraffic(ProtocolTCP, 443), net.ParseIP("10.1.1.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, CheckFail, check.Status)
+}
+
+func TestMalformedCidrReturnsError(t *testing.T) {
+	securityGroup := securityGroupWithIngress("sg-bad", types.IpPermission{
+		IpProtocol: aws.String("tcp"), FromPort: 443, ToPort: 443,
+		IpRanges: []types.IpRange{{CidrIp: aws.String("10.0.0.0/99")}},
+	})
+
+	_, err := checkIfSecurityGroupAllowsIngressForIPandPort(securityGroup, []string{}, nil, NewPortTraffic(ProtocolTCP, 443), net.ParseIP("10.1.1.1"))
+	var invalidData *InvalidDataError
+	assert.True(t, errors.As(err, &invalidData))
+	assert.Equal(t, ReasonInvalidData, orUnknown(nil, err).ReasonCode)
+}
@@ -0,0 +1,182 @@
+package analyser
+
+import (
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"strings"
+)
+
+// CheckStatus - result of a single check
+type CheckStatus string
+
+const (
+	// CheckPass - this part of the network allows the traffic
+	CheckPass CheckStatus = "pass"
+	// CheckFail - this part of the network denies the traffic
+	CheckFail CheckStatus = "fail"
+	// CheckUnknown - the check couldnt be evaluated eg. configuration not supported yet or aws api error
+	CheckUnknown CheckStatus = "unknown"
+)
+
+// ReasonCode - machine readable reason of the check result
+type ReasonCode string
+
+// Reason codes of passing or failing checks
+const (
+	ReasonSameVpc                          ReasonCode = "same_vpc"
+	ReasonSameSubnet                       ReasonCode = "same_subnet"
+	ReasonNotApplicable                    ReasonCode = "not_applicable"
+	ReasonNoReturnTraffic                  ReasonCode = "no_return_traffic"
+	ReasonSecurityGroupRuleMatch           ReasonCode = "security_group_rule_match"
+	ReasonSecurityGroupNoRule              ReasonCode = "security_group_no_matching_rule"
+	ReasonNaclRuleAllow                    ReasonCode = "nacl_rule_allow"
+	ReasonNaclRuleDeny                     ReasonCode = "nacl_rule_deny"
+	ReasonNaclNoRule                       ReasonCode = "nacl_no_matching_rule"
+	ReasonRouteFound                       ReasonCode = "route_found"
+	ReasonRouteNotFound                    ReasonCode = "route_not_found"
+	ReasonRouteBlackhole                   ReasonCode = "route_blackhole"
+	ReasonVpcCidrOverlap                   ReasonCode = "vpc_cidr_overlap"
+	ReasonVpcConnectionMatch               ReasonCode = "vpc_connection_match"
+	ReasonVpcConnectionMismatch            ReasonCode = "vpc_connection_mismatch"
+	ReasonVpcPeeringActive                 ReasonCode = "vpc_peering_active"
+	ReasonVpcPeeringInactive               ReasonCode = "vpc_peering_inactive"
+	ReasonVpcPeeringWrongVpcs              ReasonCode = "vpc_peering_wrong_vpcs"
+	ReasonVpcPeeringCidrNotCovered         ReasonCode = "vpc_peering_cidr_not_covered"
+	ReasonTransitGatewayAvailable          ReasonCode = "tgw_available"
+	ReasonTransitGatewayUnavailable        ReasonCode = "tgw_unavailable"
+	ReasonTransitGatewayAttachmentMissing  ReasonCode = "tgw_attachment_missing"
+	ReasonTransitGatewayAttachmentInactive ReasonCode = "tgw_attachment_inactive"
+	ReasonTransitGatewayRouteFound         ReasonCode = "tgw_route_found"
+	ReasonTransitGatewayRouteNotFound      ReasonCode = "tgw_route_not_found"
+	ReasonTransitGatewayRouteBlackhole     ReasonCode = "tgw_route_blackhole"
+	ReasonNoPublicIP                       ReasonCode = "no_public_ip"
+	ReasonNatGatewayAvailable              ReasonCode = "nat_available"
+	ReasonNatGatewayUnavailable            ReasonCode = "nat_unavailable"
+	ReasonInternetGatewayAttached          ReasonCode = "igw_attached"
+	ReasonInternetGatewayDetached          ReasonCode = "igw_detached"
+)
+
+// Reason codes of unknown checks
+const (
+	ReasonUnsupported ReasonCode = "unsupported"
+	ReasonAPIError    ReasonCode = "api_error"
+	ReasonInvalidData ReasonCode = "invalid_data"
+)
+
+// Check - holder for information if specific part of the network is configured correctly with description
+type Check struct {
+	Status     CheckStatus
+	ReasonCode ReasonCode
+	Reason     string
+}
+
+// IsPassing - true only if the check passed, unknown is not passing
+func (c *Check) IsPassing() bool {
+	return c.Status == CheckPass
+}
+
+func passed(code ReasonCode, reason string) *Check {
+	return &Check{CheckPass, code, reason}
+}
+
+func failed(code ReasonCode, reason string) *Check {
+	return &Check{CheckFail, code, reason}
+}
+
+func unknown(code ReasonCode, reason string) *Check {
+	return &Check{CheckUnknown, code, reason}
+}
+
+// CombineStatus - fail wins over unknown and unknown wins over pass
+func CombineStatus(checks ...*Check) CheckStatus {
+	status := CheckPass
+	for _, check := range checks {
+		if check.Status == CheckFail {
+			return CheckFail
+		}
+		if check.Status == CheckUnknown {
+			status = CheckUnknown
+		}
+	}
+	return status
+}
+
+// anyOf - used when one of the checks is enough eg. any security group attached to the interface allows traffic
+func anyOf(checks []*Check) *Check {
+	if len(checks) == 0 {
+		return failed(ReasonSecurityGroupNoRule, "no security groups")
+	}
+
+	reasons := []string{}
+	code := checks[0].ReasonCode
+	status := CheckFail
+	for _, check := range checks {
+		if check.IsPassing() {
+			return check
+		}
+		if check.Status == CheckUnknown && status == CheckFail {
+			status = CheckUnknown
+			code = check.ReasonCode
+		}
+		reasons = append(reasons, check.Reason)
+	}
+	return &Check{status, code, strings.Join(reasons, "; ")}
+}
+
+// APIError - aws api call failed so the result of the check is unknown
+type APIError struct {
+	Operation string
+	Err       error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s failed - %s", e.Operation, e.Err)
+}
+
+// Unwrap - returns the error from aws sdk
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// InvalidDataError - data returned by aws couldnt be parsed eg. malformed cidr
+type InvalidDataError struct {
+	Value string
+	Err   error
+}
+
+func (e *InvalidDataError) Error() string {
+	return fmt.Sprintf("invalid value '%s' - %s", e.Value, e.Err)
+}
+
+// Unwrap - returns the parsing error
+func (e *InvalidDataError) Unwrap() error {
+	return e.Err
+}
+
+// unknownFromError - error in one check shouldnt stop the analysis of whole fleet so it is reported as unknown result
+func unknownFromError(err error) *Check {
+	log.Warnf("check failed with error - %s", err)
+	var apiError *APIError
+	if errors.As(err, &apiError) {
+		return unknown(ReasonAPIError, err.Error())
+	}
+	return unknown(ReasonInvalidData, err.Error())
+}
+
+// orUnknown - returns the check or unknown check if there was an error
+func orUnknown(check *Check, err error) *Check {
+	if err != nil {
+		return unknownFromError(err)
+	}
+	return check
+}
+
+func parseCIDR(value string) (*net.IPNet, error) {
+	_, cidr, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, &InvalidDataError{value, err}
+	}
+	return cidr, nil
+}
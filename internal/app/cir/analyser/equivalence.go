@@ -0,0 +1,262 @@
+package analyser
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// interfaceMember - network interface together with the resource it is attached to
+// dual stack interface is a member once for every address family, PrivateIP of the interface is the address of the family
+type interfaceMember struct {
+	Resource  scanner.ResourceNetworkMetaData
+	Interface scanner.NetworkInterface
+	Family    AddressFamily
+}
+
+func interfaceMembers(resources []scanner.ResourceNetworkMetaData) []interfaceMember {
+	members := []interfaceMember{}
+	for _, resource := range resources {
+		for _, networkInterface := range resource.NetworkInterfaces {
+			if networkInterface.PrivateIP != "" {
+				members = append(members, interfaceMember{resource, networkInterface, FamilyIPv4})
+			}
+			if len(networkInterface.IPv6s) > 0 {
+				members = append(members, interfaceMember{resource, ipv6Interface(networkInterface), FamilyIPv6})
+			}
+		}
+	}
+	return members
+}
+
+// ipv6Interface - ipv6 address is reachable from the internet as it is, there is no address translation
+func ipv6Interface(networkInterface scanner.NetworkInterface) scanner.NetworkInterface {
+	networkInterface.PrivateIP = networkInterface.IPv6s[0]
+	networkInterface.PublicIP = ""
+	if scanner.IsPublicIP(net.ParseIP(networkInterface.PrivateIP)) {
+		networkInterface.PublicIP = networkInterface.PrivateIP
+	}
+	return networkInterface
+}
+
+// sameFamily - ipv4 and ipv6 traffic doesnt mix, addresses of different families are never paired
+func sameFamily(source interfaceMember, destination interfaceMember) bool {
+	return source.Family == destination.Family
+}
+
+func stringOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// equivalenceClass - interfaces in the same subnet (so route table, network acl and az) with the same security groups
+// give the same result of every check as long as their ips are matched by the same cidrs
+// vpc peering and tgw ranges are not compared, they are assumed to not split a subnet
+// internet addresses are never grouped as nat gateway subnet rules are not compared
+func equivalenceClass(member interfaceMember) string {
+	if member.Interface.Internet {
+		return scanner.InternetInterfaceID + "|" + member.Interface.PrivateIP
+	}
+
+	groupIDs := member.Interface.SecurityGroupIDs()
+	sort.Strings(groupIDs)
+	return strings.Join([]string{
+		member.Resource.VpcID,
+		member.Interface.SubnetID,
+		stringOrEmpty(member.Interface.RouteTable.RouteTableId),
+		stringOrEmpty(member.Interface.NetworkAcl.NetworkAclId),
+		strings.Join(groupIDs, ","),
+		// internet gateway is reachable only from interfaces with public ip
+		fmt.Sprintf("%t", member.Interface.PublicIP != ""),
+	}, "|")
+}
+
+// interfaceCidrs - all cidrs which can match the peer ip in security groups, route table and network acl of the interface
+// malformed cidrs are skipped here, the checks report them
+func interfaceCidrs(networkInterface scanner.NetworkInterface) []*net.IPNet {
+	values := []*string{}
+	for _, securityGroup := range networkInterface.SecurityGroups {
+		permissions := append([]types.IpPermission{}, securityGroup.IpPermissions...)
+		for _, permission := range append(permissions, securityGroup.IpPermissionsEgress...) {
+			for _, ipRange := range permission.IpRanges {
+				values = append(values, ipRange.CidrIp)
+			}
+			for _, ipRange := range permission.Ipv6Ranges {
+				values = append(values, ipRange.CidrIpv6)
+			}
+			for _, prefixList := range permission.PrefixListIds {
+				values = append(values, prefixListCidrs(networkInterface.PrefixLists, prefixList.PrefixListId)...)
+			}
+		}
+	}
+	for _, route := range networkInterface.RouteTable.Routes {
+		values = append(values, route.DestinationCidrBlock, route.DestinationIpv6CidrBlock)
+		values = append(values, prefixListCidrs(networkInterface.PrefixLists, route.DestinationPrefixListId)...)
+	}
+	for _, entry := range networkInterface.NetworkAcl.Entries {
+		values = append(values, entry.CidrBlock, entry.Ipv6CidrBlock)
+	}
+
+	cidrs := []*net.IPNet{}
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+		if _, cidr, err := net.ParseCIDR(*value); err == nil {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return cidrs
+}
+
+func prefixListCidrs(prefixLists scanner.PrefixLists, prefixListID *string) []*string {
+	values := []*string{}
+	if prefixListID == nil {
+		return values
+	}
+	entries, _ := prefixLists.Entries(*prefixListID)
+	for _, entry := range entries {
+		values = append(values, entry.Cidr)
+	}
+	return values
+}
+
+// cidrSignature - which of the cidrs contain the ip, ips with the same signature are indistinguishable for the rules
+func cidrSignature(ip string, cidrs []*net.IPNet) string {
+	parsedIP := net.ParseIP(ip)
+	signature := make([]byte, len(cidrs))
+	for i, cidr := range cidrs {
+		signature[i] = '0'
+		if parsedIP != nil && cidr.Contains(parsedIP) {
+			signature[i] = '1'
+		}
+	}
+	return string(signature)
+}
+
+// equivalenceGroups - assigns every source and destination interface pair to group analysed only once
+type equivalenceGroups struct {
+	// pairs are grouped only within the scope eg. load balancer leg and its traffic
+	scope      string
+	classCidrs map[string][]*net.IPNet
+	analysis   map[string]*Analysis
+	sizes      map[int]int
+	// public ip of the representative source of the group, analysis has no field for it
+	sourcePublicIPs map[int]string
+}
+
+func newEquivalenceGroups() *equivalenceGroups {
+	return &equivalenceGroups{
+		classCidrs:      map[string][]*net.IPNet{},
+		analysis:        map[string]*Analysis{},
+		sizes:           map[int]int{},
+		sourcePublicIPs: map[int]string{},
+	}
+}
+
+// key - ips are compared only against cidrs of both classes as only those are used by the checks
+func (g *equivalenceGroups) key(source interfaceMember, destination interfaceMember) string {
+	sourceClass := equivalenceClass(source)
+	destinationClass := equivalenceClass(destination)
+	pairClass := sourceClass + "->" + destinationClass
+
+	cidrs, ok := g.classCidrs[pairClass]
+	if !ok {
+		cidrs = append(interfaceCidrs(source.Interface), interfaceCidrs(destination.Interface)...)
+		g.classCidrs[pairClass] = cidrs
+	}
+
+	return fmt.Sprintf("%s|%s|%s|%s|%s", g.scope, source.Family, pairClass, cidrSignature(source.Interface.PrivateIP, cidrs),
+		cidrSignature(destination.Interface.PrivateIP, cidrs))
+}
+
+// analyse - runs the checks only for the first pair of the group, the other pairs reuse them
+func (g *equivalenceGroups) analyse(source interfaceMember, destination interfaceMember, analyseGroup func() *Analysis) Analysis {
+	key := g.key(source, destination)
+	representative, ok := g.analysis[key]
+	if !ok {
+		representative = analyseGroup()
+		representative.Group = len(g.analysis)
+		g.analysis[key] = representative
+		g.sourcePublicIPs[representative.Group] = source.Interface.PublicIP
+	}
+	g.sizes[representative.Group]++
+
+	analysis := *representative
+	analysis.SourceID = source.Resource.ID
+	analysis.SourceInterfaceID = source.Interface.ID
+	analysis.SourceIP = source.Interface.PrivateIP
+	analysis.SourceOwner = source.Interface.Owner
+	analysis.DestinationID = destination.Resource.ID
+	analysis.DestinationInterfaceID = destination.Interface.ID
+	analysis.DestinationIP = destination.Interface.PrivateIP
+	analysis.DestinationOwner = destination.Interface.Owner
+	if representative.DestinationPublicIP != "" {
+		analysis.DestinationPublicIP = destination.Interface.PublicIP
+	}
+	analysis.rewriteReasons(representative, g.sourcePublicIPs[representative.Group], source.Interface.PublicIP)
+	return analysis
+}
+
+// reasonToken - ids and addresses in the reasons, cidrs are one token so rule ranges are never rewritten
+var reasonToken = regexp.MustCompile(`[0-9A-Za-z.:/_-]+`)
+
+// rewriteReasons - checks are shared by the group but their reasons name the interfaces of the representative
+// every id and address of the representative is replaced by the one of the member, checks are copied so the group keeps its reasons
+// source public ip is passed separately as it is used only by the reasons of traffic to the internet
+func (a *Analysis) rewriteReasons(representative *Analysis, representativeSourcePublicIP string, sourcePublicIP string) {
+	replacements := map[string]string{}
+	for from, to := range map[string]string{
+		representative.SourceID:               a.SourceID,
+		representative.SourceInterfaceID:      a.SourceInterfaceID,
+		representative.SourceIP:               a.SourceIP,
+		representative.DestinationID:          a.DestinationID,
+		representative.DestinationInterfaceID: a.DestinationInterfaceID,
+		representative.DestinationIP:          a.DestinationIP,
+		representative.DestinationPublicIP:    a.DestinationPublicIP,
+		representativeSourcePublicIP:          sourcePublicIP,
+	} {
+		if from != "" && from != to {
+			replacements[from] = to
+		}
+	}
+	if len(replacements) == 0 {
+		return
+	}
+
+	rewrite := func(token string) string {
+		// reasons put ':' or '.' right after the id eg. 'sg-1 on eni-1: ...'
+		trimmed := strings.TrimRight(token, ".:")
+		if replacement, ok := replacements[trimmed]; ok {
+			return replacement + token[len(trimmed):]
+		}
+		return token
+	}
+	for _, field := range a.checkFields() {
+		if *field == nil {
+			continue
+		}
+		check := **field
+		check.Reason = reasonToken.ReplaceAllStringFunc(check.Reason, rewrite)
+		*field = &check
+	}
+}
+
+// GroupRepresentatives - first analysis of every equivalence group, the others have the same checks
+func GroupRepresentatives(listOfAnalysis []Analysis) []Analysis {
+	seen := map[int]bool{}
+	representatives := []Analysis{}
+	for _, a := range listOfAnalysis {
+		if !seen[a.Group] {
+			seen[a.Group] = true
+			representatives = append(representatives, a)
+		}
+	}
+	return representatives
+}
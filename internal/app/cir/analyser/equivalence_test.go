@@ -0,0 +1,55 @@
+package analyser
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func instanceInSubnet(id string, ip string, ingressCidr string) scanner.ResourceNetworkMetaData {
+	allTraffic := types.IpPermission{IpProtocol: aws.String(protocolAll), IpRanges: []types.IpRange{{CidrIp: aws.String("0.0.0.0/0")}}}
+	return scanner.ResourceNetworkMetaData{
+		ID:    id,
+		VpcID: "vpc-1",
+		NetworkInterfaces: []scanner.NetworkInterface{{
+			ID:        "eni-" + id,
+			PrivateIP: ip,
+			SubnetID:  "subnet-1",
+			SecurityGroups: []types.SecurityGroup{{
+				GroupId:             aws.String("sg-" + ingressCidr),
+				IpPermissions:       []types.IpPermission{{IpProtocol: aws.String("tcp"), FromPort: 443, ToPort: 443, IpRanges: []types.IpRange{{CidrIp: aws.String(ingressCidr)}}}},
+				IpPermissionsEgress: []types.IpPermission{allTraffic},
+			}},
+			RouteTable: types.RouteTable{
+				RouteTableId: aws.String("rtb-1"),
+				Routes:       []types.Route{{DestinationCidrBlock: aws.String("10.0.0.0/16"), GatewayId: aws.String("local"), State: types.RouteStateActive}},
+			},
+		}},
+	}
+}
+
+func TestInterfacesWithSameConfigurationAreAnalysedOnce(t *testing.T) {
+	data := scanner.AwsData{
+		Sources: []scanner.ResourceNetworkMetaData{
+			instanceInSubnet("i-allowed", "10.0.0.5", "0.0.0.0/0"),
+			instanceInSubnet("i-other-1", "10.0.0.6", "0.0.0.0/0"),
+			instanceInSubnet("i-other-2", "10.0.0.7", "0.0.0.0/0"),
+		},
+		Destinations: []scanner.ResourceNetworkMetaData{instanceInSubnet("i-db", "10.0.0.9", "10.0.0.5/32")},
+	}
+
+	listOfAnalysis, err := RunAnalysis(data, nil, NewPortTraffic(ProtocolTCP, 443))
+
+	assert.Nil(t, err)
+	assert.Len(t, listOfAnalysis, 3)
+	assert.True(t, listOfAnalysis[0].CanTheyConnect())
+	assert.Equal(t, 1, listOfAnalysis[0].GroupSize)
+	assert.False(t, listOfAnalysis[1].CanTheyConnect())
+	assert.Equal(t, listOfAnalysis[1].Group, listOfAnalysis[2].Group)
+	assert.Equal(t, 2, listOfAnalysis[2].GroupSize)
+	assert.Equal(t, "i-other-2", listOfAnalysis[2].SourceID)
+	assert.Equal(t, "10.0.0.7", listOfAnalysis[2].SourceIP)
+	assert.Len(t, GroupRepresentatives(listOfAnalysis), 2)
+}
@@ -0,0 +1,426 @@
+package analyser
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"net"
+	"strings"
+)
+
+// SourceKind - where the source allowed to reach the destination is, based on the route used for the replies
+type SourceKind string
+
+const (
+	// SourceVpc - range routed by the local route, the vpc of the destination
+	SourceVpc SourceKind = "vpc"
+	// SourcePeeredVpc - range routed to vpc peering
+	SourcePeeredVpc SourceKind = "peered-vpc"
+	// SourceTransitGateway - range routed to transit gateway
+	SourceTransitGateway SourceKind = "tgw"
+	// SourceInternet - range routed to internet gateway, nat gateway or egress-only internet gateway
+	SourceInternet SourceKind = "internet"
+	// SourceSecurityGroup - instances of the security group referenced by the inbound rule
+	SourceSecurityGroup SourceKind = "security-group"
+	// SourceUnrouted - range allowed by security group but replies to it have no route
+	SourceUnrouted SourceKind = "unrouted"
+	// SourceOther - range routed to unsupported target or rule which couldnt be evaluated
+	SourceOther SourceKind = "other"
+)
+
+// anyIPv4 and anyIPv6 - interfaces without security groups accept traffic from any address
+const (
+	anyIPv4 = "0.0.0.0/0"
+	anyIPv6 = "::/0"
+)
+
+// ExposedSource - source which can send the traffic to the destination interface with the checks done on the destination side
+// range is checked using its first address outside of the more specific route ranges inside it, they are listed separately
+type ExposedSource struct {
+	DestinationID          string
+	DestinationInterfaceID string
+	DestinationIP          string
+	AddressFamily          AddressFamily
+	Traffic                Traffic
+	Kind                   SourceKind
+	// empty if the source is a security group
+	Cidr string
+	// empty if the source is a range
+	GroupID string
+	// security group rule, prefix list entry or route the source was found in
+	Origin string
+	// target of the route to the source eg. pcx-1, tgw-1 or igw-1, empty for the local route
+	Via string
+	// empty if vpc of the source is not known
+	VpcID                        string
+	CanEnterDestination          *Check
+	DestinationSubnetHasRoute    *Check
+	ConnectionIsActive           *Check
+	DestinationNaclAllowsInbound *Check
+	DestinationNaclAllowsReturn  *Check
+}
+
+// Checks - all the checks of the exposed source
+func (s *ExposedSource) Checks() []*Check {
+	return []*Check{
+		s.CanEnterDestination,
+		s.DestinationSubnetHasRoute,
+		s.ConnectionIsActive,
+		s.DestinationNaclAllowsInbound,
+		s.DestinationNaclAllowsReturn,
+	}
+}
+
+// Verdict - unreachable if any check fails, unknown if any check couldnt be evaluated
+func (s *ExposedSource) Verdict() Verdict {
+	switch CombineStatus(s.Checks()...) {
+	case CheckFail:
+		return VerdictUnreachable
+	case CheckUnknown:
+		return VerdictUnknown
+	}
+	return VerdictReachable
+}
+
+// exposureCandidate - range which may be able to reach the destination
+type exposureCandidate struct {
+	Cidr   *net.IPNet
+	Origin string
+}
+
+// RunExposureAnalysis - finds sources which can reach the destinations starting from their security groups, route tables and network acls
+// ranges allowed by inbound rules are split by the routes and network acl entries inside them so every vpc, peering, tgw and internet range is listed separately
+func RunExposureAnalysis(data scanner.AwsData, client scanner.EC2API, traffic Traffic) ([]ExposedSource, error) {
+	exposedSources := []ExposedSource{}
+	for _, member := range interfaceMembers(data.Destinations) {
+		if member.Interface.Internet {
+			continue
+		}
+
+		memberTraffic := traffic.forFamily(member.Family)
+		candidates, groupSources, err := ingressCandidates(member, memberTraffic)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates, err = withRouteCandidates(member, candidates)
+		if err != nil {
+			return nil, err
+		}
+		candidates, err = withNetworkAclCandidates(member, candidates)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, candidate := range candidates {
+			ipSource := firstUncoveredIP(candidate.Cidr, candidates)
+			if ipSource == nil {
+				// every address of the range is in the more specific ranges listed on their own
+				continue
+			}
+			exposedSources = append(exposedSources, exposeRange(member, candidate, ipSource, client, memberTraffic))
+		}
+		exposedSources = append(exposedSources, groupSources...)
+	}
+	return exposedSources, nil
+}
+
+func newExposedSource(member interfaceMember, traffic Traffic, kind SourceKind) ExposedSource {
+	return ExposedSource{
+		DestinationID:          member.Resource.ID,
+		DestinationInterfaceID: member.Interface.ID,
+		DestinationIP:          member.Interface.PrivateIP,
+		AddressFamily:          member.Family,
+		Traffic:                traffic,
+		Kind:                   kind,
+	}
+}
+
+// appendCandidate - ranges are listed once even if many rules or routes point at them
+func appendCandidate(candidates []exposureCandidate, value string, family AddressFamily, origin string) ([]exposureCandidate, error) {
+	cidr, err := parseCIDR(value)
+	if err != nil {
+		return nil, err
+	}
+	if addressFamily(cidr.IP) != family {
+		return candidates, nil
+	}
+	for _, candidate := range candidates {
+		if candidate.Cidr.String() == cidr.String() {
+			return candidates, nil
+		}
+	}
+	return append(candidates, exposureCandidate{cidr, origin}), nil
+}
+
+// ingressCandidates - ranges of inbound rules matching the traffic and sources which cant be described by range
+// rules pointing at security groups are returned as exposed sources as there is no range to check routes for
+func ingressCandidates(member interfaceMember, traffic Traffic) ([]exposureCandidate, []ExposedSource, error) {
+	candidates := []exposureCandidate{}
+	groupSources := []ExposedSource{}
+	networkInterface := member.Interface
+
+	if networkInterface.WithoutSecurityGroups {
+		anyRange := anyIPv4
+		if member.Family == FamilyIPv6 {
+			anyRange = anyIPv6
+		}
+		candidates, err := appendCandidate(candidates, anyRange, member.Family, fmt.Sprintf("%s has no security groups", networkInterface.ID))
+		return candidates, groupSources, err
+	}
+
+	var err error
+	seenGroups := map[string]bool{}
+	for _, securityGroup := range networkInterface.SecurityGroups {
+		groupID := aws.ToString(securityGroup.GroupId)
+		for _, ingress := range securityGroup.IpPermissions {
+			if !traffic.matchesIPPermission(ingress) {
+				continue
+			}
+			rule := fmt.Sprintf("%s rule %s", groupID, toStringIPPermission(ingress))
+
+			for _, ipRange := range ingress.IpRanges {
+				if candidates, err = appendCandidate(candidates, aws.ToString(ipRange.CidrIp), member.Family, rule); err != nil {
+					return nil, nil, err
+				}
+			}
+			for _, ipRange := range ingress.Ipv6Ranges {
+				if candidates, err = appendCandidate(candidates, aws.ToString(ipRange.CidrIpv6), member.Family, rule); err != nil {
+					return nil, nil, err
+				}
+			}
+
+			for _, prefixList := range ingress.PrefixListIds {
+				prefixListID := aws.ToString(prefixList.PrefixListId)
+				entries, loaded := networkInterface.PrefixLists.Entries(prefixListID)
+				if !loaded {
+					source := newExposedSource(member, traffic, SourceOther)
+					source.Origin = rule
+					source.Via = prefixListID
+					setNotCheckedOnDestination(&source, unknown(ReasonUnsupported, fmt.Sprintf("%s on %s: %s", groupID, networkInterface.ID, toStringPrefixListNotLoaded(prefixListID))))
+					groupSources = append(groupSources, source)
+					continue
+				}
+				for _, entry := range entries {
+					if entry.Cidr == nil {
+						continue
+					}
+					origin := fmt.Sprintf("%s pointing at %s", rule, toStringPrefixListEntry(prefixListID, entry))
+					if candidates, err = appendCandidate(candidates, *entry.Cidr, member.Family, origin); err != nil {
+						return nil, nil, err
+					}
+				}
+			}
+
+			for _, userIDGroup := range ingress.UserIdGroupPairs {
+				referencedID := aws.ToString(userIDGroup.GroupId)
+				if seenGroups[referencedID] {
+					continue
+				}
+				seenGroups[referencedID] = true
+				groupSources = append(groupSources, exposeSecurityGroup(member, traffic, groupID, rule, referencedID))
+			}
+		}
+	}
+	return candidates, groupSources, nil
+}
+
+// exposeSecurityGroup - every instance of the referenced group is allowed by the rule,
+// the rest of the path depends on where the instance is so it is checked per instance
+func exposeSecurityGroup(member interfaceMember, traffic Traffic, groupID string, rule string, referencedID string) ExposedSource {
+	source := newExposedSource(member, traffic, SourceSecurityGroup)
+	source.GroupID = referencedID
+	source.Origin = rule
+	if !strings.HasPrefix(referencedID, "sg-") {
+		source.Kind = SourceOther
+		setNotCheckedOnDestination(&source, unknown(ReasonUnsupported, fmt.Sprintf("%s on %s: this source is not supported yet - userIDGroup %s",
+			groupID, member.Interface.ID, referencedID)))
+		return source
+	}
+	setNotCheckedOnDestination(&source, passed(ReasonSecurityGroupRuleMatch, fmt.Sprintf("%s on %s: found inbound rule pointing to security group - %s",
+		groupID, member.Interface.ID, referencedID)))
+	return source
+}
+
+func setNotCheckedOnDestination(source *ExposedSource, canEnter *Check) {
+	perInstance := passed(ReasonNotApplicable, "checked for every instance of the source")
+	source.CanEnterDestination = canEnter
+	source.DestinationSubnetHasRoute = perInstance
+	source.ConnectionIsActive = perInstance
+	source.DestinationNaclAllowsInbound = perInstance
+	source.DestinationNaclAllowsReturn = perInstance
+}
+
+// withRouteCandidates - route ranges inside the allowed ranges eg. vpc cidr and peered vpc cidr inside 10.0.0.0/8
+// are reached through different targets so they are added as separate candidates
+func withRouteCandidates(member interfaceMember, candidates []exposureCandidate) ([]exposureCandidate, error) {
+	routes := []exposureCandidate{}
+	for _, route := range member.Interface.RouteTable.Routes {
+		origin := fmt.Sprintf("route to '%s'", toStringRouteTarget(route))
+		values := []string{}
+		if cidr := routeCidr(route); cidr != "" {
+			values = append(values, cidr)
+		}
+		for _, value := range prefixListCidrs(member.Interface.PrefixLists, route.DestinationPrefixListId) {
+			values = append(values, aws.ToString(value))
+		}
+		for _, value := range values {
+			cidr, err := parseCIDR(value)
+			if err != nil {
+				return nil, err
+			}
+			routes = append(routes, exposureCandidate{cidr, origin})
+		}
+	}
+	return withInnerCandidates(member, candidates, routes)
+}
+
+// withNetworkAclCandidates - network acl entries inside the allowed ranges eg. deny of 10.0.128.0/17 inside 10.0.0.0/16
+// give part of the range other result so they are added as separate candidates, both directions as replies cross it too
+func withNetworkAclCandidates(member interfaceMember, candidates []exposureCandidate) ([]exposureCandidate, error) {
+	entries := []exposureCandidate{}
+	networkAcl := member.Interface.NetworkAcl
+	for _, entry := range networkAcl.Entries {
+		for _, value := range []*string{entry.CidrBlock, entry.Ipv6CidrBlock} {
+			if value == nil {
+				continue
+			}
+			cidr, err := parseCIDR(*value)
+			if err != nil {
+				return nil, err
+			}
+			origin := fmt.Sprintf("network acl '%s' rule %d", aws.ToString(networkAcl.NetworkAclId), entry.RuleNumber)
+			entries = append(entries, exposureCandidate{cidr, origin})
+		}
+	}
+	return withInnerCandidates(member, candidates, entries)
+}
+
+// withInnerCandidates - adds ranges strictly inside any of the candidates, origin says which candidate they split
+func withInnerCandidates(member interfaceMember, candidates []exposureCandidate, inner []exposureCandidate) ([]exposureCandidate, error) {
+	allowed := candidates
+	var err error
+	for _, innerCandidate := range inner {
+		for _, candidate := range allowed {
+			if !isStrictlyInside(innerCandidate.Cidr, candidate.Cidr) {
+				continue
+			}
+			if candidates, err = appendCandidate(candidates, innerCandidate.Cidr.String(), member.Family, fmt.Sprintf("%s within %s", innerCandidate.Origin, candidate.Origin)); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return candidates, nil
+}
+
+// firstUncoveredIP - first address of the range outside of the more specific candidates inside it, nil if they cover the whole range
+// eg. 10.0.0.0/8 with vpc 10.0.0.0/16 inside is checked using 10.1.0.0 so the rest of the range is described by its own route
+func firstUncoveredIP(cidr *net.IPNet, candidates []exposureCandidate) net.IP {
+	ip := cidr.IP
+	for moved := true; moved; {
+		moved = false
+		for _, candidate := range candidates {
+			if isStrictlyInside(candidate.Cidr, cidr) && candidate.Cidr.Contains(ip) {
+				ip = nextIP(lastIP(candidate.Cidr))
+				moved = true
+			}
+		}
+		if ip == nil || !cidr.Contains(ip) {
+			return nil
+		}
+	}
+	return ip
+}
+
+// exposeRange - checks done on the destination side for the address of the range, the route used for replies tells where the range is
+func exposeRange(member interfaceMember, candidate exposureCandidate, ipSource net.IP, client scanner.EC2API, traffic Traffic) ExposedSource {
+	networkInterface := member.Interface
+	source := newExposedSource(member, traffic, SourceOther)
+	source.Cidr = candidate.Cidr.String()
+	source.Origin = candidate.Origin
+
+	source.CanEnterDestination = orUnknown(checkIfSecurityGroupsAllowIngress(networkInterface, peerGroups{IDs: []string{}}, traffic, ipSource))
+	source.DestinationNaclAllowsInbound = orUnknown(checkIfNetworkAclAllowsTraffic(networkInterface.NetworkAcl, false, ipSource, traffic))
+	if returnTraffic, ok := traffic.returnTraffic(); ok {
+		source.DestinationNaclAllowsReturn = orUnknown(checkIfNetworkAclAllowsTraffic(networkInterface.NetworkAcl, true, ipSource, returnTraffic))
+	} else {
+		source.DestinationNaclAllowsReturn = passed(ReasonNoReturnTraffic, fmt.Sprintf("no return traffic expected for %s", traffic))
+	}
+
+	canReturn, route, err := lookForRouteOutsideSubnet(networkInterface, ipSource)
+	source.DestinationSubnetHasRoute = orUnknown(canReturn, err)
+	if !source.DestinationSubnetHasRoute.IsPassing() {
+		if source.DestinationSubnetHasRoute.Status == CheckFail {
+			source.Kind = SourceUnrouted
+		}
+		source.ConnectionIsActive = passed(ReasonNotApplicable, "no route to the source")
+		return source
+	}
+
+	source.Via = toStringRouteTarget(route)
+	switch {
+	case isLocalRoute(route):
+		source.Kind = SourceVpc
+		source.Via = ""
+		source.VpcID = member.Resource.VpcID
+		source.ConnectionIsActive = passed(ReasonSameVpc, "same vpc")
+	case route.VpcPeeringConnectionId != nil:
+		source.Kind = SourcePeeredVpc
+		peering, err := getVpcPeeringConnection(client, *route.VpcPeeringConnectionId)
+		if err != nil {
+			source.ConnectionIsActive = unknownFromError(err)
+			return source
+		}
+		source.VpcID = peerVpcID(peering, member.Resource.VpcID)
+		source.ConnectionIsActive = orUnknown(checkVpcPeering(*route.VpcPeeringConnectionId, peering,
+			connectionSide{source.VpcID, ipSource}, connectionSide{member.Resource.VpcID, net.ParseIP(networkInterface.PrivateIP)}))
+	case route.TransitGatewayId != nil:
+		source.Kind = SourceTransitGateway
+		source.ConnectionIsActive = orUnknown(checkIfVPCConnectionIsActive(route, nil, client, connectionSide{}, connectionSide{}))
+	case isInternetGatewayRoute(route):
+		source.Kind = SourceInternet
+		if networkInterface.PublicIP == "" {
+			source.ConnectionIsActive = failed(ReasonNoPublicIP, fmt.Sprintf("%s has no public ip or elastic ip - it cant be reached from the internet", networkInterface.ID))
+			return source
+		}
+		source.ConnectionIsActive = orUnknown(checkInternetGatewayAttached(client, *route.GatewayId, member.Resource.VpcID, networkInterface.PublicIP))
+	case route.EgressOnlyInternetGatewayId != nil:
+		source.Kind = SourceInternet
+		source.ConnectionIsActive = failed(ReasonRouteNotFound, fmt.Sprintf("route in %s points to '%s' - %s",
+			toStringRouteTable(networkInterface), source.Via, egressOnlyInternetGatewayNotAccepting))
+	case route.NatGatewayId != nil:
+		source.Kind = SourceInternet
+		source.ConnectionIsActive = failed(ReasonRouteNotFound, fmt.Sprintf("route in %s points to '%s' - nat gateway doesnt accept connections from the internet",
+			toStringRouteTable(networkInterface), source.Via))
+	default:
+		source.ConnectionIsActive = unknown(ReasonUnsupported, fmt.Sprintf("sources routed to '%s' are not supported", source.Via))
+	}
+	return source
+}
+
+// peerVpcID - the other side of the peering, empty if the peering wasnt found
+func peerVpcID(peering *types.VpcPeeringConnection, vpcID string) string {
+	if peering == nil || peering.RequesterVpcInfo == nil || peering.AccepterVpcInfo == nil {
+		return ""
+	}
+	if aws.ToString(peering.RequesterVpcInfo.VpcId) == vpcID {
+		return aws.ToString(peering.AccepterVpcInfo.VpcId)
+	}
+	return aws.ToString(peering.RequesterVpcInfo.VpcId)
+}
+
+// ExposedVerdict - exposure is reachable if any source can reach the destination, no sources means unreachable
+func ExposedVerdict(exposedSources []ExposedSource) Verdict {
+	verdict := VerdictUnreachable
+	for _, source := range exposedSources {
+		switch source.Verdict() {
+		case VerdictReachable:
+			return VerdictReachable
+		case VerdictUnknown:
+			verdict = VerdictUnknown
+		}
+	}
+	return verdict
+}
@@ -0,0 +1,157 @@
+package analyser
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type fakeExposureConnections struct {
+	fakeInternetGateways
+}
+
+func (f *fakeExposureConnections) DescribeVpcPeeringConnections(ctx context.Context, params *ec2.DescribeVpcPeeringConnectionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcPeeringConnectionsOutput, error) {
+	return &ec2.DescribeVpcPeeringConnectionsOutput{VpcPeeringConnections: []types.VpcPeeringConnection{{
+		VpcPeeringConnectionId: aws.String("pcx-1"),
+		Status:                 &types.VpcPeeringConnectionStateReason{Code: types.VpcPeeringConnectionStateReasonCodeActive},
+		RequesterVpcInfo:       &types.VpcPeeringConnectionVpcInfo{VpcId: aws.String("vpc-1"), CidrBlock: aws.String("10.0.0.0/16")},
+		AccepterVpcInfo:        &types.VpcPeeringConnectionVpcInfo{VpcId: aws.String("vpc-peer"), CidrBlock: aws.String("10.1.0.0/16")},
+	}}}, nil
+}
+
+func (f *fakeExposureConnections) DescribeTransitGateways(ctx context.Context, params *ec2.DescribeTransitGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTransitGatewaysOutput, error) {
+	return &ec2.DescribeTransitGatewaysOutput{TransitGateways: []types.TransitGateway{{
+		TransitGatewayId: aws.String("tgw-1"),
+		State:            types.TransitGatewayStateAvailable,
+	}}}, nil
+}
+
+// exposedDatabase - database allowing 10.0.0.0/8 and the app security group, routed to the vpc, peering, tgw and internet gateway
+func exposedDatabase() scanner.ResourceNetworkMetaData {
+	database := instanceInSubnet("i-db", "10.0.0.9", "10.0.0.0/8")
+	networkInterface := &database.NetworkInterfaces[0]
+	networkInterface.SecurityGroups[0].IpPermissions = append(networkInterface.SecurityGroups[0].IpPermissions, types.IpPermission{
+		IpProtocol: aws.String("tcp"), FromPort: 443, ToPort: 443, UserIdGroupPairs: []types.UserIdGroupPair{{GroupId: aws.String("sg-app")}},
+	})
+	networkInterface.RouteTable.Routes = append(networkInterface.RouteTable.Routes,
+		types.Route{DestinationCidrBlock: aws.String("10.1.0.0/16"), VpcPeeringConnectionId: aws.String("pcx-1"), State: types.RouteStateActive},
+		types.Route{DestinationCidrBlock: aws.String("10.2.0.0/16"), TransitGatewayId: aws.String("tgw-1"), State: types.RouteStateActive},
+		types.Route{DestinationCidrBlock: aws.String("0.0.0.0/0"), GatewayId: aws.String("igw-1"), State: types.RouteStateActive})
+	networkInterface.NetworkAcl = types.NetworkAcl{NetworkAclId: aws.String("acl-1"), Entries: []types.NetworkAclEntry{
+		{RuleNumber: 100, Egress: true, RuleAction: types.RuleActionAllow, CidrBlock: aws.String("0.0.0.0/0"), Protocol: aws.String(protocolAll)},
+		{RuleNumber: 100, Egress: false, RuleAction: types.RuleActionAllow, CidrBlock: aws.String("0.0.0.0/0"), Protocol: aws.String(protocolAll)},
+	}}
+	return database
+}
+
+func exposedSourceByCidr(exposedSources []ExposedSource, cidr string) *ExposedSource {
+	for i := range exposedSources {
+		if exposedSources[i].Cidr == cidr {
+			return &exposedSources[i]
+		}
+	}
+	return nil
+}
+
+func TestExposureSplitsAllowedRangeByRoutes(t *testing.T) {
+	data := scanner.AwsData{Destinations: []scanner.ResourceNetworkMetaData{exposedDatabase()}}
+
+	exposedSources, err := RunExposureAnalysis(data, &fakeExposureConnections{}, NewPortTraffic(ProtocolTCP, 443))
+
+	assert.Nil(t, err)
+	assert.Len(t, exposedSources, 5)
+
+	vpc := exposedSourceByCidr(exposedSources, "10.0.0.0/16")
+	assert.Equal(t, SourceVpc, vpc.Kind)
+	assert.Equal(t, "vpc-1", vpc.VpcID)
+	assert.Equal(t, VerdictReachable, vpc.Verdict())
+
+	peered := exposedSourceByCidr(exposedSources, "10.1.0.0/16")
+	assert.Equal(t, SourcePeeredVpc, peered.Kind)
+	assert.Equal(t, "vpc-peer", peered.VpcID)
+	assert.Equal(t, "pcx-1", peered.Via)
+	assert.Equal(t, VerdictReachable, peered.Verdict(), peered.ConnectionIsActive.Reason)
+
+	tgw := exposedSourceByCidr(exposedSources, "10.2.0.0/16")
+	assert.Equal(t, SourceTransitGateway, tgw.Kind)
+	assert.Equal(t, VerdictReachable, tgw.Verdict(), tgw.ConnectionIsActive.Reason)
+
+	// rest of the allowed range is routed to the internet gateway but the database has no public ip
+	rest := exposedSourceByCidr(exposedSources, "10.0.0.0/8")
+	assert.Equal(t, SourceInternet, rest.Kind)
+	assert.Equal(t, ReasonNoPublicIP, rest.ConnectionIsActive.ReasonCode)
+	assert.Equal(t, VerdictUnreachable, rest.Verdict())
+
+	group := exposedSources[4]
+	assert.Equal(t, SourceSecurityGroup, group.Kind)
+	assert.Equal(t, "sg-app", group.GroupID)
+	assert.Equal(t, VerdictReachable, group.Verdict())
+	assert.Equal(t, VerdictReachable, ExposedVerdict(exposedSources))
+}
+
+func TestExposureChecksNetworkAclOfAllowedRange(t *testing.T) {
+	database := exposedDatabase()
+	database.NetworkInterfaces[0].NetworkAcl.Entries = append([]types.NetworkAclEntry{
+		{RuleNumber: 10, Egress: false, RuleAction: types.RuleActionDeny, CidrBlock: aws.String("10.1.0.0/16"), Protocol: aws.String(protocolAll)},
+	}, database.NetworkInterfaces[0].NetworkAcl.Entries...)
+	data := scanner.AwsData{Destinations: []scanner.ResourceNetworkMetaData{database}}
+
+	exposedSources, err := RunExposureAnalysis(data, &fakeExposureConnections{}, NewPortTraffic(ProtocolTCP, 443))
+
+	assert.Nil(t, err)
+	peered := exposedSourceByCidr(exposedSources, "10.1.0.0/16")
+	assert.Equal(t, ReasonNaclRuleDeny, peered.DestinationNaclAllowsInbound.ReasonCode)
+	assert.Equal(t, VerdictUnreachable, peered.Verdict())
+}
+
+func TestExposureSplitsAllowedRangeByNetworkAclEntries(t *testing.T) {
+	denying := func(cidr string) scanner.AwsData {
+		database := exposedDatabase()
+		database.NetworkInterfaces[0].NetworkAcl.Entries = append([]types.NetworkAclEntry{
+			{RuleNumber: 10, Egress: false, RuleAction: types.RuleActionDeny, CidrBlock: aws.String(cidr), Protocol: aws.String(protocolAll)},
+		}, database.NetworkInterfaces[0].NetworkAcl.Entries...)
+		return scanner.AwsData{Destinations: []scanner.ResourceNetworkMetaData{database}}
+	}
+
+	// deny of the upper half leaves the rest of the vpc reachable
+	exposedSources, err := RunExposureAnalysis(denying("10.0.128.0/17"), &fakeExposureConnections{}, NewPortTraffic(ProtocolTCP, 443))
+	assert.Nil(t, err)
+	assert.Equal(t, VerdictReachable, exposedSourceByCidr(exposedSources, "10.0.0.0/16").Verdict())
+	denied := exposedSourceByCidr(exposedSources, "10.0.128.0/17")
+	assert.Equal(t, VerdictUnreachable, denied.Verdict())
+	assert.Equal(t, "network acl 'acl-1' rule 10 within sg-10.0.0.0/8 rule tcp 443-443", denied.Origin)
+
+	// deny of the first /24 doesnt hide the rest of the vpc
+	exposedSources, err = RunExposureAnalysis(denying("10.0.0.0/24"), &fakeExposureConnections{}, NewPortTraffic(ProtocolTCP, 443))
+	assert.Nil(t, err)
+	assert.Equal(t, VerdictReachable, exposedSourceByCidr(exposedSources, "10.0.0.0/16").Verdict())
+	assert.Equal(t, VerdictUnreachable, exposedSourceByCidr(exposedSources, "10.0.0.0/24").Verdict())
+}
+
+func TestExposureListsOnlyRangesOfMatchingRules(t *testing.T) {
+	data := scanner.AwsData{Destinations: []scanner.ResourceNetworkMetaData{exposedDatabase()}}
+
+	exposedSources, err := RunExposureAnalysis(data, &fakeExposureConnections{}, NewPortTraffic(ProtocolTCP, 5432))
+
+	assert.Nil(t, err)
+	assert.Len(t, exposedSources, 0)
+	assert.Equal(t, VerdictUnreachable, ExposedVerdict(exposedSources))
+}
+
+func TestFirstUncoveredIPSkipsMoreSpecificRanges(t *testing.T) {
+	outer, _ := parseCIDR("10.0.0.0/8")
+	first, _ := parseCIDR("10.0.0.0/16")
+	second, _ := parseCIDR("10.1.0.0/16")
+	whole, _ := parseCIDR("10.0.0.0/9")
+	lower, _ := parseCIDR("10.0.0.0/10")
+	upper, _ := parseCIDR("10.64.0.0/10")
+	candidates := []exposureCandidate{{outer, ""}, {second, ""}, {first, ""}}
+
+	assert.Equal(t, "10.2.0.0", firstUncoveredIP(outer, candidates).String())
+	assert.Equal(t, "10.0.0.0", firstUncoveredIP(first, candidates).String())
+	assert.Nil(t, firstUncoveredIP(whole, []exposureCandidate{{whole, ""}, {outer, ""}, {upper, ""}, {lower, ""}}))
+}
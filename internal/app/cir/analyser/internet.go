@@ -0,0 +1,486 @@
+package analyser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"net"
+	"sort"
+	"strings"
+)
+
+// internetGatewayPrefix - route GatewayId is also used by virtual private gateway and vpc endpoints
+const internetGatewayPrefix = "igw-"
+
+// internetGatewayAttached - internet gateway attachments report 'available' when attached to vpc
+const internetGatewayAttached = "available"
+
+// egressOnlyInternetGatewayNotAccepting - ipv6 counterpart of nat gateway, it has no address translation but the same direction
+const egressOnlyInternetGatewayNotAccepting = "egress-only internet gateway doesnt accept connections from the internet"
+
+func isInternetGatewayRoute(route types.Route) bool {
+	return route.GatewayId != nil && strings.HasPrefix(*route.GatewayId, internetGatewayPrefix)
+}
+
+// analyseInternetEgress - destination is outside of aws so only the path leaving the vpc is checked
+// public subnet routes directly to internet gateway which needs public ip of the source,
+// private subnet routes to nat gateway which needs elastic ip and route to internet gateway in its own subnet
+// ipv6 address is public so it reaches internet gateway directly, private ipv6 subnet routes to egress-only internet gateway
+func analyseInternetEgress(analysis *Analysis, source scanner.ResourceNetworkMetaData, sourceInterface scanner.NetworkInterface,
+	destinationInterface scanner.NetworkInterface, client scanner.EC2API, traffic Traffic) {
+	ipDestination := net.ParseIP(destinationInterface.PrivateIP)
+	ipSource := net.ParseIP(sourceInterface.PrivateIP)
+	inInternet := passed(ReasonNotApplicable, "destination is in the internet")
+	analysis.IsToInternet = true
+
+	analysis.CanEscapeSource = orUnknown(checkIfSecurityGroupsAllowEgress(sourceInterface, peerGroups{IDs: []string{}}, traffic, ipDestination))
+	analysis.CanEnterDestination = passed(ReasonNotApplicable, "destination is in the internet - its firewall is not checked")
+
+	canEscapeSourceSubnet, routeSource, err := lookForRouteOutsideSubnet(sourceInterface, ipDestination)
+	analysis.SourceSubnetHasRoute = orUnknown(canEscapeSourceSubnet, err)
+	analysis.DestinationSubnetHasRoute = inInternet
+	analysis.ConnectionBetweenVPCsIsValid = inInternet
+	analysis.ConnectionBetweenVPCsIsActive = inInternet
+	noTransitGateway := passed(ReasonNotApplicable, "not connected using tgw")
+	analysis.TransitGatewayRouteToDestination = noTransitGateway
+	analysis.TransitGatewayRouteToSource = noTransitGateway
+
+	analysis.SourceNaclAllowsOutbound = orUnknown(checkIfNetworkAclAllowsTraffic(sourceInterface.NetworkAcl, true, ipDestination, traffic))
+	analysis.DestinationNaclAllowsInbound = inInternet
+	analysis.DestinationNaclAllowsReturn = inInternet
+	if returnTraffic, ok := traffic.returnTraffic(); ok {
+		analysis.SourceNaclAllowsReturn = orUnknown(checkIfNetworkAclAllowsTraffic(sourceInterface.NetworkAcl, false, ipDestination, returnTraffic))
+	} else {
+		analysis.SourceNaclAllowsReturn = passed(ReasonNoReturnTraffic, fmt.Sprintf("no return traffic expected for %s", traffic))
+	}
+
+	analysis.NatSubnetNaclAllowsTraffic = passed(ReasonNotApplicable, "not routed through nat gateway")
+	if !analysis.SourceSubnetHasRoute.IsPassing() {
+		notRouted := passed(ReasonNotApplicable, "no route to the internet")
+		analysis.NatGatewayIsAvailable = notRouted
+		analysis.InternetGatewayIsReachable = notRouted
+		return
+	}
+
+	switch {
+	case routeSource.NatGatewayId != nil:
+		analysis.NatGatewayID = *routeSource.NatGatewayId
+		natGateway, err := getNatGateway(client, analysis.NatGatewayID)
+		if err != nil {
+			analysis.NatGatewayIsAvailable = unknownFromError(err)
+			analysis.NatSubnetNaclAllowsTraffic = unknownFromError(err)
+			analysis.InternetGatewayIsReachable = unknownFromError(err)
+			return
+		}
+		analysis.NatGatewayIsAvailable = checkNatGateway(analysis.NatGatewayID, natGateway)
+		if natGateway == nil {
+			analysis.InternetGatewayIsReachable = passed(ReasonNotApplicable, "nat gateway not found")
+			return
+		}
+		analysis.NatSubnetNaclAllowsTraffic = orUnknown(checkNatSubnetNetworkAcl(client, natGateway, sourceInterface, traffic, ipSource, ipDestination))
+		analysis.InternetGatewayIsReachable = orUnknown(checkNatGatewayRoute(client, natGateway, ipDestination))
+	case isInternetGatewayRoute(routeSource):
+		analysis.NatGatewayIsAvailable = passed(ReasonNotApplicable, "routed directly to internet gateway")
+		if sourceInterface.PublicIP == "" {
+			analysis.InternetGatewayIsReachable = failed(ReasonNoPublicIP, fmt.Sprintf("%s has no public ip - internet gateway doesnt translate private ips, use nat gateway from private subnet", sourceInterface.ID))
+			return
+		}
+		analysis.InternetGatewayIsReachable = orUnknown(checkInternetGatewayAttached(client, *routeSource.GatewayId, source.VpcID, sourceInterface.PublicIP))
+	case routeSource.EgressOnlyInternetGatewayId != nil:
+		analysis.NatGatewayIsAvailable = passed(ReasonNotApplicable, "routed to egress-only internet gateway")
+		analysis.InternetGatewayIsReachable = orUnknown(checkEgressOnlyInternetGatewayAttached(client, *routeSource.EgressOnlyInternetGatewayId, source.VpcID))
+	default:
+		analysis.NatGatewayIsAvailable = passed(ReasonNotApplicable, "not routed through nat gateway")
+		analysis.InternetGatewayIsReachable = unknown(ReasonUnsupported, fmt.Sprintf("traffic to the internet routed to '%s' - only internet gateway, egress-only internet gateway and nat gateway are supported", toStringRouteTarget(routeSource)))
+	}
+}
+
+// analyseInternetIngress - source is outside of aws so it can only reach public ip of the destination through internet gateway
+// replies have to be routed back to the same internet gateway, nat gateway doesnt accept connections from the internet
+func analyseInternetIngress(analysis *Analysis, destination scanner.ResourceNetworkMetaData, sourceInterface scanner.NetworkInterface,
+	destinationInterface scanner.NetworkInterface, client scanner.EC2API, traffic Traffic) {
+	ipSource := net.ParseIP(sourceInterface.PrivateIP)
+	inInternet := passed(ReasonNotApplicable, "source is in the internet")
+	analysis.IsFromInternet = true
+	analysis.DestinationPublicIP = destinationInterface.PublicIP
+
+	analysis.CanEscapeSource = inInternet
+	analysis.CanEnterDestination = orUnknown(checkIfSecurityGroupsAllowIngress(destinationInterface, peerGroups{IDs: []string{}}, traffic, ipSource))
+
+	canReturn, routeDestination, err := lookForRouteOutsideSubnet(destinationInterface, ipSource)
+	analysis.SourceSubnetHasRoute = inInternet
+	analysis.DestinationSubnetHasRoute = orUnknown(canReturn, err)
+	analysis.ConnectionBetweenVPCsIsValid = inInternet
+	analysis.ConnectionBetweenVPCsIsActive = inInternet
+	noTransitGateway := passed(ReasonNotApplicable, "not connected using tgw")
+	analysis.TransitGatewayRouteToDestination = noTransitGateway
+	analysis.TransitGatewayRouteToSource = noTransitGateway
+
+	analysis.SourceNaclAllowsOutbound = inInternet
+	analysis.SourceNaclAllowsReturn = inInternet
+	analysis.DestinationNaclAllowsInbound = orUnknown(checkIfNetworkAclAllowsTraffic(destinationInterface.NetworkAcl, false, ipSource, traffic))
+	if returnTraffic, ok := traffic.returnTraffic(); ok {
+		analysis.DestinationNaclAllowsReturn = orUnknown(checkIfNetworkAclAllowsTraffic(destinationInterface.NetworkAcl, true, ipSource, returnTraffic))
+	} else {
+		analysis.DestinationNaclAllowsReturn = passed(ReasonNoReturnTraffic, fmt.Sprintf("no return traffic expected for %s", traffic))
+	}
+
+	analysis.NatGatewayIsAvailable = passed(ReasonNotApplicable, "traffic from the internet doesnt go through nat gateway")
+	analysis.NatSubnetNaclAllowsTraffic = analysis.NatGatewayIsAvailable
+	switch {
+	case destinationInterface.PublicIP == "":
+		analysis.InternetGatewayIsReachable = failed(ReasonNoPublicIP, fmt.Sprintf("%s has no public ip or elastic ip - it cant be reached from the internet", destinationInterface.ID))
+	case !analysis.DestinationSubnetHasRoute.IsPassing():
+		analysis.InternetGatewayIsReachable = passed(ReasonNotApplicable, "no route to the internet")
+	case routeDestination.EgressOnlyInternetGatewayId != nil:
+		analysis.InternetGatewayIsReachable = failed(ReasonRouteNotFound, fmt.Sprintf("route in %s points to '%s' - %s",
+			toStringRouteTable(destinationInterface), toStringRouteTarget(routeDestination), egressOnlyInternetGatewayNotAccepting))
+	case !isInternetGatewayRoute(routeDestination):
+		analysis.InternetGatewayIsReachable = failed(ReasonRouteNotFound, fmt.Sprintf("route in %s points to '%s' - replies to the internet have to go through internet gateway",
+			toStringRouteTable(destinationInterface), toStringRouteTarget(routeDestination)))
+	default:
+		analysis.InternetGatewayIsReachable = orUnknown(checkInternetGatewayAttached(client, *routeDestination.GatewayId, destination.VpcID, destinationInterface.PublicIP))
+	}
+}
+
+func getNatGateway(client scanner.EC2API, natGatewayID string) (*types.NatGateway, error) {
+	natGateways, err := client.DescribeNatGateways(context.Background(), &ec2.DescribeNatGatewaysInput{
+		NatGatewayIds: []string{natGatewayID},
+	})
+	if err != nil {
+		return nil, &APIError{"DescribeNatGateways", err}
+	}
+
+	if len(natGateways.NatGateways) <= 0 {
+		return nil, nil
+	}
+	return &natGateways.NatGateways[0], nil
+}
+
+// natGatewayPublicIP - empty if nat gateway has no elastic ip
+func natGatewayPublicIP(natGateway *types.NatGateway) string {
+	for _, address := range natGateway.NatGatewayAddresses {
+		if address.PublicIp != nil {
+			return *address.PublicIp
+		}
+	}
+	return ""
+}
+
+// naclCrossing - traffic entering or leaving the subnet, ip is the other side of the traffic
+type naclCrossing struct {
+	egress  bool
+	ip      net.IP
+	traffic Traffic
+}
+
+// checkNatGateway - nat gateway has to be available with elastic ip
+func checkNatGateway(natGatewayID string, natGateway *types.NatGateway) *Check {
+	if natGateway == nil {
+		return failed(ReasonNatGatewayUnavailable, fmt.Sprintf("nat gateway %s not found", natGatewayID))
+	}
+
+	if natGateway.State != types.NatGatewayStateAvailable {
+		return failed(ReasonNatGatewayUnavailable, fmt.Sprintf("nat gateway %s is %s", natGatewayID, natGateway.State))
+	}
+
+	publicIP := natGatewayPublicIP(natGateway)
+	if publicIP == "" {
+		return failed(ReasonNoPublicIP, fmt.Sprintf("nat gateway %s has no elastic ip", natGatewayID))
+	}
+	return passed(ReasonNatGatewayAvailable, fmt.Sprintf("nat gateway %s is available with elastic ip %s", natGatewayID, publicIP))
+}
+
+// natSubnetNetworkAcl - network acl of the nat gateway subnet, false if the nat gateway is in the source subnet
+// as the traffic doesnt cross the subnet boundary to reach it
+func natSubnetNetworkAcl(client scanner.EC2API, natGateway *types.NatGateway, sourceInterface scanner.NetworkInterface) (types.NetworkAcl, bool, error) {
+	natSubnetID := aws.ToString(natGateway.SubnetId)
+	if natSubnetID == sourceInterface.SubnetID {
+		return types.NetworkAcl{}, false, nil
+	}
+	networkAcl, err := getSubnetNetworkAcl(client, natSubnetID)
+	return networkAcl, true, err
+}
+
+// checkNatSubnetNetworkAcl - traffic from the source subnet crosses network acl of the nat subnet twice - entering and leaving the nat gateway
+// the same for the return traffic
+func checkNatSubnetNetworkAcl(client scanner.EC2API, natGateway *types.NatGateway, sourceInterface scanner.NetworkInterface,
+	traffic Traffic, ipSource net.IP, ipDestination net.IP) (*Check, error) {
+	networkAcl, crossed, err := natSubnetNetworkAcl(client, natGateway, sourceInterface)
+	if err != nil {
+		return nil, err
+	}
+	if !crossed {
+		return passed(ReasonSameSubnet, fmt.Sprintf("nat gateway %s is in the source subnet - its network acl is not crossed", aws.ToString(natGateway.NatGatewayId))), nil
+	}
+	return checkNatSubnetTraffic(natGateway, networkAcl, traffic, ipSource, ipDestination)
+}
+
+func checkNatSubnetTraffic(natGateway *types.NatGateway, networkAcl types.NetworkAcl, traffic Traffic, ipSource net.IP, ipDestination net.IP) (*Check, error) {
+	naclChecks := []naclCrossing{{false, ipSource, traffic}, {true, ipDestination, traffic}}
+	if returnTraffic, ok := traffic.returnTraffic(); ok {
+		naclChecks = append(naclChecks, naclCrossing{false, ipDestination, returnTraffic}, naclCrossing{true, ipSource, returnTraffic})
+	}
+
+	var check *Check
+	for _, naclCheck := range naclChecks {
+		var err error
+		check, err = checkIfNetworkAclAllowsTraffic(networkAcl, naclCheck.egress, naclCheck.ip, naclCheck.traffic)
+		if err != nil {
+			return nil, err
+		}
+		if !check.IsPassing() {
+			break
+		}
+	}
+	check.Reason = fmt.Sprintf("nat gateway %s subnet %s: %s", aws.ToString(natGateway.NatGatewayId), aws.ToString(natGateway.SubnetId), check.Reason)
+	return check, nil
+}
+
+// checkNatGatewayRoute - nat gateway forwards the traffic using route table of its own subnet
+func checkNatGatewayRoute(client scanner.EC2API, natGateway *types.NatGateway, ipDestination net.IP) (*Check, error) {
+	natSubnetID := aws.ToString(natGateway.SubnetId)
+	routeTable, err := getSubnetRouteTable(client, natSubnetID, aws.ToString(natGateway.VpcId))
+	if err != nil {
+		return nil, err
+	}
+
+	prefixLists, err := getRoutePrefixLists(client, routeTable)
+	if err != nil {
+		return nil, err
+	}
+
+	match, found, err := selectRoute(routeTable, prefixLists, ipDestination)
+	if err != nil {
+		return nil, err
+	}
+	route := match.Route
+
+	if !found {
+		return failed(ReasonRouteNotFound, fmt.Sprintf("route table '%s' of nat gateway subnet %s has no route to %s",
+			aws.ToString(routeTable.RouteTableId), natSubnetID, ipDestination)), nil
+	}
+
+	if route.State == types.RouteStateBlackhole {
+		return failed(ReasonRouteBlackhole, fmt.Sprintf("route in route table '%s' of nat gateway subnet %s with range '%s' to '%s' is a blackhole - traffic is dropped",
+			aws.ToString(routeTable.RouteTableId), natSubnetID, match.Destination, toStringRouteTarget(route))), nil
+	}
+
+	if !isInternetGatewayRoute(route) {
+		return failed(ReasonRouteNotFound, fmt.Sprintf("route in route table '%s' of nat gateway subnet %s points to '%s' - nat gateway needs route to internet gateway",
+			aws.ToString(routeTable.RouteTableId), natSubnetID, toStringRouteTarget(route))), nil
+	}
+
+	return checkInternetGatewayAttached(client, *route.GatewayId, aws.ToString(natGateway.VpcId), natGatewayPublicIP(natGateway))
+}
+
+// checkInternetGatewayAttached - detached internet gateway stays in route tables and drops the traffic
+func checkInternetGatewayAttached(client scanner.EC2API, internetGatewayID string, vpcID string, publicIP string) (*Check, error) {
+	internetGateways, err := client.DescribeInternetGateways(context.Background(), &ec2.DescribeInternetGatewaysInput{
+		InternetGatewayIds: []string{internetGatewayID},
+	})
+	if err != nil {
+		return nil, &APIError{"DescribeInternetGateways", err}
+	}
+
+	if len(internetGateways.InternetGateways) <= 0 {
+		return failed(ReasonInternetGatewayDetached, fmt.Sprintf("internet gateway %s not found", internetGatewayID)), nil
+	}
+
+	for _, attachment := range internetGateways.InternetGateways[0].Attachments {
+		if aws.ToString(attachment.VpcId) != vpcID {
+			continue
+		}
+		if string(attachment.State) == internetGatewayAttached || attachment.State == types.AttachmentStatusAttached {
+			return passed(ReasonInternetGatewayAttached, fmt.Sprintf("internet gateway %s attached to %s translates public ip %s", internetGatewayID, vpcID, publicIP)), nil
+		}
+	}
+	return failed(ReasonInternetGatewayDetached, fmt.Sprintf("internet gateway %s is not attached to %s", internetGatewayID, vpcID)), nil
+}
+
+// checkEgressOnlyInternetGatewayAttached - outbound only ipv6 traffic, replies to it are allowed back
+func checkEgressOnlyInternetGatewayAttached(client scanner.EC2API, egressOnlyInternetGatewayID string, vpcID string) (*Check, error) {
+	egressOnlyInternetGateways, err := client.DescribeEgressOnlyInternetGateways(context.Background(), &ec2.DescribeEgressOnlyInternetGatewaysInput{
+		EgressOnlyInternetGatewayIds: []string{egressOnlyInternetGatewayID},
+	})
+	if err != nil {
+		return nil, &APIError{"DescribeEgressOnlyInternetGateways", err}
+	}
+
+	if len(egressOnlyInternetGateways.EgressOnlyInternetGateways) <= 0 {
+		return failed(ReasonInternetGatewayDetached, fmt.Sprintf("egress-only internet gateway %s not found", egressOnlyInternetGatewayID)), nil
+	}
+
+	for _, attachment := range egressOnlyInternetGateways.EgressOnlyInternetGateways[0].Attachments {
+		if aws.ToString(attachment.VpcId) != vpcID {
+			continue
+		}
+		if string(attachment.State) == internetGatewayAttached || attachment.State == types.AttachmentStatusAttached {
+			return passed(ReasonInternetGatewayAttached, fmt.Sprintf("egress-only internet gateway %s attached to %s", egressOnlyInternetGatewayID, vpcID)), nil
+		}
+	}
+	return failed(ReasonInternetGatewayDetached, fmt.Sprintf("egress-only internet gateway %s is not attached to %s", egressOnlyInternetGatewayID, vpcID)), nil
+}
+
+// getSubnetRouteTable - route table explicitly associated with the subnet or main route table of the vpc
+func getSubnetRouteTable(client scanner.EC2API, subnetID string, vpcID string) (types.RouteTable, error) {
+	filters := [][]types.Filter{
+		{{Name: aws.String("association.subnet-id"), Values: []string{subnetID}}},
+		{{Name: aws.String("association.main"), Values: []string{"true"}}, {Name: aws.String("vpc-id"), Values: []string{vpcID}}},
+	}
+	for _, filter := range filters {
+		routeTables, err := client.DescribeRouteTables(context.Background(), &ec2.DescribeRouteTablesInput{Filters: filter})
+		if err != nil {
+			return types.RouteTable{}, &APIError{"DescribeRouteTables", err}
+		}
+		if len(routeTables.RouteTables) > 0 {
+			return routeTables.RouteTables[0], nil
+		}
+	}
+	return types.RouteTable{}, &InvalidDataError{subnetID, fmt.Errorf("no route table found for subnet")}
+}
+
+func getSubnetNetworkAcl(client scanner.EC2API, subnetID string) (types.NetworkAcl, error) {
+	networkAcls, err := client.DescribeNetworkAcls(context.Background(), &ec2.DescribeNetworkAclsInput{
+		Filters: []types.Filter{{Name: aws.String("association.subnet-id"), Values: []string{subnetID}}},
+	})
+	if err != nil {
+		return types.NetworkAcl{}, &APIError{"DescribeNetworkAcls", err}
+	}
+	if len(networkAcls.NetworkAcls) <= 0 {
+		return types.NetworkAcl{}, &InvalidDataError{subnetID, fmt.Errorf("no network acl found for subnet")}
+	}
+	return networkAcls.NetworkAcls[0], nil
+}
+
+// natSubnetCidrs - ranges used by route table and network acl of subnets of nat gateways the interface routes to
+// nat gateways which cant be read are skipped, the path analysis reports the error
+func natSubnetCidrs(networkInterface scanner.NetworkInterface, client scanner.EC2API) []*net.IPNet {
+	values := []*string{}
+	for _, route := range networkInterface.RouteTable.Routes {
+		if route.NatGatewayId == nil {
+			continue
+		}
+		natGateway, err := getNatGateway(client, *route.NatGatewayId)
+		if err != nil || natGateway == nil {
+			continue
+		}
+		natSubnetID := aws.ToString(natGateway.SubnetId)
+		if routeTable, err := getSubnetRouteTable(client, natSubnetID, aws.ToString(natGateway.VpcId)); err == nil {
+			for _, natRoute := range routeTable.Routes {
+				values = append(values, natRoute.DestinationCidrBlock, natRoute.DestinationIpv6CidrBlock)
+			}
+		}
+		if networkAcl, err := getSubnetNetworkAcl(client, natSubnetID); err == nil {
+			for _, entry := range networkAcl.Entries {
+				values = append(values, entry.CidrBlock, entry.Ipv6CidrBlock)
+			}
+		}
+	}
+
+	cidrs := []*net.IPNet{}
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+		if _, cidr, err := net.ParseCIDR(*value); err == nil {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return cidrs
+}
+
+// rangePieces - first address of every part of the range split by the ranges inside it
+// every rule and route contains the whole part or none of it so the first address stands for the part
+func rangePieces(cidr *net.IPNet, cidrs []*net.IPNet) []net.IP {
+	starts := map[string]net.IP{cidr.IP.String(): cidr.IP}
+	for _, inner := range cidrs {
+		if !isStrictlyInside(inner, cidr) {
+			continue
+		}
+		starts[inner.IP.String()] = inner.IP
+		if after := nextIP(lastIP(inner)); after != nil && cidr.Contains(after) {
+			starts[after.String()] = after
+		}
+	}
+
+	pieces := []net.IP{}
+	for _, start := range starts {
+		pieces = append(pieces, start)
+	}
+	sort.Slice(pieces, func(i, j int) bool {
+		return bytes.Compare(pieces[i].To16(), pieces[j].To16()) < 0
+	})
+	return pieces
+}
+
+// isStrictlyInside - inner range is smaller than the outer one and all of its addresses are in it
+func isStrictlyInside(inner *net.IPNet, outer *net.IPNet) bool {
+	innerLength, innerBits := inner.Mask.Size()
+	outerLength, outerBits := outer.Mask.Size()
+	return innerBits == outerBits && innerLength > outerLength && outer.Contains(inner.IP)
+}
+
+// lastIP - broadcast address of the range
+func lastIP(cidr *net.IPNet) net.IP {
+	last := make(net.IP, len(cidr.IP))
+	for i := range cidr.IP {
+		last[i] = cidr.IP[i] | ^cidr.Mask[i]
+	}
+	return last
+}
+
+// nextIP - nil if the address is the last one of its family
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			return next
+		}
+	}
+	return nil
+}
+
+// rangeMembers - internet range is analysed once for every part of it the other side treats differently
+// so the range is reachable only if all of its parts are, members not found by cidr query are returned as they are
+func rangeMembers(member interfaceMember, other interfaceMember, client scanner.EC2API) []interfaceMember {
+	if member.Interface.Cidr == "" {
+		return []interfaceMember{member}
+	}
+	_, cidr, err := net.ParseCIDR(member.Interface.Cidr)
+	if err != nil {
+		return []interfaceMember{member}
+	}
+
+	cidrs := append(interfaceCidrs(other.Interface), natSubnetCidrs(other.Interface, client)...)
+	members := []interfaceMember{}
+	for _, ip := range rangePieces(cidr, cidrs) {
+		piece := member
+		piece.Interface.PrivateIP = ip.String()
+		if piece.Interface.PublicIP != "" {
+			piece.Interface.PublicIP = ip.String()
+		}
+		members = append(members, piece)
+	}
+	return members
+}
+
+// interfacePairs - source and destination pairs of the same family, internet range is split into its parts
+func interfacePairs(source interfaceMember, destination interfaceMember, client scanner.EC2API) [][2]interfaceMember {
+	pairs := [][2]interfaceMember{}
+	if !sameFamily(source, destination) {
+		return pairs
+	}
+	for _, sourcePiece := range rangeMembers(source, destination, client) {
+		for _, destinationPiece := range rangeMembers(destination, source, client) {
+			pairs = append(pairs, [2]interfaceMember{sourcePiece, destinationPiece})
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,227 @@
+package analyser
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// fakeInternetGateways - nat-1 in subnet-public routing to igw-1 attached to vpc-1
+type fakeInternetGateways struct {
+	scanner.EC2API
+	natState types.NatGatewayState
+	// entries of network acl of subnet-public, all traffic is allowed if not set
+	natAclEntries []types.NetworkAclEntry
+}
+
+func (f *fakeInternetGateways) DescribeNatGateways(ctx context.Context, params *ec2.DescribeNatGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNatGatewaysOutput, error) {
+	return &ec2.DescribeNatGatewaysOutput{NatGateways: []types.NatGateway{{
+		NatGatewayId:        aws.String("nat-1"),
+		State:               f.natState,
+		SubnetId:            aws.String("subnet-public"),
+		VpcId:               aws.String("vpc-1"),
+		NatGatewayAddresses: []types.NatGatewayAddress{{PublicIp: aws.String("34.1.1.1")}},
+	}}}, nil
+}
+
+func (f *fakeInternetGateways) DescribeInternetGateways(ctx context.Context, params *ec2.DescribeInternetGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInternetGatewaysOutput, error) {
+	return &ec2.DescribeInternetGatewaysOutput{InternetGateways: []types.InternetGateway{{
+		InternetGatewayId: aws.String("igw-1"),
+		Attachments:       []types.InternetGatewayAttachment{{VpcId: aws.String("vpc-1"), State: internetGatewayAttached}},
+	}}}, nil
+}
+
+func (f *fakeInternetGateways) DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+	return &ec2.DescribeRouteTablesOutput{RouteTables: []types.RouteTable{{
+		RouteTableId: aws.String("rtb-public"),
+		Routes:       []types.Route{{DestinationCidrBlock: aws.String("0.0.0.0/0"), GatewayId: aws.String("igw-1"), State: types.RouteStateActive}},
+	}}}, nil
+}
+
+func (f *fakeInternetGateways) DescribeNetworkAcls(ctx context.Context, params *ec2.DescribeNetworkAclsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkAclsOutput, error) {
+	entries := []types.NetworkAclEntry{
+		{RuleNumber: 100, Egress: true, RuleAction: types.RuleActionAllow, CidrBlock: aws.String("0.0.0.0/0"), Protocol: aws.String(protocolAll)},
+		{RuleNumber: 100, Egress: false, RuleAction: types.RuleActionAllow, CidrBlock: aws.String("0.0.0.0/0"), Protocol: aws.String(protocolAll)},
+	}
+	return &ec2.DescribeNetworkAclsOutput{NetworkAcls: []types.NetworkAcl{{
+		NetworkAclId: aws.String("acl-public"),
+		Entries:      append(append([]types.NetworkAclEntry{}, f.natAclEntries...), entries...),
+	}}}, nil
+}
+
+// instanceRoutingToInternet - instance with all traffic allowed and default route to given gateway
+func instanceRoutingToInternet(route types.Route, publicIP string) scanner.ResourceNetworkMetaData {
+	instance := instanceInSubnet("i-app", "10.0.0.5", "0.0.0.0/0")
+	allowAll := types.NetworkAcl{NetworkAclId: aws.String("acl-1"), Entries: []types.NetworkAclEntry{
+		{RuleNumber: 100, Egress: true, RuleAction: types.RuleActionAllow, CidrBlock: aws.String("0.0.0.0/0"), Protocol: aws.String(protocolAll)},
+		{RuleNumber: 100, Egress: false, RuleAction: types.RuleActionAllow, CidrBlock: aws.String("0.0.0.0/0"), Protocol: aws.String(protocolAll)},
+	}}
+	route.DestinationCidrBlock = aws.String("0.0.0.0/0")
+	route.State = types.RouteStateActive
+	instance.NetworkInterfaces[0].RouteTable.Routes = append(instance.NetworkInterfaces[0].RouteTable.Routes, route)
+	instance.NetworkInterfaces[0].NetworkAcl = allowAll
+	instance.NetworkInterfaces[0].PublicIP = publicIP
+	return instance
+}
+
+func internetData(source scanner.ResourceNetworkMetaData) scanner.AwsData {
+	return scanner.AwsData{
+		Sources: []scanner.ResourceNetworkMetaData{source},
+		Destinations: []scanner.ResourceNetworkMetaData{{
+			ID:                "api.stripe.com",
+			NetworkInterfaces: []scanner.NetworkInterface{{ID: scanner.InternetInterfaceID, PrivateIP: "54.187.174.169", Internet: true}},
+		}},
+	}
+}
+
+func TestPrivateSubnetReachesInternetThroughNatGateway(t *testing.T) {
+	client := &fakeInternetGateways{natState: types.NatGatewayStateAvailable}
+	data := internetData(instanceRoutingToInternet(types.Route{NatGatewayId: aws.String("nat-1")}, ""))
+
+	listOfAnalysis, err := RunAnalysis(data, client, NewPortTraffic(ProtocolTCP, 443))
+
+	assert.Nil(t, err)
+	analysis := listOfAnalysis[0]
+	assert.True(t, analysis.CanTheyConnect(), analysis.InternetGatewayIsReachable.Reason)
+	assert.True(t, analysis.IsToInternet)
+	assert.Equal(t, "nat-1", analysis.NatGatewayID)
+	assert.Contains(t, analysis.NatGatewayIsAvailable.Reason, "34.1.1.1")
+	assert.Equal(t, ReasonInternetGatewayAttached, analysis.InternetGatewayIsReachable.ReasonCode)
+}
+
+func TestUnavailableNatGatewayBlocksInternet(t *testing.T) {
+	client := &fakeInternetGateways{natState: types.NatGatewayStateFailed}
+	data := internetData(instanceRoutingToInternet(types.Route{NatGatewayId: aws.String("nat-1")}, ""))
+
+	listOfAnalysis, _ := RunAnalysis(data, client, NewPortTraffic(ProtocolTCP, 443))
+
+	assert.Equal(t, VerdictUnreachable, listOfAnalysis[0].Verdict())
+	assert.Equal(t, ReasonNatGatewayUnavailable, listOfAnalysis[0].NatGatewayIsAvailable.ReasonCode)
+}
+
+func TestPublicSubnetNeedsPublicIP(t *testing.T) {
+	client := &fakeInternetGateways{}
+
+	withoutPublicIP, _ := RunAnalysis(internetData(instanceRoutingToInternet(types.Route{GatewayId: aws.String("igw-1")}, "")), client, NewPortTraffic(ProtocolTCP, 443))
+	withPublicIP, _ := RunAnalysis(internetData(instanceRoutingToInternet(types.Route{GatewayId: aws.String("igw-1")}, "52.1.1.1")), client, NewPortTraffic(ProtocolTCP, 443))
+
+	assert.Equal(t, ReasonNoPublicIP, withoutPublicIP[0].InternetGatewayIsReachable.ReasonCode)
+	assert.True(t, withPublicIP[0].CanTheyConnect())
+	assert.Equal(t, ReasonNotApplicable, withPublicIP[0].NatGatewayIsAvailable.ReasonCode)
+}
+
+func TestCidrDestinationIsSplitByNetworkAclEntryInsideIt(t *testing.T) {
+	client := &fakeInternetGateways{}
+	source := instanceRoutingToInternet(types.Route{GatewayId: aws.String("igw-1")}, "52.1.1.1")
+	source.NetworkInterfaces[0].NetworkAcl.Entries = append(source.NetworkInterfaces[0].NetworkAcl.Entries,
+		types.NetworkAclEntry{RuleNumber: 50, Egress: true, RuleAction: types.RuleActionDeny, CidrBlock: aws.String("203.0.113.128/25"), Protocol: aws.String(protocolAll)})
+	data := internetData(source)
+	data.Destinations[0].ID = "203.0.113.0/24"
+	data.Destinations[0].NetworkInterfaces[0].PrivateIP = "203.0.113.0"
+	data.Destinations[0].NetworkInterfaces[0].Cidr = "203.0.113.0/24"
+
+	listOfAnalysis, err := RunAnalysis(data, client, NewPortTraffic(ProtocolTCP, 443))
+
+	assert.Nil(t, err)
+	assert.Len(t, listOfAnalysis, 2)
+	assert.Equal(t, "203.0.113.0", listOfAnalysis[0].DestinationIP)
+	assert.Equal(t, VerdictReachable, listOfAnalysis[0].Verdict())
+	assert.Equal(t, "203.0.113.128", listOfAnalysis[1].DestinationIP)
+	assert.Equal(t, VerdictUnreachable, listOfAnalysis[1].Verdict())
+	assert.Equal(t, VerdictUnreachable, OverallVerdict(listOfAnalysis))
+}
+
+func exposureData(destination scanner.ResourceNetworkMetaData) scanner.AwsData {
+	return scanner.AwsData{
+		Sources: []scanner.ResourceNetworkMetaData{{
+			ID:                "internet",
+			NetworkInterfaces: []scanner.NetworkInterface{{ID: scanner.InternetInterfaceID, PrivateIP: "0.0.0.0", Internet: true}},
+		}},
+		Destinations: []scanner.ResourceNetworkMetaData{destination},
+	}
+}
+
+func TestInternetReachesPublicIPThroughInternetGateway(t *testing.T) {
+	client := &fakeInternetGateways{}
+	exposed := instanceRoutingToInternet(types.Route{GatewayId: aws.String("igw-1")}, "52.1.1.1")
+
+	listOfAnalysis, err := RunAnalysis(exposureData(exposed), client, NewPortTraffic(ProtocolTCP, 443))
+
+	assert.Nil(t, err)
+	analysis := listOfAnalysis[0]
+	assert.True(t, analysis.CanTheyConnect())
+	assert.True(t, analysis.IsFromInternet)
+	assert.Equal(t, "52.1.1.1", analysis.DestinationPublicIP)
+	assert.Equal(t, ReasonSecurityGroupRuleMatch, analysis.CanEnterDestination.ReasonCode)
+}
+
+func TestGroupedInternetIngressReportsPublicIPOfEveryMember(t *testing.T) {
+	client := &fakeInternetGateways{}
+	exposed := instanceRoutingToInternet(types.Route{GatewayId: aws.String("igw-1")}, "52.1.1.1")
+	other := instanceRoutingToInternet(types.Route{GatewayId: aws.String("igw-1")}, "52.2.2.2")
+	other.ID = "i-other"
+	other.NetworkInterfaces[0].ID = "eni-i-other"
+	other.NetworkInterfaces[0].PrivateIP = "10.0.0.6"
+	data := exposureData(exposed)
+	data.Destinations = append(data.Destinations, other)
+
+	listOfAnalysis, err := RunAnalysis(data, client, NewPortTraffic(ProtocolTCP, 443))
+
+	assert.Nil(t, err)
+	assert.Equal(t, listOfAnalysis[0].Group, listOfAnalysis[1].Group)
+	assert.Equal(t, "52.1.1.1", listOfAnalysis[0].DestinationPublicIP)
+	assert.Equal(t, "52.2.2.2", listOfAnalysis[1].DestinationPublicIP)
+	assert.Contains(t, listOfAnalysis[0].CanEnterDestination.Reason, "eni-i-app:")
+	assert.Contains(t, listOfAnalysis[1].CanEnterDestination.Reason, "eni-i-other:")
+	assert.Contains(t, listOfAnalysis[1].InternetGatewayIsReachable.Reason, "public ip 52.2.2.2")
+
+	// egress reason names the public ip the source is translated to
+	data = internetData(exposed)
+	data.Sources = append(data.Sources, other)
+
+	listOfAnalysis, err = RunAnalysis(data, client, NewPortTraffic(ProtocolTCP, 443))
+
+	assert.Nil(t, err)
+	assert.Equal(t, listOfAnalysis[0].Group, listOfAnalysis[1].Group)
+	assert.Contains(t, listOfAnalysis[0].InternetGatewayIsReachable.Reason, "translates public ip 52.1.1.1")
+	assert.Contains(t, listOfAnalysis[1].InternetGatewayIsReachable.Reason, "translates public ip 52.2.2.2")
+}
+
+func TestInternetCantReachInstanceBehindNatGateway(t *testing.T) {
+	client := &fakeInternetGateways{natState: types.NatGatewayStateAvailable}
+	private := instanceRoutingToInternet(types.Route{NatGatewayId: aws.String("nat-1")}, "52.1.1.1")
+	// security group allowing only part of the internet doesnt expose the instance to all of it
+	restricted := instanceRoutingToInternet(types.Route{GatewayId: aws.String("igw-1")}, "52.1.1.1")
+	restricted.NetworkInterfaces[0].SecurityGroups = instanceInSubnet("i-app", "10.0.0.5", "198.51.100.0/24").NetworkInterfaces[0].SecurityGroups
+
+	viaNat, _ := RunAnalysis(exposureData(private), client, NewPortTraffic(ProtocolTCP, 443))
+	viaRestrictedGroup, _ := RunAnalysis(exposureData(restricted), client, NewPortTraffic(ProtocolTCP, 443))
+
+	assert.Equal(t, ReasonRouteNotFound, viaNat[0].InternetGatewayIsReachable.ReasonCode)
+	assert.Equal(t, ReasonSecurityGroupNoRule, viaRestrictedGroup[0].CanEnterDestination.ReasonCode)
+}
+
+func TestCidrSourceIsSplitByNetworkAclEntryInsideIt(t *testing.T) {
+	client := &fakeInternetGateways{}
+	exposed := instanceRoutingToInternet(types.Route{GatewayId: aws.String("igw-1")}, "52.1.1.1")
+	exposed.NetworkInterfaces[0].NetworkAcl.Entries = append(exposed.NetworkInterfaces[0].NetworkAcl.Entries,
+		types.NetworkAclEntry{RuleNumber: 50, Egress: false, RuleAction: types.RuleActionDeny, CidrBlock: aws.String("203.0.113.128/25"), Protocol: aws.String(protocolAll)})
+	data := exposureData(exposed)
+	data.Sources[0].ID = "203.0.113.0/24"
+	data.Sources[0].NetworkInterfaces[0].PrivateIP = "203.0.113.0"
+	data.Sources[0].NetworkInterfaces[0].Cidr = "203.0.113.0/24"
+
+	listOfAnalysis, err := RunAnalysis(data, client, NewPortTraffic(ProtocolTCP, 443))
+
+	assert.Nil(t, err)
+	assert.Len(t, listOfAnalysis, 2)
+	assert.Equal(t, "203.0.113.0", listOfAnalysis[0].SourceIP)
+	assert.Equal(t, VerdictReachable, listOfAnalysis[0].Verdict())
+	assert.Equal(t, "203.0.113.128", listOfAnalysis[1].SourceIP)
+	assert.Equal(t, VerdictUnreachable, listOfAnalysis[1].Verdict())
+	assert.Equal(t, VerdictUnreachable, OverallVerdict(listOfAnalysis))
+}
@@ -0,0 +1,138 @@
+package analyser
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func (f *fakeInternetGateways) DescribeEgressOnlyInternetGateways(ctx context.Context, params *ec2.DescribeEgressOnlyInternetGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeEgressOnlyInternetGatewaysOutput, error) {
+	return &ec2.DescribeEgressOnlyInternetGatewaysOutput{EgressOnlyInternetGateways: []types.EgressOnlyInternetGateway{{
+		EgressOnlyInternetGatewayId: aws.String("eigw-1"),
+		Attachments:                 []types.InternetGatewayAttachment{{VpcId: aws.String("vpc-1"), State: types.AttachmentStatusAttached}},
+	}}}, nil
+}
+
+// dualStackInstance - instance with ipv6 address, ipv6 ingress limited to the cidr and default ipv6 route to the gateway
+func dualStackInstance(id string, ip string, ipv6 string, ingressCidrIpv6 string, defaultRoute types.Route) scanner.ResourceNetworkMetaData {
+	instance := instanceInSubnet(id, ip, "0.0.0.0/0")
+	networkInterface := &instance.NetworkInterfaces[0]
+	networkInterface.IPv6s = []string{ipv6}
+
+	securityGroup := &networkInterface.SecurityGroups[0]
+	securityGroup.IpPermissions = append(securityGroup.IpPermissions, types.IpPermission{
+		IpProtocol: aws.String("tcp"), FromPort: 443, ToPort: 443, Ipv6Ranges: []types.Ipv6Range{{CidrIpv6: aws.String(ingressCidrIpv6)}},
+	})
+	securityGroup.IpPermissionsEgress = append(securityGroup.IpPermissionsEgress, types.IpPermission{
+		IpProtocol: aws.String(protocolAll), Ipv6Ranges: []types.Ipv6Range{{CidrIpv6: aws.String("::/0")}},
+	})
+
+	defaultRoute.DestinationIpv6CidrBlock = aws.String("::/0")
+	defaultRoute.State = types.RouteStateActive
+	networkInterface.RouteTable.Routes = append(networkInterface.RouteTable.Routes,
+		types.Route{DestinationIpv6CidrBlock: aws.String("2600:1f18:1000::/56"), GatewayId: aws.String("local"), State: types.RouteStateActive},
+		defaultRoute)
+	networkInterface.NetworkAcl = types.NetworkAcl{NetworkAclId: aws.String("acl-1"), Entries: []types.NetworkAclEntry{
+		{RuleNumber: 100, Egress: true, RuleAction: types.RuleActionAllow, CidrBlock: aws.String("0.0.0.0/0"), Protocol: aws.String(protocolAll)},
+		{RuleNumber: 100, Egress: false, RuleAction: types.RuleActionAllow, CidrBlock: aws.String("0.0.0.0/0"), Protocol: aws.String(protocolAll)},
+		{RuleNumber: 101, Egress: true, RuleAction: types.RuleActionAllow, Ipv6CidrBlock: aws.String("::/0"), Protocol: aws.String(protocolAll)},
+		{RuleNumber: 101, Egress: false, RuleAction: types.RuleActionAllow, Ipv6CidrBlock: aws.String("::/0"), Protocol: aws.String(protocolAll)},
+	}}
+	return instance
+}
+
+func TestDualStackInterfacesAreAnalysedPerAddressFamily(t *testing.T) {
+	data := scanner.AwsData{
+		Sources:      []scanner.ResourceNetworkMetaData{dualStackInstance("i-app", "10.0.0.5", "2600:1f18:1000:10::5", "::/0", types.Route{EgressOnlyInternetGatewayId: aws.String("eigw-1")})},
+		Destinations: []scanner.ResourceNetworkMetaData{dualStackInstance("i-db", "10.0.0.9", "2600:1f18:1000:10::9", "2600:1f18:1000:20::/64", types.Route{EgressOnlyInternetGatewayId: aws.String("eigw-1")})},
+	}
+
+	listOfAnalysis, err := RunAnalysis(data, nil, NewPortTraffic(ProtocolTCP, 443))
+
+	assert.Nil(t, err)
+	assert.Len(t, listOfAnalysis, 2)
+	assert.Equal(t, FamilyIPv4, listOfAnalysis[0].AddressFamily)
+	assert.True(t, listOfAnalysis[0].CanTheyConnect())
+	assert.Equal(t, FamilyIPv6, listOfAnalysis[1].AddressFamily)
+	assert.Equal(t, "2600:1f18:1000:10::5", listOfAnalysis[1].SourceIP)
+	assert.Equal(t, "2600:1f18:1000:10::9", listOfAnalysis[1].DestinationIP)
+	assert.Contains(t, listOfAnalysis[1].SourceSubnetHasRoute.Reason, "2600:1f18:1000::/56")
+	assert.Equal(t, ReasonSecurityGroupNoRule, listOfAnalysis[1].CanEnterDestination.ReasonCode)
+}
+
+func TestIpv6ReachesInternetThroughEgressOnlyInternetGateway(t *testing.T) {
+	client := &fakeInternetGateways{}
+	source := dualStackInstance("i-app", "10.0.0.5", "2600:1f18:1000:10::5", "::/0", types.Route{EgressOnlyInternetGatewayId: aws.String("eigw-1")})
+	data := scanner.AwsData{
+		Sources: []scanner.ResourceNetworkMetaData{source},
+		Destinations: []scanner.ResourceNetworkMetaData{{
+			ID:                "api.stripe.com",
+			NetworkInterfaces: []scanner.NetworkInterface{{ID: scanner.InternetInterfaceID, IPv6s: []string{"2600:1f14::1"}, Internet: true}},
+		}},
+	}
+
+	listOfAnalysis, err := RunAnalysis(data, client, NewPortTraffic(ProtocolTCP, 443))
+
+	assert.Nil(t, err)
+	assert.Len(t, listOfAnalysis, 1)
+	analysis := listOfAnalysis[0]
+	assert.Equal(t, FamilyIPv6, analysis.AddressFamily)
+	assert.True(t, analysis.CanTheyConnect(), analysis.InternetGatewayIsReachable.Reason)
+	assert.Contains(t, analysis.InternetGatewayIsReachable.Reason, "eigw-1")
+}
+
+func TestInternetCantReachIpv6BehindEgressOnlyInternetGateway(t *testing.T) {
+	client := &fakeInternetGateways{}
+	private := dualStackInstance("i-app", "10.0.0.5", "2600:1f18:1000:10::5", "::/0", types.Route{EgressOnlyInternetGatewayId: aws.String("eigw-1")})
+	public := dualStackInstance("i-web", "10.0.0.6", "2600:1f18:1000:10::6", "::/0", types.Route{GatewayId: aws.String("igw-1")})
+	internet := scanner.ResourceNetworkMetaData{
+		ID:                "internet",
+		NetworkInterfaces: []scanner.NetworkInterface{{ID: scanner.InternetInterfaceID, IPv6s: []string{"::"}, Internet: true}},
+	}
+
+	toPrivate, _ := RunAnalysis(scanner.AwsData{Sources: []scanner.ResourceNetworkMetaData{internet}, Destinations: []scanner.ResourceNetworkMetaData{private}}, client, NewPortTraffic(ProtocolTCP, 443))
+	toPublic, _ := RunAnalysis(scanner.AwsData{Sources: []scanner.ResourceNetworkMetaData{internet}, Destinations: []scanner.ResourceNetworkMetaData{public}}, client, NewPortTraffic(ProtocolTCP, 443))
+
+	assert.Len(t, toPrivate, 1)
+	assert.Equal(t, VerdictUnreachable, toPrivate[0].Verdict())
+	assert.Contains(t, toPrivate[0].InternetGatewayIsReachable.Reason, egressOnlyInternetGatewayNotAccepting)
+	assert.True(t, toPublic[0].CanTheyConnect(), toPublic[0].InternetGatewayIsReachable.Reason)
+	assert.Equal(t, "2600:1f18:1000:10::6", toPublic[0].DestinationPublicIP)
+}
+
+func TestIcmpOfIpv6PairIsCheckedAgainstIcmpv6Rules(t *testing.T) {
+	source := dualStackInstance("i-app", "10.0.0.5", "2600:1f18:1000:10::5", "::/0", types.Route{EgressOnlyInternetGatewayId: aws.String("eigw-1")})
+	icmpv6Only := dualStackInstance("i-db", "10.0.0.9", "2600:1f18:1000:10::9", "::/0", types.Route{EgressOnlyInternetGatewayId: aws.String("eigw-1")})
+	icmpv6Only.NetworkInterfaces[0].SecurityGroups[0].GroupId = aws.String("sg-icmpv6")
+	icmpv6Only.NetworkInterfaces[0].SecurityGroups[0].IpPermissions = []types.IpPermission{
+		{IpProtocol: aws.String("icmpv6"), FromPort: -1, ToPort: -1, Ipv6Ranges: []types.Ipv6Range{{CidrIpv6: aws.String("::/0")}}},
+	}
+	icmpOnly := dualStackInstance("i-cache", "10.0.0.10", "2600:1f18:1000:10::10", "::/0", types.Route{EgressOnlyInternetGatewayId: aws.String("eigw-1")})
+	icmpOnly.NetworkInterfaces[0].SecurityGroups[0].GroupId = aws.String("sg-icmp")
+	icmpOnly.NetworkInterfaces[0].SecurityGroups[0].IpPermissions = []types.IpPermission{
+		{IpProtocol: aws.String("icmp"), FromPort: -1, ToPort: -1,
+			IpRanges: []types.IpRange{{CidrIp: aws.String("0.0.0.0/0")}}, Ipv6Ranges: []types.Ipv6Range{{CidrIpv6: aws.String("::/0")}}},
+	}
+	data := scanner.AwsData{
+		Sources:      []scanner.ResourceNetworkMetaData{source},
+		Destinations: []scanner.ResourceNetworkMetaData{icmpv6Only, icmpOnly},
+	}
+
+	listOfAnalysis, err := RunAnalysis(data, nil, NewIcmpTraffic(8, 0))
+
+	assert.Nil(t, err)
+	assert.Len(t, listOfAnalysis, 4)
+	// ipv4 pairs first as the source is paired family by family
+	assert.Equal(t, FamilyIPv4, listOfAnalysis[0].AddressFamily)
+	assert.False(t, listOfAnalysis[0].CanTheyConnect())
+	assert.True(t, listOfAnalysis[1].CanTheyConnect())
+	assert.Equal(t, FamilyIPv6, listOfAnalysis[2].AddressFamily)
+	assert.True(t, listOfAnalysis[2].CanTheyConnect(), listOfAnalysis[2].CanEnterDestination.Reason)
+	assert.Equal(t, Traffic{Protocol: ProtocolICMPv6, IcmpType: 128}, listOfAnalysis[2].Traffic)
+	assert.False(t, listOfAnalysis[3].CanTheyConnect())
+	assert.Equal(t, ReasonSecurityGroupNoRule, listOfAnalysis[3].CanEnterDestination.ReasonCode)
+}
@@ -0,0 +1,196 @@
+package analyser
+
+import (
+	"fmt"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"net"
+)
+
+// Leg - part of the path to the service behind load balancer the analysis covers
+type Leg string
+
+const (
+	// LegClientToLoadBalancer - client connecting to the listener on load balancer node
+	LegClientToLoadBalancer Leg = "client-to-lb"
+	// LegLoadBalancerToTarget - load balancer node forwarding traffic to the target port
+	LegLoadBalancerToTarget Leg = "lb-to-target"
+	// LegClientToTarget - network load balancer preserving client ip, traffic is routed from the node but the target sees the client
+	LegClientToTarget Leg = "client-to-target"
+	// LegHealthCheck - load balancer node checking health of the target, always sent from the node ip
+	LegHealthCheck Leg = "lb-health-check"
+)
+
+// clientSecurityGroupsReason - why rules pointing at client security groups dont match traffic forwarded by network load balancer
+const clientSecurityGroupsReason = "traffic is forwarded by network load balancer"
+
+// RunLoadBalancerAnalysis - checks if clients (sources) can reach listeners on load balancer nodes (destinations)
+// and if the nodes can reach every target on its traffic and health check port
+// listenerPort limits the first leg to single listener, 0 or less means all of them
+func RunLoadBalancerAnalysis(data scanner.AwsData, client scanner.EC2API, listenerPort int32) ([]Analysis, error) {
+	loadBalancer := data.LoadBalancer
+	if loadBalancer == nil {
+		return nil, fmt.Errorf("destination is not a load balancer")
+	}
+
+	listeners := []scanner.Listener{}
+	for _, listener := range loadBalancer.Listeners {
+		if listenerPort <= 0 || listener.Port == listenerPort {
+			listeners = append(listeners, listener)
+		}
+	}
+	if len(listeners) <= 0 {
+		return nil, fmt.Errorf("lb %s has no listener on port %d", loadBalancer.Name, listenerPort)
+	}
+
+	run := newLoadBalancerRun(client)
+	clients := interfaceMembers(data.Sources)
+	nodes := interfaceMembers(data.Destinations)
+
+	for _, listener := range listeners {
+		traffic := NewPortTraffic(Protocol(listener.Protocol), listener.Port)
+		run.analysePairs(LegClientToLoadBalancer, traffic, clients, nodes)
+	}
+
+	for _, group := range loadBalancer.TargetGroups {
+		for _, target := range group.Targets {
+			targets := interfaceMembers([]scanner.ResourceNetworkMetaData{target.Resource})
+			for _, protocol := range group.Protocols {
+				traffic := NewPortTraffic(Protocol(protocol), target.Port)
+				if group.PreserveClientIP {
+					run.analysePreservedClient(traffic, clients, nodes, targets)
+				} else {
+					run.analysePairs(LegLoadBalancerToTarget, traffic, nodes, targets)
+				}
+			}
+
+			if target.HealthCheckPort > 0 {
+				run.analysePairs(LegHealthCheck, NewPortTraffic(ProtocolTCP, target.HealthCheckPort), nodes, targets)
+			}
+		}
+	}
+
+	for i := range run.listOfAnalysis {
+		run.listOfAnalysis[i].GroupSize = run.groups.sizes[run.listOfAnalysis[i].Group]
+	}
+	return run.listOfAnalysis, nil
+}
+
+// loadBalancerRun - analysis of all legs sharing the equivalence groups, every leg and traffic is a separate scope
+// the same target can be registered in many target groups so pairs already analysed are skipped
+type loadBalancerRun struct {
+	client         scanner.EC2API
+	groups         *equivalenceGroups
+	seen           map[string]bool
+	listOfAnalysis []Analysis
+}
+
+func newLoadBalancerRun(client scanner.EC2API) *loadBalancerRun {
+	return &loadBalancerRun{
+		client:         client,
+		groups:         newEquivalenceGroups(),
+		seen:           map[string]bool{},
+		listOfAnalysis: []Analysis{},
+	}
+}
+
+func (r *loadBalancerRun) isNew(leg Leg, traffic Traffic, source interfaceMember, destination interfaceMember) bool {
+	key := fmt.Sprintf("%s|%s|%s|%s|%s|%s|%s", leg, traffic, source.Family, source.Interface.ID, source.Interface.PrivateIP,
+		destination.Interface.ID, destination.Interface.PrivateIP)
+	if r.seen[key] {
+		return false
+	}
+	r.seen[key] = true
+	return true
+}
+
+func (r *loadBalancerRun) analysePairs(leg Leg, traffic Traffic, sources []interfaceMember, destinations []interfaceMember) {
+	r.groups.scope = fmt.Sprintf("%s|%s", leg, traffic)
+	for _, source := range sources {
+		for _, destination := range destinations {
+			for _, pair := range interfacePairs(source, destination, r.client) {
+				source, destination := pair[0], pair[1]
+				if !r.isNew(leg, traffic, source, destination) {
+					continue
+				}
+				analysis := r.groups.analyse(source, destination, func() *Analysis {
+					analysis := analyseInterfaces(source.Resource, source.Interface, destination.Resource, destination.Interface, r.client, traffic)
+					analysis.Leg = leg
+					return analysis
+				})
+				r.listOfAnalysis = append(r.listOfAnalysis, analysis)
+			}
+		}
+	}
+}
+
+// nodeForTarget - without cross zone load balancing the target receives traffic from the node in its availability zone
+// false if no node has address of the target family
+func nodeForTarget(nodes []interfaceMember, target interfaceMember) (interfaceMember, bool) {
+	candidates := []interfaceMember{}
+	for _, node := range nodes {
+		if sameFamily(node, target) {
+			candidates = append(candidates, node)
+		}
+	}
+	for _, node := range candidates {
+		if node.Interface.AvailabilityZone == target.Interface.AvailabilityZone {
+			return node, true
+		}
+	}
+	if len(candidates) <= 0 {
+		return interfaceMember{}, false
+	}
+	return candidates[0], true
+}
+
+// analysePreservedClient - route and network acl of the node subnet are used to reach the target
+// but security groups and network acl of the target see the client ip, the target also has to route replies back to the client,
+// rules pointing at security groups of the node still match as the traffic is forwarded by it
+func (r *loadBalancerRun) analysePreservedClient(traffic Traffic, clients []interfaceMember, nodes []interfaceMember, targets []interfaceMember) {
+	r.groups.scope = fmt.Sprintf("%s|%s", LegClientToTarget, traffic)
+	for _, target := range targets {
+		node, ok := nodeForTarget(nodes, target)
+		if !ok {
+			continue
+		}
+		for _, client := range preservedClients(clients, target, r.client) {
+			if !r.isNew(LegClientToTarget, traffic, client, target) {
+				continue
+			}
+			analysis := r.groups.analyse(client, target, func() *Analysis {
+				analysis := analyseInterfaces(node.Resource, node.Interface, target.Resource, target.Interface, r.client, traffic)
+				analysis.Leg = LegClientToTarget
+				ipClient := net.ParseIP(client.Interface.PrivateIP)
+
+				canReturn, _, err := lookForRouteOutsideSubnet(target.Interface, ipClient)
+				analysis.DestinationSubnetHasRoute = orUnknown(canReturn, err)
+				analysis.CanEnterDestination = orUnknown(checkIfSecurityGroupsAllowIngress(target.Interface,
+					peerGroups{
+						IDs:                          client.Interface.SecurityGroupIDs(),
+						ReferencesNotSupportedReason: clientSecurityGroupsReason,
+						ForwarderIDs:                 node.Interface.SecurityGroupIDs(),
+					}, traffic, ipClient))
+
+				if !analysis.AreInTheSameSubnet {
+					analysis.DestinationNaclAllowsInbound = orUnknown(checkIfNetworkAclAllowsTraffic(target.Interface.NetworkAcl, false, ipClient, traffic))
+					if returnTraffic, ok := traffic.returnTraffic(); ok {
+						analysis.DestinationNaclAllowsReturn = orUnknown(checkIfNetworkAclAllowsTraffic(target.Interface.NetworkAcl, true, ipClient, returnTraffic))
+					}
+				}
+				return analysis
+			})
+			r.listOfAnalysis = append(r.listOfAnalysis, analysis)
+		}
+	}
+}
+
+// preservedClients - clients of the target family, client range is split by the rules of the target as they see the client ip
+func preservedClients(clients []interfaceMember, target interfaceMember, client scanner.EC2API) []interfaceMember {
+	members := []interfaceMember{}
+	for _, c := range clients {
+		if sameFamily(c, target) {
+			members = append(members, rangeMembers(c, target, client)...)
+		}
+	}
+	return members
+}
@@ -0,0 +1,89 @@
+package analyser
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func networkLoadBalancerData(preserveClientIP bool) scanner.AwsData {
+	node := instanceInSubnet("my-nlb", "10.0.0.20", "0.0.0.0/0")
+	node.NetworkInterfaces[0].SecurityGroups = nil
+	node.NetworkInterfaces[0].WithoutSecurityGroups = true
+
+	return scanner.AwsData{
+		Sources:      []scanner.ResourceNetworkMetaData{instanceInSubnet("i-client", "10.0.0.5", "0.0.0.0/0")},
+		Destinations: []scanner.ResourceNetworkMetaData{node},
+		LoadBalancer: &scanner.LoadBalancer{
+			Name:      "my-nlb",
+			Type:      scanner.LoadBalancerNetwork,
+			Listeners: []scanner.Listener{{Protocol: "tcp", Port: 443}},
+			TargetGroups: []scanner.TargetGroup{{
+				Name:             "targets",
+				Protocols:        []string{"tcp"},
+				PreserveClientIP: preserveClientIP,
+				// target allows only the client, not the load balancer node
+				Targets: []scanner.Target{{Resource: instanceInSubnet("i-target", "10.0.0.30", "10.0.0.5/32"), Port: 443, HealthCheckPort: 443}},
+			}},
+		},
+	}
+}
+
+func TestPreservedClientIPHasToBeAllowedByTarget(t *testing.T) {
+	listOfAnalysis, err := RunLoadBalancerAnalysis(networkLoadBalancerData(true), nil, 0)
+
+	assert.Nil(t, err)
+	assert.Len(t, listOfAnalysis, 3)
+
+	assert.Equal(t, LegClientToLoadBalancer, listOfAnalysis[0].Leg)
+	assert.True(t, listOfAnalysis[0].CanTheyConnect())
+	assert.Equal(t, ReasonNotApplicable, listOfAnalysis[0].CanEnterDestination.ReasonCode)
+
+	assert.Equal(t, LegClientToTarget, listOfAnalysis[1].Leg)
+	assert.Equal(t, "i-client", listOfAnalysis[1].SourceID)
+	assert.True(t, listOfAnalysis[1].CanTheyConnect())
+
+	// health checks are always sent from the node
+	assert.Equal(t, LegHealthCheck, listOfAnalysis[2].Leg)
+	assert.Equal(t, "my-nlb", listOfAnalysis[2].SourceID)
+	assert.False(t, listOfAnalysis[2].CanTheyConnect())
+	assert.NotEqual(t, listOfAnalysis[1].Group, listOfAnalysis[2].Group)
+}
+
+func TestPreservedClientIPTargetCanAllowSecurityGroupOfNode(t *testing.T) {
+	data := networkLoadBalancerData(true)
+	data.Destinations[0].NetworkInterfaces[0].SecurityGroups = []types.SecurityGroup{{
+		GroupId:             aws.String("sg-nlb"),
+		IpPermissions:       []types.IpPermission{{IpProtocol: aws.String(protocolAll), IpRanges: []types.IpRange{{CidrIp: aws.String("0.0.0.0/0")}}}},
+		IpPermissionsEgress: []types.IpPermission{{IpProtocol: aws.String(protocolAll), IpRanges: []types.IpRange{{CidrIp: aws.String("0.0.0.0/0")}}}},
+	}}
+	data.Destinations[0].NetworkInterfaces[0].WithoutSecurityGroups = false
+	target := &data.LoadBalancer.TargetGroups[0].Targets[0].Resource.NetworkInterfaces[0].SecurityGroups[0]
+	target.IpPermissions = []types.IpPermission{{IpProtocol: aws.String("tcp"), FromPort: 443, ToPort: 443,
+		UserIdGroupPairs: []types.UserIdGroupPair{{GroupId: aws.String("sg-nlb")}}}}
+
+	listOfAnalysis, err := RunLoadBalancerAnalysis(data, nil, 443)
+
+	assert.Nil(t, err)
+	assert.Equal(t, LegClientToTarget, listOfAnalysis[1].Leg)
+	assert.True(t, listOfAnalysis[1].CanEnterDestination.IsPassing())
+	assert.True(t, listOfAnalysis[1].CanTheyConnect())
+}
+
+func TestTargetHasToAllowNodeWithoutClientIPPreservation(t *testing.T) {
+	listOfAnalysis, err := RunLoadBalancerAnalysis(networkLoadBalancerData(false), nil, 443)
+
+	assert.Nil(t, err)
+	assert.Len(t, listOfAnalysis, 3)
+	assert.Equal(t, LegLoadBalancerToTarget, listOfAnalysis[1].Leg)
+	assert.Equal(t, "my-nlb", listOfAnalysis[1].SourceID)
+	assert.False(t, listOfAnalysis[1].CanTheyConnect())
+}
+
+func TestLoadBalancerListenerPortHasToExist(t *testing.T) {
+	_, err := RunLoadBalancerAnalysis(networkLoadBalancerData(false), nil, 8080)
+
+	assert.EqualError(t, err, "lb my-nlb has no listener on port 8080")
+}
@@ -0,0 +1,113 @@
+package analyser
+
+import (
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+)
+
+// MatrixCell - result of every pair of instances from the source group to the destination group on every traffic
+type MatrixCell struct {
+	Source      string
+	Destination string
+	Pairs       int
+	Reachable   int
+	Unknown     int
+	// pairs which arent reachable, one per equivalence group as the others have the same checks
+	Failing []Analysis
+}
+
+// Verdict - partial if only some pairs are reachable, cell without pairs is unknown
+func (c *MatrixCell) Verdict() Verdict {
+	switch {
+	case c.Pairs == 0:
+		return VerdictUnknown
+	case c.Reachable == c.Pairs:
+		return VerdictReachable
+	case c.Reachable > 0:
+		return VerdictPartial
+	case c.Unknown > 0:
+		return VerdictUnknown
+	}
+	return VerdictUnreachable
+}
+
+// Matrix - reachability between every pair of groups, Cells[source][destination] follow the order of Groups
+type Matrix struct {
+	Groups  []string
+	Traffic []Traffic
+	Cells   [][]MatrixCell
+}
+
+// Verdict - reachable only if every pair of every cell is reachable, partial cell has unreachable pairs so it makes the matrix unreachable
+// cells without pairs are skipped, matrix without pairs is unknown
+func (m *Matrix) Verdict() Verdict {
+	pairs := 0
+	verdict := VerdictReachable
+	for _, row := range m.Cells {
+		for _, cell := range row {
+			if cell.Pairs == 0 {
+				continue
+			}
+			pairs += cell.Pairs
+			switch cell.Verdict() {
+			case VerdictUnreachable, VerdictPartial:
+				return VerdictUnreachable
+			case VerdictUnknown:
+				verdict = VerdictUnknown
+			}
+		}
+	}
+	if pairs == 0 {
+		return VerdictUnknown
+	}
+	return verdict
+}
+
+// RunMatrixAnalysis - analyses every group pair on every traffic, instance is not paired with itself
+func RunMatrixAnalysis(groups []scanner.ResourceGroup, client scanner.EC2API, traffic []Traffic) (*Matrix, error) {
+	matrix := &Matrix{Groups: []string{}, Traffic: traffic, Cells: [][]MatrixCell{}}
+	for _, group := range groups {
+		matrix.Groups = append(matrix.Groups, group.Name)
+	}
+
+	for _, source := range groups {
+		row := []MatrixCell{}
+		for _, destination := range groups {
+			cell, err := analyseMatrixCell(source, destination, client, traffic)
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, cell)
+		}
+		matrix.Cells = append(matrix.Cells, row)
+	}
+	return matrix, nil
+}
+
+func analyseMatrixCell(source scanner.ResourceGroup, destination scanner.ResourceGroup, client scanner.EC2API, traffic []Traffic) (MatrixCell, error) {
+	cell := MatrixCell{Source: source.Name, Destination: destination.Name, Failing: []Analysis{}}
+	data := scanner.AwsData{Sources: source.Resources, Destinations: destination.Resources}
+	for _, t := range traffic {
+		listOfAnalysis, err := RunAnalysis(data, client, t)
+		if err != nil {
+			return cell, err
+		}
+
+		failing := []Analysis{}
+		for _, a := range listOfAnalysis {
+			if a.SourceID == a.DestinationID {
+				continue
+			}
+			cell.Pairs++
+			switch a.Verdict() {
+			case VerdictReachable:
+				cell.Reachable++
+				continue
+			case VerdictUnknown:
+				cell.Unknown++
+			}
+			failing = append(failing, a)
+		}
+		cell.Failing = append(cell.Failing, GroupRepresentatives(failing)...)
+	}
+	return cell, nil
+}
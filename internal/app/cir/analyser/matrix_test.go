@@ -0,0 +1,71 @@
+package analyser
+
+import (
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMatrixCellsAreReachablePartialOrEmpty(t *testing.T) {
+	groups := []scanner.ResourceGroup{
+		{Name: "api", Resources: []scanner.ResourceNetworkMetaData{
+			instanceInSubnet("i-api-1", "10.0.0.5", "0.0.0.0/0"),
+			instanceInSubnet("i-api-2", "10.0.0.6", "0.0.0.0/0"),
+		}},
+		{Name: "db", Resources: []scanner.ResourceNetworkMetaData{instanceInSubnet("i-db", "10.0.0.9", "10.0.0.5/32")}},
+	}
+
+	matrix, err := RunMatrixAnalysis(groups, nil, []Traffic{NewPortTraffic(ProtocolTCP, 443)})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"api", "db"}, matrix.Groups)
+
+	apiToAPI := matrix.Cells[0][0]
+	assert.Equal(t, 2, apiToAPI.Pairs)
+	assert.Equal(t, VerdictReachable, apiToAPI.Verdict())
+
+	apiToDB := matrix.Cells[0][1]
+	assert.Equal(t, "api", apiToDB.Source)
+	assert.Equal(t, "db", apiToDB.Destination)
+	assert.Equal(t, 1, apiToDB.Reachable)
+	assert.Equal(t, 2, apiToDB.Pairs)
+	assert.Equal(t, VerdictPartial, apiToDB.Verdict())
+	assert.Len(t, apiToDB.Failing, 1)
+	assert.Equal(t, "i-api-2", apiToDB.Failing[0].SourceID)
+	assert.Equal(t, CheckFail, apiToDB.Failing[0].CanEnterDestination.Status)
+
+	assert.Equal(t, VerdictReachable, matrix.Cells[1][0].Verdict())
+
+	// single instance isnt paired with itself
+	assert.Equal(t, 0, matrix.Cells[1][1].Pairs)
+}
+
+func TestMatrixCellCountsEveryTraffic(t *testing.T) {
+	groups := []scanner.ResourceGroup{
+		{Name: "api", Resources: []scanner.ResourceNetworkMetaData{instanceInSubnet("i-api", "10.0.0.5", "0.0.0.0/0")}},
+		{Name: "db", Resources: []scanner.ResourceNetworkMetaData{instanceInSubnet("i-db", "10.0.0.9", "0.0.0.0/0")}},
+	}
+
+	matrix, err := RunMatrixAnalysis(groups, nil, []Traffic{NewPortTraffic(ProtocolTCP, 443), NewPortTraffic(ProtocolTCP, 5432)})
+
+	assert.Nil(t, err)
+	apiToDB := matrix.Cells[0][1]
+	assert.Equal(t, 2, apiToDB.Pairs)
+	assert.Equal(t, 1, apiToDB.Reachable)
+	assert.Equal(t, VerdictPartial, apiToDB.Verdict())
+	assert.Equal(t, int32(5432), apiToDB.Failing[0].Traffic.FromPort)
+	assert.Equal(t, VerdictUnreachable, (&MatrixCell{Pairs: 1}).Verdict())
+	assert.Equal(t, VerdictUnknown, (&MatrixCell{Pairs: 1, Unknown: 1}).Verdict())
+}
+
+func TestMatrixVerdictIsUnreachableIfAnyCellIsNotFullyReachable(t *testing.T) {
+	reachable := MatrixCell{Pairs: 2, Reachable: 2}
+	partial := MatrixCell{Pairs: 2, Reachable: 1}
+	unknown := MatrixCell{Pairs: 1, Unknown: 1}
+	empty := MatrixCell{}
+
+	assert.Equal(t, VerdictReachable, (&Matrix{Cells: [][]MatrixCell{{empty, reachable}, {reachable, empty}}}).Verdict())
+	assert.Equal(t, VerdictUnreachable, (&Matrix{Cells: [][]MatrixCell{{unknown, reachable}, {partial, empty}}}).Verdict())
+	assert.Equal(t, VerdictUnknown, (&Matrix{Cells: [][]MatrixCell{{unknown, reachable}}}).Verdict())
+	assert.Equal(t, VerdictUnknown, (&Matrix{Cells: [][]MatrixCell{{empty}}}).Verdict())
+}
@@ -0,0 +1,144 @@
+package analyser
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"sort"
+	"strings"
+)
+
+// network acls are stateless so the response has to be allowed explicitly, aws recommends opening 1024-65535
+// for return traffic as the client picks the port depending on the OS
+const ephemeralPortFrom int32 = 1024
+const ephemeralPortTo int32 = 65535
+
+// the last rule in every network acl, it denies everything and cannot be modified or removed
+const naclDefaultRuleNumber int32 = 32767
+
+type portRange struct {
+	From int32
+	To   int32
+}
+
+func (p portRange) String() string {
+	if p.From == p.To {
+		return fmt.Sprintf("port %d", p.From)
+	}
+	return fmt.Sprintf("ports %d-%d", p.From, p.To)
+}
+
+// subtract - returns what is left from the list of ranges after removing r
+func subtract(ranges []portRange, r portRange) []portRange {
+	left := []portRange{}
+	for _, p := range ranges {
+		if r.To < p.From || r.From > p.To {
+			left = append(left, p)
+			continue
+		}
+		if p.From < r.From {
+			left = append(left, portRange{p.From, r.From - 1})
+		}
+		if p.To > r.To {
+			left = append(left, portRange{r.To + 1, p.To})
+		}
+	}
+	return left
+}
+
+func overlaps(ranges []portRange, r portRange) bool {
+	for _, p := range ranges {
+		if r.From <= p.To && r.To >= p.From {
+			return true
+		}
+	}
+	return false
+}
+
+func toStringRuleNumber(ruleNumber int32) string {
+	if ruleNumber == naclDefaultRuleNumber {
+		return "*"
+	}
+	return fmt.Sprintf("%d", ruleNumber)
+}
+
+func toStringDirection(egress bool) string {
+	if egress {
+		return "outbound to"
+	}
+	return "inbound from"
+}
+
+// sortedNetworkAclEntries - returns rules for given direction in the order aws evaluates them
+func sortedNetworkAclEntries(networkAcl types.NetworkAcl, egress bool) []types.NetworkAclEntry {
+	entries := []types.NetworkAclEntry{}
+	for _, entry := range networkAcl.Entries {
+		if entry.Egress == egress {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].RuleNumber < entries[j].RuleNumber
+	})
+	return entries
+}
+
+// networkAclEntryCidr - entry matches either ipv4 or ipv6 range
+func networkAclEntryCidr(entry types.NetworkAclEntry) string {
+	if entry.CidrBlock != nil {
+		return *entry.CidrBlock
+	}
+	if entry.Ipv6CidrBlock != nil {
+		return *entry.Ipv6CidrBlock
+	}
+	return ""
+}
+
+// checkIfNetworkAclAllowsTraffic - ipv4 and ipv6 entries share the rule numbers, only entries of the ip family can match it
+func checkIfNetworkAclAllowsTraffic(networkAcl types.NetworkAcl, egress bool, ip net.IP, traffic Traffic) (*Check, error) {
+	log.Debugf("Checking network acl %s - egress: %t\n", *networkAcl.NetworkAclId, egress)
+	notAllowedYet := []portRange{traffic.portRange()}
+	allowingRules := []string{}
+
+	for _, entry := range sortedNetworkAclEntries(networkAcl, egress) {
+		entryPorts, ok := traffic.networkAclEntryRange(entry)
+		if !ok {
+			continue
+		}
+
+		entryCidr := networkAclEntryCidr(entry)
+		if entryCidr == "" {
+			continue
+		}
+
+		cidr, err := parseCIDR(entryCidr)
+		if err != nil {
+			return nil, err
+		}
+
+		if !cidr.Contains(ip) {
+			continue
+		}
+
+		if !overlaps(notAllowedYet, entryPorts) {
+			continue
+		}
+
+		log.Debugf("network acl rule %d matches %s", entry.RuleNumber, ip)
+		if entry.RuleAction == types.RuleActionDeny {
+			return failed(ReasonNaclRuleDeny, fmt.Sprintf("network acl '%s' rule %s denies %s %s on %s",
+				*networkAcl.NetworkAclId, toStringRuleNumber(entry.RuleNumber), toStringDirection(egress), entryCidr, traffic)), nil
+		}
+
+		allowingRules = append(allowingRules, toStringRuleNumber(entry.RuleNumber))
+		notAllowedYet = subtract(notAllowedYet, entryPorts)
+		if len(notAllowedYet) == 0 {
+			return passed(ReasonNaclRuleAllow, fmt.Sprintf("network acl '%s' rule %s allows %s %s on %s",
+				*networkAcl.NetworkAclId, strings.Join(allowingRules, ", "), toStringDirection(egress), ip, traffic)), nil
+		}
+	}
+
+	return failed(ReasonNaclNoRule, fmt.Sprintf("network acl '%s' has no rule allowing %s %s on %s",
+		*networkAcl.NetworkAclId, toStringDirection(egress), ip, traffic)), nil
+}
@@ -0,0 +1,90 @@
+package analyser
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func naclEntry(ruleNumber int32, egress bool, action types.RuleAction, cidr string, from int32, to int32) types.NetworkAclEntry {
+	return types.NetworkAclEntry{
+		RuleNumber: ruleNumber,
+		Egress:     egress,
+		RuleAction: action,
+		CidrBlock:  aws.String(cidr),
+		Protocol:   aws.String("6"),
+		PortRange:  &types.PortRange{From: from, To: to},
+	}
+}
+
+func defaultDenyEntry(egress bool) types.NetworkAclEntry {
+	return types.NetworkAclEntry{
+		RuleNumber: naclDefaultRuleNumber,
+		Egress:     egress,
+		RuleAction: types.RuleActionDeny,
+		CidrBlock:  aws.String("0.0.0.0/0"),
+		Protocol:   aws.String(protocolAll),
+	}
+}
+
+func TestNetworkAclRulesAreEvaluatedInRuleNumberOrder(t *testing.T) {
+	networkAcl := types.NetworkAcl{
+		NetworkAclId: aws.String("acl-1"),
+		Entries: []types.NetworkAclEntry{
+			defaultDenyEntry(false),
+			naclEntry(200, false, types.RuleActionAllow, "10.0.0.0/8", 443, 443),
+			naclEntry(100, false, types.RuleActionDeny, "10.1.0.0/16", 443, 443),
+		},
+	}
+
+	denied, _ := checkIfNetworkAclAllowsTraffic(networkAcl, false, net.ParseIP("10.1.2.3"), NewPortTraffic(ProtocolTCP, 443))
+	assert.False(t, denied.IsPassing())
+	assert.Contains(t, denied.Reason, "rule 100")
+
+	allowed, _ := checkIfNetworkAclAllowsTraffic(networkAcl, false, net.ParseIP("10.2.2.3"), NewPortTraffic(ProtocolTCP, 443))
+	assert.True(t, allowed.IsPassing())
+	assert.Contains(t, allowed.Reason, "rule 200")
+}
+
+func TestNetworkAclDefaultRuleDeniesUnmatchedTraffic(t *testing.T) {
+	networkAcl := types.NetworkAcl{
+		NetworkAclId: aws.String("acl-1"),
+		Entries: []types.NetworkAclEntry{
+			naclEntry(100, true, types.RuleActionAllow, "10.0.0.0/8", 443, 443),
+			defaultDenyEntry(true),
+		},
+	}
+
+	check, _ := checkIfNetworkAclAllowsTraffic(networkAcl, true, net.ParseIP("10.1.2.3"), NewPortTraffic(ProtocolTCP, 80))
+	assert.False(t, check.IsPassing())
+	assert.Contains(t, check.Reason, "rule *")
+}
+
+func TestNetworkAclReturnTrafficNeedsWholeEphemeralRange(t *testing.T) {
+	ephemeralPorts, _ := NewPortTraffic(ProtocolTCP, 443).returnTraffic()
+	partial := types.NetworkAcl{
+		NetworkAclId: aws.String("acl-1"),
+		Entries: []types.NetworkAclEntry{
+			naclEntry(100, true, types.RuleActionAllow, "0.0.0.0/0", 32768, 65535),
+			defaultDenyEntry(true),
+		},
+	}
+
+	check, _ := checkIfNetworkAclAllowsTraffic(partial, true, net.ParseIP("10.1.2.3"), ephemeralPorts)
+	assert.False(t, check.IsPassing())
+
+	split := types.NetworkAcl{
+		NetworkAclId: aws.String("acl-1"),
+		Entries: []types.NetworkAclEntry{
+			naclEntry(100, true, types.RuleActionAllow, "0.0.0.0/0", 32768, 65535),
+			naclEntry(110, true, types.RuleActionAllow, "10.0.0.0/8", 1024, 32767),
+			defaultDenyEntry(true),
+		},
+	}
+
+	check, _ = checkIfNetworkAclAllowsTraffic(split, true, net.ParseIP("10.1.2.3"), ephemeralPorts)
+	assert.True(t, check.IsPassing())
+	assert.Contains(t, check.Reason, "rule 100, 110")
+}
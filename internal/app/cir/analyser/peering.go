@@ -0,0 +1,153 @@
+package analyser
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"net"
+)
+
+// connectionSide - vpc and ip of one side of the connection between vpcs
+type connectionSide struct {
+	VpcID string
+	IP    net.IP
+}
+
+func getVpcPeeringConnection(client scanner.EC2API, vpcPeeringConnectionID string) (*types.VpcPeeringConnection, error) {
+	vpcQuery := &ec2.DescribeVpcPeeringConnectionsInput{
+		VpcPeeringConnectionIds: []string{vpcPeeringConnectionID},
+	}
+
+	vpcs, err := client.DescribeVpcPeeringConnections(context.Background(), vpcQuery)
+	if err != nil {
+		return nil, &APIError{"DescribeVpcPeeringConnections", err}
+	}
+
+	if len(vpcs.VpcPeeringConnections) <= 0 {
+		return nil, nil
+	}
+	return &vpcs.VpcPeeringConnections[0], nil
+}
+
+// peeringCidrBlocks - primary and secondary ipv4 cidrs and ipv6 cidrs of the vpc as seen by the peering
+func peeringCidrBlocks(vpcInfo *types.VpcPeeringConnectionVpcInfo) []string {
+	cidrBlocks := []string{}
+	if vpcInfo.CidrBlock != nil {
+		cidrBlocks = append(cidrBlocks, *vpcInfo.CidrBlock)
+	}
+	for _, cidrBlock := range vpcInfo.CidrBlockSet {
+		if cidrBlock.CidrBlock != nil && !containsString(cidrBlocks, *cidrBlock.CidrBlock) {
+			cidrBlocks = append(cidrBlocks, *cidrBlock.CidrBlock)
+		}
+	}
+	for _, cidrBlock := range vpcInfo.Ipv6CidrBlockSet {
+		if cidrBlock.Ipv6CidrBlock != nil {
+			cidrBlocks = append(cidrBlocks, *cidrBlock.Ipv6CidrBlock)
+		}
+	}
+	return cidrBlocks
+}
+
+func peeringCoversIP(vpcInfo *types.VpcPeeringConnectionVpcInfo, ip net.IP) (bool, error) {
+	for _, cidrBlock := range peeringCidrBlocks(vpcInfo) {
+		cidr, err := parseCIDR(cidrBlock)
+		if err != nil {
+			return false, err
+		}
+		if cidr.Contains(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func isInterRegionPeering(peering types.VpcPeeringConnection) bool {
+	if peering.RequesterVpcInfo == nil || peering.AccepterVpcInfo == nil {
+		return false
+	}
+	requesterRegion := peering.RequesterVpcInfo.Region
+	accepterRegion := peering.AccepterVpcInfo.Region
+	return requesterRegion != nil && accepterRegion != nil && *requesterRegion != *accepterRegion
+}
+
+func toStringPeeringSide(vpcInfo *types.VpcPeeringConnectionVpcInfo) string {
+	description := *vpcInfo.VpcId
+	if vpcInfo.OwnerId != nil {
+		description = fmt.Sprintf("%s account %s", description, *vpcInfo.OwnerId)
+	}
+	if vpcInfo.Region != nil {
+		description = fmt.Sprintf("%s region %s", description, *vpcInfo.Region)
+	}
+	return description
+}
+
+// checkVpcPeering - peering has to be active, join both vpcs and its cidrs have to cover both ips
+func checkVpcPeering(vpcPeeringConnectionID string, peering *types.VpcPeeringConnection, source connectionSide, destination connectionSide) (*Check, error) {
+	if peering == nil {
+		return failed(ReasonVpcPeeringInactive, fmt.Sprintf("vpc peering - %s - not found", vpcPeeringConnectionID)), nil
+	}
+
+	if peering.Status == nil || peering.Status.Code != types.VpcPeeringConnectionStateReasonCodeActive {
+		return failed(ReasonVpcPeeringInactive, fmt.Sprintf("vpc peering - %s - is inactive", vpcPeeringConnectionID)), nil
+	}
+
+	if peering.RequesterVpcInfo == nil || peering.AccepterVpcInfo == nil {
+		return failed(ReasonVpcPeeringWrongVpcs, fmt.Sprintf("vpc peering - %s - is missing requester or accepter vpc", vpcPeeringConnectionID)), nil
+	}
+
+	sourceInfo, destinationInfo := peering.RequesterVpcInfo, peering.AccepterVpcInfo
+	if *sourceInfo.VpcId != source.VpcID {
+		sourceInfo, destinationInfo = destinationInfo, sourceInfo
+	}
+
+	if *sourceInfo.VpcId != source.VpcID || *destinationInfo.VpcId != destination.VpcID {
+		return failed(ReasonVpcPeeringWrongVpcs, fmt.Sprintf("vpc peering - %s - joins %s and %s not %s and %s", vpcPeeringConnectionID,
+			*peering.RequesterVpcInfo.VpcId, *peering.AccepterVpcInfo.VpcId, source.VpcID, destination.VpcID)), nil
+	}
+
+	covered, err := peeringCoversIP(sourceInfo, source.IP)
+	if err != nil {
+		return nil, err
+	}
+	if !covered {
+		return failed(ReasonVpcPeeringCidrNotCovered, fmt.Sprintf("vpc peering - %s - cidrs %v of %s dont cover source ip %s",
+			vpcPeeringConnectionID, peeringCidrBlocks(sourceInfo), source.VpcID, source.IP)), nil
+	}
+
+	covered, err = peeringCoversIP(destinationInfo, destination.IP)
+	if err != nil {
+		return nil, err
+	}
+	if !covered {
+		return failed(ReasonVpcPeeringCidrNotCovered, fmt.Sprintf("vpc peering - %s - cidrs %v of %s dont cover destination ip %s",
+			vpcPeeringConnectionID, peeringCidrBlocks(destinationInfo), destination.VpcID, destination.IP)), nil
+	}
+
+	peeringType := "vpc peering"
+	if isInterRegionPeering(*peering) {
+		peeringType = "inter-region vpc peering"
+	}
+
+	return passed(ReasonVpcPeeringActive, fmt.Sprintf("%s - %s - is active between %s and %s", peeringType, vpcPeeringConnectionID,
+		toStringPeeringSide(sourceInfo), toStringPeeringSide(destinationInfo))), nil
+}
+
+// groupReferencesNotSupportedReason - rules can point at security groups only in the same vpc
+// or in the vpc peered in the same region, returns empty string if references are supported
+func groupReferencesNotSupportedReason(areInTheSameVpc bool, peering *types.VpcPeeringConnection) string {
+	if areInTheSameVpc {
+		return ""
+	}
+
+	if peering == nil {
+		return "security group references work only in the same vpc or over vpc peering"
+	}
+
+	if isInterRegionPeering(*peering) {
+		return "security group references dont work over inter-region vpc peering"
+	}
+
+	return ""
+}
@@ -0,0 +1,89 @@
+package analyser
+
+import (
+	"sort"
+)
+
+// portSet - sorted port ranges, overlapping and adjacent ranges are merged
+type portSet []portRange
+
+func newPortSet(ranges ...portRange) portSet {
+	sorted := append([]portRange{}, ranges...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].From < sorted[j].From
+	})
+
+	set := portSet{}
+	for _, r := range sorted {
+		if r.From > r.To {
+			continue
+		}
+		last := len(set) - 1
+		if last >= 0 && r.From <= set[last].To+1 {
+			if r.To > set[last].To {
+				set[last].To = r.To
+			}
+			continue
+		}
+		set = append(set, r)
+	}
+	return set
+}
+
+func (s portSet) intersect(other portSet) portSet {
+	ranges := []portRange{}
+	for _, a := range s {
+		for _, b := range other {
+			from, to := a.From, a.To
+			if b.From > from {
+				from = b.From
+			}
+			if b.To < to {
+				to = b.To
+			}
+			if from <= to {
+				ranges = append(ranges, portRange{from, to})
+			}
+		}
+	}
+	return newPortSet(ranges...)
+}
+
+func (s portSet) subtract(other portSet) portSet {
+	left := []portRange(s)
+	for _, r := range other {
+		left = subtract(left, r)
+	}
+	return newPortSet(left...)
+}
+
+// elementaryRanges - splits the range at every boundary so each rule covers the whole piece or none of it
+func elementaryRanges(whole portRange, boundaries []portRange) []portRange {
+	cuts := map[int32]bool{whole.From: true}
+	for _, b := range boundaries {
+		if b.From > whole.From && b.From <= whole.To {
+			cuts[b.From] = true
+		}
+		if b.To >= whole.From && b.To < whole.To {
+			cuts[b.To+1] = true
+		}
+	}
+
+	starts := []int32{}
+	for cut := range cuts {
+		starts = append(starts, cut)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i] < starts[j]
+	})
+
+	ranges := []portRange{}
+	for i, start := range starts {
+		end := whole.To
+		if i+1 < len(starts) {
+			end = starts[i+1] - 1
+		}
+		ranges = append(ranges, portRange{start, end})
+	}
+	return ranges
+}
@@ -0,0 +1,27 @@
+package analyser
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPortSetMergesOverlappingAndAdjacentRanges(t *testing.T) {
+	set := newPortSet(portRange{8000, 8100}, portRange{443, 443}, portRange{8101, 8200}, portRange{8050, 8060}, portRange{10, 5})
+
+	assert.Equal(t, portSet{{443, 443}, {8000, 8200}}, set)
+}
+
+func TestPortSetIntersectAndSubtract(t *testing.T) {
+	allowed := newPortSet(portRange{0, 1000}, portRange{8000, 8100})
+	opened := newPortSet(portRange{443, 443}, portRange{900, 8050})
+
+	assert.Equal(t, portSet{{443, 443}, {900, 1000}, {8000, 8050}}, allowed.intersect(opened))
+	assert.Equal(t, portSet{{0, 442}, {444, 899}, {8051, 8100}}, allowed.subtract(opened))
+	assert.Len(t, allowed.intersect(portSet{}), 0)
+}
+
+func TestElementaryRangesSplitAtEveryBoundary(t *testing.T) {
+	pieces := elementaryRanges(allPorts, []portRange{{443, 443}, {8000, 8100}, {8050, 65535}, {-1, -1}})
+
+	assert.Equal(t, []portRange{{0, 442}, {443, 443}, {444, 7999}, {8000, 8049}, {8050, 8100}, {8101, 65535}}, pieces)
+}
@@ -0,0 +1,62 @@
+package analyser
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"net"
+)
+
+// prefixListEntryContaining - the most specific entry of the prefix list containing the ip, nil if none contains it
+// returns false if the prefix list wasnt loaded
+func prefixListEntryContaining(prefixLists scanner.PrefixLists, prefixListID string, ip net.IP) (*types.PrefixListEntry, bool, error) {
+	entries, ok := prefixLists.Entries(prefixListID)
+	if !ok {
+		return nil, false, nil
+	}
+
+	var selected *types.PrefixListEntry
+	selectedPrefixLength := -1
+	for i, entry := range entries {
+		if entry.Cidr == nil {
+			continue
+		}
+		cidr, err := parseCIDR(*entry.Cidr)
+		if err != nil {
+			return nil, true, err
+		}
+		prefixLength, _ := cidr.Mask.Size()
+		if cidr.Contains(ip) && prefixLength > selectedPrefixLength {
+			selected = &entries[i]
+			selectedPrefixLength = prefixLength
+		}
+	}
+	return selected, true, nil
+}
+
+func toStringPrefixListEntry(prefixListID string, entry types.PrefixListEntry) string {
+	if entry.Description != nil && *entry.Description != "" {
+		return fmt.Sprintf("prefix list %s entry %s (%s)", prefixListID, *entry.Cidr, *entry.Description)
+	}
+	return fmt.Sprintf("prefix list %s entry %s", prefixListID, *entry.Cidr)
+}
+
+func toStringPrefixListNotLoaded(prefixListID string) string {
+	return fmt.Sprintf("prefix list %s couldnt be loaded", prefixListID)
+}
+
+// getRoutePrefixLists - entries of prefix lists used by route table which wasnt loaded by the scan eg. nat gateway subnet
+func getRoutePrefixLists(client scanner.EC2API, routeTable types.RouteTable) (scanner.PrefixLists, error) {
+	prefixLists := scanner.PrefixLists{}
+	for _, route := range routeTable.Routes {
+		if route.DestinationPrefixListId == nil {
+			continue
+		}
+		entries, err := scanner.GetManagedPrefixListEntries(client, *route.DestinationPrefixListId)
+		if err != nil {
+			return nil, &APIError{"GetManagedPrefixListEntries", err}
+		}
+		prefixLists[*route.DestinationPrefixListId] = entries
+	}
+	return prefixLists, nil
+}
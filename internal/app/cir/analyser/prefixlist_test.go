@@ -0,0 +1,89 @@
+package analyser
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+var officePrefixLists = scanner.PrefixLists{
+	"pl-office": {
+		{Cidr: aws.String("192.168.0.0/16"), Description: aws.String("london office")},
+		{Cidr: aws.String("172.16.0.0/12")},
+	},
+}
+
+func TestSecurityGroupRuleMatchesPrefixListEntry(t *testing.T) {
+	securityGroup := securityGroupWithIngress("sg-office", types.IpPermission{
+		IpProtocol: aws.String("tcp"), FromPort: 22, ToPort: 22,
+		PrefixListIds: []types.PrefixListId{{PrefixListId: aws.String("pl-office")}},
+	})
+	traffic := NewPortTraffic(ProtocolTCP, 22)
+
+	check, err := checkIfSecurityGroupAllowsIngressForIPandPort(securityGroup, []string{}, officePrefixLists, traffic, net.ParseIP("192.168.4.1"))
+	assert.NoError(t, err)
+	assert.True(t, check.IsPassing())
+	assert.Contains(t, check.Reason, "prefix list pl-office entry 192.168.0.0/16 (london office)")
+
+	check, _ = checkIfSecurityGroupAllowsIngressForIPandPort(securityGroup, []string{}, officePrefixLists, traffic, net.ParseIP("10.0.0.1"))
+	assert.Equal(t, CheckFail, check.Status)
+
+	check, _ = checkIfSecurityGroupAllowsIngressForIPandPort(securityGroup, []string{}, scanner.PrefixLists{}, traffic, net.ParseIP("192.168.4.1"))
+	assert.Equal(t, CheckUnknown, check.Status)
+	assert.Contains(t, check.Reason, "pl-office")
+}
+
+func TestPrefixListRouteIsMatchedByItsEntries(t *testing.T) {
+	networkInterface := scanner.NetworkInterface{
+		SubnetID: "subnet-1",
+		RouteTable: types.RouteTable{
+			RouteTableId: aws.String("rtb-1"),
+			Routes: []types.Route{
+				{DestinationCidrBlock: aws.String("0.0.0.0/0"), NatGatewayId: aws.String("nat-1"), State: types.RouteStateActive},
+				{DestinationPrefixListId: aws.String("pl-office"), TransitGatewayId: aws.String("tgw-1"), State: types.RouteStateActive},
+				{DestinationCidrBlock: aws.String("192.168.10.0/24"), VpcPeeringConnectionId: aws.String("pcx-1"), State: types.RouteStateActive},
+			},
+		},
+		PrefixLists: officePrefixLists,
+	}
+
+	check, route, err := lookForRouteOutsideSubnet(networkInterface, net.ParseIP("192.168.4.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "tgw-1", *route.TransitGatewayId)
+	assert.Contains(t, check.Reason, "prefix list pl-office entry 192.168.0.0/16")
+
+	_, route, _ = lookForRouteOutsideSubnet(networkInterface, net.ParseIP("192.168.10.1"))
+	assert.Equal(t, "pcx-1", *route.VpcPeeringConnectionId)
+
+	networkInterface.PrefixLists = scanner.PrefixLists{}
+	_, _, err = lookForRouteOutsideSubnet(networkInterface, net.ParseIP("10.0.0.1"))
+	assert.Equal(t, ReasonInvalidData, orUnknown(nil, err).ReasonCode)
+}
+
+func TestPrefixListWhichWasntLoadedIsSkippedIfItCantBeTheLongestMatch(t *testing.T) {
+	networkInterface := scanner.NetworkInterface{
+		SubnetID: "subnet-1",
+		RouteTable: types.RouteTable{
+			RouteTableId: aws.String("rtb-1"),
+			Routes: []types.Route{
+				{DestinationCidrBlock: aws.String("10.0.0.0/16"), GatewayId: aws.String(localGatewayID), State: types.RouteStateActive},
+				{DestinationPrefixListId: aws.String("pl-office"), TransitGatewayId: aws.String("tgw-1"), State: types.RouteStateActive},
+				{DestinationCidrBlock: aws.String("0.0.0.0/0"), NatGatewayId: aws.String("nat-1"), State: types.RouteStateActive},
+			},
+		},
+		PrefixLists: scanner.PrefixLists{},
+	}
+
+	// local route cant be overridden by the prefix list
+	check, route, err := lookForRouteOutsideSubnet(networkInterface, net.ParseIP("10.0.0.9"))
+	assert.NoError(t, err)
+	assert.Equal(t, localGatewayID, *route.GatewayId)
+	assert.True(t, check.IsPassing())
+
+	// prefix list could have entry more specific than the default route
+	_, _, err = lookForRouteOutsideSubnet(networkInterface, net.ParseIP("192.168.4.1"))
+	assert.Equal(t, ReasonInvalidData, orUnknown(nil, err).ReasonCode)
+}
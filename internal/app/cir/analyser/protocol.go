@@ -0,0 +1,187 @@
+package analyser
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"strings"
+)
+
+// Protocol - ip protocol of the traffic being checked
+type Protocol string
+
+const (
+	// ProtocolTCP - tcp traffic
+	ProtocolTCP Protocol = "tcp"
+	// ProtocolUDP - udp traffic
+	ProtocolUDP Protocol = "udp"
+	// ProtocolICMP - icmp traffic, ports are replaced with icmp type and code
+	ProtocolICMP Protocol = "icmp"
+	// ProtocolICMPv6 - icmp traffic of ipv6 pair, aws applies icmp rules only to ipv4
+	ProtocolICMPv6 Protocol = "icmpv6"
+)
+
+// in both security groups and network acls -1 means all protocols and all ports
+const protocolAll = "-1"
+
+const icmpTypeEchoReply int32 = 0
+const icmpTypeEchoRequest int32 = 8
+const icmpv6TypeEchoRequest int32 = 128
+const icmpv6TypeEchoReply int32 = 129
+
+// ParseProtocol - converts protocol name given by the user to Protocol
+func ParseProtocol(protocol string) (Protocol, error) {
+	switch Protocol(strings.ToLower(protocol)) {
+	case ProtocolTCP:
+		return ProtocolTCP, nil
+	case ProtocolUDP:
+		return ProtocolUDP, nil
+	case ProtocolICMP:
+		return ProtocolICMP, nil
+	}
+	return "", fmt.Errorf("protocol '%s' is not supported - use tcp, udp or icmp", protocol)
+}
+
+// number - iana protocol number, network acls and some security group rules use it instead of the name
+func (p Protocol) number() string {
+	switch p {
+	case ProtocolTCP:
+		return "6"
+	case ProtocolUDP:
+		return "17"
+	case ProtocolICMP:
+		return "1"
+	case ProtocolICMPv6:
+		return "58"
+	}
+	return ""
+}
+
+// IsICMP - icmp of both families has types and codes instead of ports
+func (p Protocol) IsICMP() bool {
+	return p == ProtocolICMP || p == ProtocolICMPv6
+}
+
+// matches - checks if protocol used in aws rule (name or number) is this protocol
+func (p Protocol) matches(ruleProtocol string) bool {
+	return ruleProtocol == protocolAll || strings.EqualFold(ruleProtocol, string(p)) || ruleProtocol == p.number()
+}
+
+// Traffic - describes the traffic sent from source to destination
+type Traffic struct {
+	Protocol Protocol
+	FromPort int32
+	ToPort   int32
+	IcmpType int32
+	IcmpCode int32
+}
+
+// NewPortTraffic - tcp or udp traffic to single port
+func NewPortTraffic(protocol Protocol, port int32) Traffic {
+	return Traffic{Protocol: protocol, FromPort: port, ToPort: port}
+}
+
+// NewIcmpTraffic - icmp traffic with given type and code eg. 8/0 for ping
+func NewIcmpTraffic(icmpType int32, icmpCode int32) Traffic {
+	return Traffic{Protocol: ProtocolICMP, IcmpType: icmpType, IcmpCode: icmpCode}
+}
+
+// forFamily - icmp of ipv6 pair is checked as icmpv6, echo request and reply are replaced with their icmpv6 types
+// other icmp types are kept as given
+func (t Traffic) forFamily(family AddressFamily) Traffic {
+	if t.Protocol != ProtocolICMP || family != FamilyIPv6 {
+		return t
+	}
+	t.Protocol = ProtocolICMPv6
+	switch t.IcmpType {
+	case icmpTypeEchoRequest:
+		t.IcmpType = icmpv6TypeEchoRequest
+	case icmpTypeEchoReply:
+		t.IcmpType = icmpv6TypeEchoReply
+	}
+	return t
+}
+
+func (t Traffic) String() string {
+	if t.Protocol.IsICMP() {
+		return fmt.Sprintf("%s type %d code %d", t.Protocol, t.IcmpType, t.IcmpCode)
+	}
+	return fmt.Sprintf("%s %s", t.Protocol, t.portRange())
+}
+
+// portRange - for icmp there are no ports so single value range is used to represent type and code
+func (t Traffic) portRange() portRange {
+	if t.Protocol.IsICMP() {
+		return portRange{0, 0}
+	}
+	return portRange{t.FromPort, t.ToPort}
+}
+
+// returnTraffic - traffic which the destination sends back, needed for stateless network acls
+// returns false if no response is expected
+func (t Traffic) returnTraffic() (Traffic, bool) {
+	if t.Protocol == ProtocolICMPv6 {
+		if t.IcmpType == icmpv6TypeEchoRequest {
+			return Traffic{Protocol: ProtocolICMPv6, IcmpType: icmpv6TypeEchoReply}, true
+		}
+		return Traffic{}, false
+	}
+	if t.Protocol == ProtocolICMP {
+		if t.IcmpType == icmpTypeEchoRequest {
+			return NewIcmpTraffic(icmpTypeEchoReply, 0), true
+		}
+		return Traffic{}, false
+	}
+	return Traffic{Protocol: t.Protocol, FromPort: ephemeralPortFrom, ToPort: ephemeralPortTo}, true
+}
+
+func icmpMatches(ruleValue int32, value int32) bool {
+	return ruleValue == -1 || ruleValue == value
+}
+
+// matchesIPPermission - checks if security group rule covers the protocol and port (or icmp type and code)
+func (t Traffic) matchesIPPermission(permission types.IpPermission) bool {
+	if permission.IpProtocol == nil {
+		return false
+	}
+
+	if *permission.IpProtocol == protocolAll {
+		return true
+	}
+
+	if !t.Protocol.matches(*permission.IpProtocol) {
+		return false
+	}
+
+	// for icmp rules FromPort is the icmp type and ToPort is the icmp code
+	if t.Protocol.IsICMP() {
+		return icmpMatches(permission.FromPort, t.IcmpType) && icmpMatches(permission.ToPort, t.IcmpCode)
+	}
+
+	return t.FromPort >= permission.FromPort && t.ToPort <= permission.ToPort
+}
+
+// networkAclEntryRange - returns the part of traffic covered by network acl rule, false if the rule doesnt apply
+func (t Traffic) networkAclEntryRange(entry types.NetworkAclEntry) (portRange, bool) {
+	if entry.Protocol == nil || !t.Protocol.matches(*entry.Protocol) {
+		return portRange{}, false
+	}
+
+	if *entry.Protocol == protocolAll {
+		return t.portRange(), true
+	}
+
+	if t.Protocol.IsICMP() {
+		if entry.IcmpTypeCode == nil {
+			return t.portRange(), true
+		}
+		if icmpMatches(entry.IcmpTypeCode.Type, t.IcmpType) && icmpMatches(entry.IcmpTypeCode.Code, t.IcmpCode) {
+			return t.portRange(), true
+		}
+		return portRange{}, false
+	}
+
+	if entry.PortRange == nil {
+		return portRange{0, 65535}, true
+	}
+	return portRange{entry.PortRange.From, entry.PortRange.To}, true
+}
@@ -0,0 +1,56 @@
+package analyser
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSecurityGroupRuleMatchesOnlyItsProtocol(t *testing.T) {
+	udpDNS := types.IpPermission{IpProtocol: aws.String("udp"), FromPort: 53, ToPort: 53}
+
+	assert.True(t, NewPortTraffic(ProtocolUDP, 53).matchesIPPermission(udpDNS))
+	assert.False(t, NewPortTraffic(ProtocolTCP, 53).matchesIPPermission(udpDNS))
+}
+
+func TestSecurityGroupAllTrafficRuleMatchesEverything(t *testing.T) {
+	allTraffic := types.IpPermission{IpProtocol: aws.String(protocolAll), FromPort: -1, ToPort: -1}
+
+	assert.True(t, NewPortTraffic(ProtocolTCP, 443).matchesIPPermission(allTraffic))
+	assert.True(t, NewPortTraffic(ProtocolUDP, 53).matchesIPPermission(allTraffic))
+	assert.True(t, NewIcmpTraffic(8, 0).matchesIPPermission(allTraffic))
+}
+
+func TestSecurityGroupIcmpRuleMatchesTypeAndCode(t *testing.T) {
+	echoRequest := types.IpPermission{IpProtocol: aws.String("icmp"), FromPort: 8, ToPort: -1}
+	allIcmp := types.IpPermission{IpProtocol: aws.String("icmp"), FromPort: -1, ToPort: -1}
+
+	assert.True(t, NewIcmpTraffic(8, 0).matchesIPPermission(echoRequest))
+	assert.False(t, NewIcmpTraffic(3, 4).matchesIPPermission(echoRequest))
+	assert.True(t, NewIcmpTraffic(3, 4).matchesIPPermission(allIcmp))
+	assert.False(t, NewPortTraffic(ProtocolTCP, 8).matchesIPPermission(echoRequest))
+}
+
+func TestNetworkAclMatchesProtocolNumber(t *testing.T) {
+	udpEntry := types.NetworkAclEntry{Protocol: aws.String("17"), PortRange: &types.PortRange{From: 53, To: 53}}
+
+	_, ok := NewPortTraffic(ProtocolUDP, 53).networkAclEntryRange(udpEntry)
+	assert.True(t, ok)
+	_, ok = NewPortTraffic(ProtocolTCP, 53).networkAclEntryRange(udpEntry)
+	assert.False(t, ok)
+}
+
+func TestIcmpOfIpv6IsIcmpv6WithItsEchoTypes(t *testing.T) {
+	ping := NewIcmpTraffic(8, 0).forFamily(FamilyIPv6)
+	icmpv6Entry := types.NetworkAclEntry{Protocol: aws.String("58"), IcmpTypeCode: &types.IcmpTypeCode{Type: 128, Code: -1}}
+
+	assert.Equal(t, NewIcmpTraffic(8, 0), NewIcmpTraffic(8, 0).forFamily(FamilyIPv4))
+	_, ok := ping.networkAclEntryRange(icmpv6Entry)
+	assert.True(t, ok)
+	_, ok = NewIcmpTraffic(8, 0).networkAclEntryRange(icmpv6Entry)
+	assert.False(t, ok)
+	reply, ok := ping.returnTraffic()
+	assert.True(t, ok)
+	assert.Equal(t, Traffic{Protocol: ProtocolICMPv6, IcmpType: 129}, reply)
+}
@@ -0,0 +1,144 @@
+package analyser
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	log "github.com/sirupsen/logrus"
+	"net"
+)
+
+// every vpc route table has a route for the vpc cidr pointing at this gateway
+const localGatewayID = "local"
+
+func toStringRouteTable(networkInterface scanner.NetworkInterface) string {
+	if networkInterface.RouteTableIsMain {
+		return fmt.Sprintf("main route table '%s' (subnet %s has no explicit association)", *networkInterface.RouteTable.RouteTableId, networkInterface.SubnetID)
+	}
+	return fmt.Sprintf("route table '%s' (explicitly associated with subnet %s)", *networkInterface.RouteTable.RouteTableId, networkInterface.SubnetID)
+}
+
+func isLocalRoute(route types.Route) bool {
+	return route.GatewayId != nil && *route.GatewayId == localGatewayID
+}
+
+func toStringRouteTarget(route types.Route) string {
+	targets := []*string{
+		route.GatewayId,
+		route.TransitGatewayId,
+		route.VpcPeeringConnectionId,
+		route.NatGatewayId,
+		route.EgressOnlyInternetGatewayId,
+		route.NetworkInterfaceId,
+		route.InstanceId,
+		route.LocalGatewayId,
+		route.CarrierGatewayId,
+	}
+	for _, target := range targets {
+		if target != nil {
+			return *target
+		}
+	}
+	return "unknown target"
+}
+
+// routeCidr - ipv4 or ipv6 range of the route, empty for prefix list routes as they are matched by their entries
+func routeCidr(route types.Route) string {
+	if route.DestinationCidrBlock != nil {
+		return *route.DestinationCidrBlock
+	}
+	if route.DestinationIpv6CidrBlock != nil {
+		return *route.DestinationIpv6CidrBlock
+	}
+	return ""
+}
+
+// routeMatch - route selected for the destination with the range which matched it
+type routeMatch struct {
+	Route types.Route
+	// cidr of the route or entry of its prefix list
+	Destination string
+}
+
+// selectRoute - aws picks the most specific route matching the destination (longest prefix match)
+// prefix list route is matched by its entries, returns false if there is no route matching
+// prefix list which wasnt loaded is an error only if its entries could be more specific than the selected route,
+// local route cant be overridden by prefix list route and route to single address is the most specific one
+func selectRoute(routeTable types.RouteTable, prefixLists scanner.PrefixLists, ipDestination net.IP) (routeMatch, bool, error) {
+	var selected routeMatch
+	selectedPrefixLength := -1
+	notLoaded := []string{}
+	for _, r := range routeTable.Routes {
+		if r.DestinationPrefixListId != nil {
+			entry, loaded, err := prefixListEntryContaining(prefixLists, *r.DestinationPrefixListId, ipDestination)
+			if err != nil {
+				return routeMatch{}, false, err
+			}
+			if !loaded {
+				notLoaded = append(notLoaded, *r.DestinationPrefixListId)
+				continue
+			}
+			if entry == nil {
+				continue
+			}
+			cidr, _ := parseCIDR(*entry.Cidr)
+			if prefixLength, _ := cidr.Mask.Size(); prefixLength > selectedPrefixLength {
+				selected = routeMatch{r, toStringPrefixListEntry(*r.DestinationPrefixListId, *entry)}
+				selectedPrefixLength = prefixLength
+			}
+			continue
+		}
+
+		destination := routeCidr(r)
+		if destination == "" {
+			continue
+		}
+
+		cidr, err := parseCIDR(destination)
+		if err != nil {
+			return routeMatch{}, false, err
+		}
+
+		// ipv4 ranges dont contain ipv6 addresses and vice versa
+		if !cidr.Contains(ipDestination) {
+			continue
+		}
+
+		prefixLength, _ := cidr.Mask.Size()
+		if prefixLength > selectedPrefixLength {
+			selected = routeMatch{r, destination}
+			selectedPrefixLength = prefixLength
+		}
+	}
+
+	addressLength := net.IPv4len * 8
+	if addressFamily(ipDestination) == FamilyIPv6 {
+		addressLength = net.IPv6len * 8
+	}
+	if len(notLoaded) > 0 && !isLocalRoute(selected.Route) && selectedPrefixLength < addressLength {
+		return routeMatch{}, false, &InvalidDataError{notLoaded[0], fmt.Errorf("prefix list used by route couldnt be loaded")}
+	}
+	return selected, selectedPrefixLength >= 0, nil
+}
+
+func lookForRouteOutsideSubnet(networkInterface scanner.NetworkInterface, ipDestination net.IP) (*Check, types.Route, error) {
+	log.Debug("Checking subnet routing table")
+	match, found, err := selectRoute(networkInterface.RouteTable, networkInterface.PrefixLists, ipDestination)
+	if err != nil {
+		return nil, types.Route{}, err
+	}
+	route := match.Route
+
+	if !found {
+		return failed(ReasonRouteNotFound, fmt.Sprintf("found no route in %s allowing traffic", toStringRouteTable(networkInterface))), types.Route{}, nil
+	}
+
+	// route stays in the table after its target (eg. peering or nat) is deleted and drops all the traffic
+	if route.State == types.RouteStateBlackhole {
+		return failed(ReasonRouteBlackhole, fmt.Sprintf("route in %s with range '%s' to '%s' is a blackhole - traffic is dropped",
+			toStringRouteTable(networkInterface), match.Destination, toStringRouteTarget(route))), route, nil
+	}
+
+	return passed(ReasonRouteFound, fmt.Sprintf("found route in %s with range '%s' to '%s'",
+		toStringRouteTable(networkInterface), match.Destination, toStringRouteTarget(route))), route, nil
+}
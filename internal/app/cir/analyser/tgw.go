@@ -0,0 +1,194 @@
+package analyser
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	log "github.com/sirupsen/logrus"
+	"net"
+)
+
+// transitGatewayPath - side of the transit gateway traffic enters from and the ip it is sent to
+type transitGatewayPath struct {
+	TransitGatewayID string
+	FromVpcID        string
+	// transit gateway can only be reached from availability zones where the attachment has a subnet
+	FromAvailabilityZone string
+	ToVpcID              string
+	ToIP                 net.IP
+}
+
+func getTransitGatewayVpcAttachment(client scanner.EC2API, transitGatewayID string, vpcID string) (*types.TransitGatewayVpcAttachment, error) {
+	filterTransitGatewayID := "transit-gateway-id"
+	filterVpcID := "vpc-id"
+	attachments, err := client.DescribeTransitGatewayVpcAttachments(context.Background(), &ec2.DescribeTransitGatewayVpcAttachmentsInput{
+		Filters: []types.Filter{
+			{
+				Name:   &filterTransitGatewayID,
+				Values: []string{transitGatewayID},
+			},
+			{
+				Name:   &filterVpcID,
+				Values: []string{vpcID},
+			},
+		},
+	})
+	if err != nil {
+		return nil, &APIError{"DescribeTransitGatewayVpcAttachments", err}
+	}
+
+	// deleted attachments are still returned for some time
+	for _, attachment := range attachments.TransitGatewayVpcAttachments {
+		if attachment.State != types.TransitGatewayAttachmentStateDeleted {
+			return &attachment, nil
+		}
+	}
+	return nil, nil
+}
+
+func getTransitGatewayAttachmentAvailabilityZones(client scanner.EC2API, attachment types.TransitGatewayVpcAttachment) ([]string, error) {
+	subnets, err := client.DescribeSubnets(context.Background(), &ec2.DescribeSubnetsInput{
+		SubnetIds: attachment.SubnetIds,
+	})
+	if err != nil {
+		return nil, &APIError{"DescribeSubnets", err}
+	}
+
+	availabilityZones := []string{}
+	for _, subnet := range subnets.Subnets {
+		availabilityZones = append(availabilityZones, *subnet.AvailabilityZone)
+	}
+	return availabilityZones, nil
+}
+
+func getTransitGatewayAttachmentAssociation(client scanner.EC2API, attachmentID string) (*types.TransitGatewayAttachmentAssociation, error) {
+	attachments, err := client.DescribeTransitGatewayAttachments(context.Background(), &ec2.DescribeTransitGatewayAttachmentsInput{
+		TransitGatewayAttachmentIds: []string{attachmentID},
+	})
+	if err != nil {
+		return nil, &APIError{"DescribeTransitGatewayAttachments", err}
+	}
+
+	if len(attachments.TransitGatewayAttachments) <= 0 {
+		return nil, nil
+	}
+	return attachments.TransitGatewayAttachments[0].Association, nil
+}
+
+// hostCidr - single address range, tgw route tables hold ipv4 and ipv6 routes in the same field
+func hostCidr(ip net.IP) string {
+	if ip.To4() != nil {
+		return fmt.Sprintf("%s/32", ip)
+	}
+	return fmt.Sprintf("%s/128", ip)
+}
+
+// selectTransitGatewayRoute - like in vpc route tables the most specific route wins
+func selectTransitGatewayRoute(client scanner.EC2API, routeTableID string, ip net.IP) (types.TransitGatewayRoute, bool, error) {
+	filterSupernetOf := "route-search.supernet-of-match"
+	routes, err := client.SearchTransitGatewayRoutes(context.Background(), &ec2.SearchTransitGatewayRoutesInput{
+		TransitGatewayRouteTableId: &routeTableID,
+		Filters: []types.Filter{
+			{
+				Name:   &filterSupernetOf,
+				Values: []string{hostCidr(ip)},
+			},
+		},
+	})
+	if err != nil {
+		return types.TransitGatewayRoute{}, false, &APIError{"SearchTransitGatewayRoutes", err}
+	}
+
+	var selected types.TransitGatewayRoute
+	selectedPrefixLength := -1
+	for _, route := range routes.Routes {
+		if route.DestinationCidrBlock == nil {
+			continue
+		}
+
+		cidr, err := parseCIDR(*route.DestinationCidrBlock)
+		if err != nil {
+			return types.TransitGatewayRoute{}, false, err
+		}
+
+		prefixLength, _ := cidr.Mask.Size()
+		if cidr.Contains(ip) && prefixLength > selectedPrefixLength {
+			selected = route
+			selectedPrefixLength = prefixLength
+		}
+	}
+	return selected, selectedPrefixLength >= 0, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTransitGatewayPath - follows traffic entering transit gateway from one vpc attachment
+// through the associated tgw route table to the attachment of the other vpc
+func checkTransitGatewayPath(client scanner.EC2API, path transitGatewayPath) (*Check, error) {
+	log.Debugf("Checking tgw %s path from %s to %s", path.TransitGatewayID, path.FromVpcID, path.ToIP)
+	fromAttachment, err := getTransitGatewayVpcAttachment(client, path.TransitGatewayID, path.FromVpcID)
+	if err != nil {
+		return nil, err
+	}
+	if fromAttachment == nil {
+		return failed(ReasonTransitGatewayAttachmentMissing, fmt.Sprintf("tgw %s has no attachment for vpc %s", path.TransitGatewayID, path.FromVpcID)), nil
+	}
+
+	fromAttachmentID := *fromAttachment.TransitGatewayAttachmentId
+	if fromAttachment.State != types.TransitGatewayAttachmentStateAvailable {
+		return failed(ReasonTransitGatewayAttachmentInactive, fmt.Sprintf("tgw attachment %s for vpc %s is %s", fromAttachmentID, path.FromVpcID, fromAttachment.State)), nil
+	}
+
+	if path.FromAvailabilityZone != "" {
+		availabilityZones, err := getTransitGatewayAttachmentAvailabilityZones(client, *fromAttachment)
+		if err != nil {
+			return nil, err
+		}
+		if !containsString(availabilityZones, path.FromAvailabilityZone) {
+			return failed(ReasonTransitGatewayAttachmentMissing, fmt.Sprintf("tgw attachment %s has no subnet in availability zone %s", fromAttachmentID, path.FromAvailabilityZone)), nil
+		}
+	}
+
+	association, err := getTransitGatewayAttachmentAssociation(client, fromAttachmentID)
+	if err != nil {
+		return nil, err
+	}
+	if association == nil || association.TransitGatewayRouteTableId == nil {
+		return failed(ReasonTransitGatewayAttachmentInactive, fmt.Sprintf("tgw attachment %s is not associated with any tgw route table", fromAttachmentID)), nil
+	}
+
+	routeTableID := *association.TransitGatewayRouteTableId
+	if association.State != types.TransitGatewayAssociationStateAssociated {
+		return failed(ReasonTransitGatewayAttachmentInactive, fmt.Sprintf("tgw attachment %s association with tgw route table %s is %s", fromAttachmentID, routeTableID, association.State)), nil
+	}
+
+	route, found, err := selectTransitGatewayRoute(client, routeTableID, path.ToIP)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return failed(ReasonTransitGatewayRouteNotFound, fmt.Sprintf("tgw route table %s has no static or propagated route for %s", routeTableID, path.ToIP)), nil
+	}
+
+	if route.State == types.TransitGatewayRouteStateBlackhole {
+		return failed(ReasonTransitGatewayRouteBlackhole, fmt.Sprintf("tgw route table %s route %s is a blackhole - traffic is dropped", routeTableID, *route.DestinationCidrBlock)), nil
+	}
+
+	for _, routeAttachment := range route.TransitGatewayAttachments {
+		if routeAttachment.ResourceId != nil && *routeAttachment.ResourceId == path.ToVpcID {
+			return passed(ReasonTransitGatewayRouteFound, fmt.Sprintf("tgw attachment %s (%s) uses tgw route table %s with %s route %s to %s (%s)",
+				fromAttachmentID, path.FromVpcID, routeTableID, route.Type, *route.DestinationCidrBlock, *routeAttachment.TransitGatewayAttachmentId, path.ToVpcID)), nil
+		}
+	}
+
+	return failed(ReasonTransitGatewayRouteNotFound, fmt.Sprintf("tgw route table %s route %s doesnt point at attachment of vpc %s", routeTableID, *route.DestinationCidrBlock, path.ToVpcID)), nil
+}
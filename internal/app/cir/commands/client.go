@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"github.com/michal-franc/cir/internal/app/cir/snapshot"
+	log "github.com/sirupsen/logrus"
+	"net"
+)
+
+func setLogLevel() {
+	log.SetLevel(log.WarnLevel)
+
+	if debug {
+		log.SetLevel(log.DebugLevel)
+	}
+}
+
+// newAwsClients - creates aws clients using default aws config, aborts if there are no valid credentials
+func newAwsClients() scanner.AwsClients {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Fatalf("unable to load SDK config, %v", err)
+	}
+
+	creds, err := cfg.Credentials.Retrieve(context.Background())
+	if err != nil {
+		log.Fatal("no credentials or invalid credentials provided")
+	}
+
+	if creds.Expired() {
+		log.Fatal("aws credentials have expired - aborting")
+	}
+
+	return scanner.AwsClients{
+		EC2:         ec2.NewFromConfig(cfg),
+		RDS:         rds.NewFromConfig(cfg),
+		ElastiCache: elasticache.NewFromConfig(cfg),
+		ELBV2:       elasticloadbalancingv2.NewFromConfig(cfg),
+		ELB:         elasticloadbalancing.NewFromConfig(cfg),
+		ECS:         ecs.NewFromConfig(cfg),
+		Lambda:      lambda.NewFromConfig(cfg),
+		Resolver:    net.DefaultResolver,
+	}
+}
+
+// loadSnapshotClients - creates clients replaying the snapshot, queries from the snapshot are used if not given
+func loadSnapshotClients() scanner.AwsClients {
+	s, err := snapshot.Load(snapshotFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if sourceQuery == "" {
+		sourceQuery = s.SourceQuery
+	}
+	if destinationQuery == "" {
+		destinationQuery = s.DestinationQuery
+	}
+
+	return snapshot.NewReplayer(s).Clients()
+}
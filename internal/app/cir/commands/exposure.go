@@ -0,0 +1,168 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/michal-franc/cir/internal/app/cir/analyser"
+	"github.com/michal-franc/cir/internal/app/cir/printer"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"net"
+	"os"
+	"strings"
+)
+
+func init() {
+	exposureCmd.Flags().StringVar(&destinationQuery, "to", "", "Specifies which machine is checked eg ip:127.0.0.0, name:my-db or rds:orders.")
+	exposureCmd.MarkFlagRequired("to")
+	exposureCmd.Flags().Int32Var(&port, "port", -1, "Specifies which port should be checked - required for tcp and udp.")
+	exposureCmd.Flags().StringVar(&protocol, "protocol", "tcp", "Specifies which protocol should be checked - tcp, udp or icmp.")
+	exposureCmd.Flags().Int32Var(&icmpType, "icmp-type", 8, "Specifies which icmp type should be checked when protocol is icmp - default is echo request (ping).")
+	exposureCmd.Flags().Int32Var(&icmpCode, "icmp-code", 0, "Specifies which icmp code should be checked when protocol is icmp.")
+	exposureCmd.Flags().BoolVar(&debug, "debug", false, "Specifies if debug messages should be emitted.")
+	exposureCmd.Flags().BoolVar(&detailed, "detailed", false, "Will also print sources which are allowed by security group but cant reach the destination.")
+	exposureCmd.Flags().StringVar(&output, "output", printer.OutputText, "Specifies the output format - text, json or yaml.")
+	rootCmd.AddCommand(exposureCmd)
+}
+
+func validateExposureArgs() bool {
+	isValid := true
+
+	if output != printer.OutputText && output != printer.OutputJSON && output != printer.OutputYAML {
+		fmt.Printf("output format '%s' is not supported - use text, json or yaml\n", output)
+		isValid = false
+	}
+
+	parsedProtocol, err := analyser.ParseProtocol(protocol)
+	if err != nil {
+		fmt.Println(err)
+		isValid = false
+	}
+
+	if parsedProtocol == analyser.ProtocolICMP {
+		if icmpType < 0 || icmpType > 255 || icmpCode < 0 || icmpCode > 255 {
+			fmt.Println("icmp type and code value out of range 0-255")
+			isValid = false
+		}
+	} else if port <= 0 || port > 65535 {
+		fmt.Println("port value out of range 1-65535")
+		isValid = false
+	}
+
+	return ArgValidator.ValidateQuery(destinationQuery, "to") && isValid
+}
+
+// ipWildcard - instances in range of unknown vpc are searched by their ip, the range is widened to whole octets
+// false for ipv6 and ranges wider than /8 as the search would return too many instances
+func ipWildcard(value string) (string, bool) {
+	_, cidr, err := net.ParseCIDR(value)
+	if err != nil || cidr.IP.To4() == nil {
+		return "", false
+	}
+	prefixLength, _ := cidr.Mask.Size()
+	if prefixLength < 8 {
+		return "", false
+	}
+	if prefixLength == 32 {
+		return cidr.IP.String(), true
+	}
+	octets := strings.Split(cidr.IP.String(), ".")[:prefixLength/8]
+	return strings.Join(octets, ".") + ".*", true
+}
+
+// instanceQueries - queries finding instances of the sources which may reach the destination
+// ranges in vpcs are searched by the vpc, ranges behind transit gateway by the ip as their vpc is not known
+func instanceQueries(exposedSources []analyser.ExposedSource) []string {
+	queries := []string{}
+	seen := map[string]bool{}
+	for _, source := range exposedSources {
+		if source.Verdict() == analyser.VerdictUnreachable {
+			continue
+		}
+
+		query := ""
+		switch source.Kind {
+		case analyser.SourceSecurityGroup:
+			query = fmt.Sprintf("%s:%s", scanner.QuerySG, source.GroupID)
+		case analyser.SourceVpc, analyser.SourcePeeredVpc:
+			if source.VpcID != "" {
+				query = fmt.Sprintf("%s:%s", scanner.QueryVpc, source.VpcID)
+			}
+		case analyser.SourceTransitGateway:
+			if wildcard, ok := ipWildcard(source.Cidr); ok {
+				query = fmt.Sprintf("%s:%s", scanner.QueryIP, wildcard)
+			}
+		}
+
+		if query != "" && !seen[query] {
+			seen[query] = true
+			queries = append(queries, query)
+		}
+	}
+	return queries
+}
+
+// reachingInstances - every instance found in the sources is analysed with the full path as the exposure checks only the destination side
+// sources which cant be scanned are skipped, the exposure still lists their ranges
+func reachingInstances(clients scanner.AwsClients, queries []string, traffic analyser.Traffic) []analyser.Analysis {
+	instances := []analyser.Analysis{}
+	seen := map[string]bool{}
+	for _, query := range queries {
+		data, err := scanner.ScanAws(clients, query, destinationQuery)
+		if err != nil {
+			log.Warnf("instances of '%s' not listed - %s", query, err)
+			continue
+		}
+
+		// load balancer nodes are the destination, the legs behind them are not part of the exposure
+		data.LoadBalancer = nil
+		listOfAnalysis, err := analyser.RunAnalysis(*data, clients.EC2, traffic)
+		if err != nil {
+			log.Warnf("instances of '%s' not listed - %s", query, err)
+			continue
+		}
+
+		for _, a := range listOfAnalysis {
+			key := fmt.Sprintf("%s|%s|%s", a.SourceInterfaceID, a.SourceIP, a.DestinationInterfaceID)
+			if a.Verdict() != analyser.VerdictReachable || seen[key] {
+				continue
+			}
+			seen[key] = true
+			instances = append(instances, a)
+		}
+	}
+	return instances
+}
+
+var exposureCmd = &cobra.Command{
+	Use:   "exposure",
+	Short: "list sources which can reach the destination",
+	Run: func(cmd *cobra.Command, args []string) {
+		setLogLevel()
+
+		if !validateExposureArgs() {
+			os.Exit(ExitError)
+		}
+
+		clients := newAwsClients()
+		data, err := scanner.ScanDestinations(clients, destinationQuery)
+		if err != nil {
+			log.Fatalf("error when scanning AWS resources - %s", err)
+		}
+
+		exposedSources, err := analyser.RunExposureAnalysis(*data, clients.EC2, traffic())
+		if err != nil {
+			log.Fatalf("error when analysing data - %s", err)
+		}
+
+		instances := reachingInstances(clients, instanceQueries(exposedSources), traffic())
+
+		if output == printer.OutputText {
+			printer.PrintExposure(exposedSources, instances, detailed)
+		} else if err := printer.WriteExposureReport(os.Stdout, exposedSources, instances, output); err != nil {
+			log.Fatalf("error when writing report - %s", err)
+		}
+
+		os.Exit(exitCode(analyser.ExposedVerdict(exposedSources)))
+	},
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"github.com/michal-franc/cir/internal/app/cir/analyser"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIPWildcardWidensRangeToWholeOctets(t *testing.T) {
+	cases := map[string]string{
+		"10.2.0.0/16":    "10.2.*",
+		"10.2.16.0/20":   "10.2.*",
+		"10.0.0.0/8":     "10.*",
+		"10.2.3.4/32":    "10.2.3.4",
+		"192.168.1.0/24": "192.168.1.*",
+	}
+
+	for cidr, expected := range cases {
+		wildcard, ok := ipWildcard(cidr)
+		assert.True(t, ok, cidr)
+		assert.Equal(t, expected, wildcard)
+	}
+
+	for _, cidr := range []string{"0.0.0.0/0", "2600:1f18::/56", "invalid"} {
+		_, ok := ipWildcard(cidr)
+		assert.False(t, ok, cidr)
+	}
+}
+
+func TestInstanceQueriesSkipUnreachableSources(t *testing.T) {
+	pass := &analyser.Check{Status: analyser.CheckPass}
+	fail := &analyser.Check{Status: analyser.CheckFail}
+	source := func(kind analyser.SourceKind, cidr string, groupID string, vpcID string, check *analyser.Check) analyser.ExposedSource {
+		return analyser.ExposedSource{Kind: kind, Cidr: cidr, GroupID: groupID, VpcID: vpcID, CanEnterDestination: check,
+			DestinationSubnetHasRoute: pass, ConnectionIsActive: pass, DestinationNaclAllowsInbound: pass, DestinationNaclAllowsReturn: pass}
+	}
+
+	queries := instanceQueries([]analyser.ExposedSource{
+		source(analyser.SourceVpc, "10.0.0.0/16", "", "vpc-1", pass),
+		source(analyser.SourceVpc, "10.0.1.0/24", "", "vpc-1", pass),
+		source(analyser.SourcePeeredVpc, "10.1.0.0/16", "", "vpc-peer", fail),
+		source(analyser.SourceTransitGateway, "10.2.0.0/16", "", "", pass),
+		source(analyser.SourceInternet, "0.0.0.0/0", "", "", pass),
+		source(analyser.SourceSecurityGroup, "", "sg-app", "", pass),
+	})
+
+	assert.Equal(t, []string{"vpc:vpc-1", "ip:10.2.*", "sg:sg-app"}, queries)
+}
@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/michal-franc/cir/internal/app/cir/analyser"
+	"github.com/michal-franc/cir/internal/app/cir/printer"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var groupQuery string
+var matrixPorts string
+var matrixOutput string
+
+func init() {
+	matrixCmd.Flags().StringVar(&groupQuery, "group", "", "Specifies the tag instances are grouped by eg. tag:Tier, other terms filter the instances eg. tag:Tier,vpc:vpc-123.")
+	matrixCmd.MarkFlagRequired("group")
+	matrixCmd.Flags().Int32Var(&port, "port", -1, "Specifies which port should be checked - required for tcp and udp unless --ports is used.")
+	matrixCmd.Flags().StringVar(&matrixPorts, "ports", "", fmt.Sprintf("Specifies comma separated ports or named port sets which are all checked eg. 80,8080 or web,ssh - sets: %s.", strings.Join(portSetNames(), ", ")))
+	matrixCmd.Flags().StringVar(&protocol, "protocol", "tcp", "Specifies which protocol should be checked - tcp, udp or icmp, named port sets have their own protocols.")
+	matrixCmd.Flags().Int32Var(&icmpType, "icmp-type", 8, "Specifies which icmp type should be checked when protocol is icmp - default is echo request (ping).")
+	matrixCmd.Flags().Int32Var(&icmpCode, "icmp-code", 0, "Specifies which icmp code should be checked when protocol is icmp.")
+	matrixCmd.Flags().BoolVar(&debug, "debug", false, "Specifies if debug messages should be emitted.")
+	matrixCmd.Flags().StringVar(&matrixOutput, "output", printer.OutputTable, "Specifies the output format - table, csv or html.")
+	rootCmd.AddCommand(matrixCmd)
+}
+
+// namedPortSets - ports commonly checked together, usable in --ports instead of the numbers
+var namedPortSets = map[string][]analyser.Traffic{
+	"web":   {analyser.NewPortTraffic(analyser.ProtocolTCP, 80), analyser.NewPortTraffic(analyser.ProtocolTCP, 443)},
+	"ssh":   {analyser.NewPortTraffic(analyser.ProtocolTCP, 22)},
+	"rdp":   {analyser.NewPortTraffic(analyser.ProtocolTCP, 3389)},
+	"dns":   {analyser.NewPortTraffic(analyser.ProtocolUDP, 53), analyser.NewPortTraffic(analyser.ProtocolTCP, 53)},
+	"db":    {analyser.NewPortTraffic(analyser.ProtocolTCP, 3306), analyser.NewPortTraffic(analyser.ProtocolTCP, 5432), analyser.NewPortTraffic(analyser.ProtocolTCP, 1433)},
+	"cache": {analyser.NewPortTraffic(analyser.ProtocolTCP, 6379), analyser.NewPortTraffic(analyser.ProtocolTCP, 11211)},
+}
+
+func portSetNames() []string {
+	names := []string{}
+	for name := range namedPortSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parsePorts - numbers use the protocol from --protocol, named sets their own, the same traffic is checked once
+func parsePorts(value string, protocol analyser.Protocol) ([]analyser.Traffic, error) {
+	listOfTraffic := []analyser.Traffic{}
+	seen := map[analyser.Traffic]bool{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+
+		items, ok := namedPortSets[strings.ToLower(item)]
+		if !ok {
+			number, err := strconv.Atoi(item)
+			if err != nil || number <= 0 || number > 65535 {
+				return nil, fmt.Errorf("port '%s' is not a number in range 1-65535 or port set - use %s", item, strings.Join(portSetNames(), ", "))
+			}
+			items = []analyser.Traffic{analyser.NewPortTraffic(protocol, int32(number))}
+		}
+
+		for _, t := range items {
+			if !seen[t] {
+				seen[t] = true
+				listOfTraffic = append(listOfTraffic, t)
+			}
+		}
+	}
+	return listOfTraffic, nil
+}
+
+func validateMatrixArgs() bool {
+	isValid := true
+
+	if matrixOutput != printer.OutputTable && matrixOutput != printer.OutputCSV && matrixOutput != printer.OutputHTML {
+		fmt.Printf("output format '%s' is not supported - use table, csv or html\n", matrixOutput)
+		isValid = false
+	}
+
+	parsedProtocol, err := analyser.ParseProtocol(protocol)
+	if err != nil {
+		fmt.Println(err)
+		isValid = false
+	}
+
+	switch {
+	case port != -1 && matrixPorts != "":
+		fmt.Println("--port cant be combined with --ports")
+		isValid = false
+	case matrixPorts != "" && parsedProtocol == analyser.ProtocolICMP:
+		fmt.Println("--ports cant be combined with --protocol icmp - icmp has no ports, use --icmp-type and --icmp-code")
+		isValid = false
+	case matrixPorts != "":
+		if _, err := parsePorts(matrixPorts, parsedProtocol); err != nil {
+			fmt.Println(err)
+			isValid = false
+		}
+	case parsedProtocol == analyser.ProtocolICMP:
+		if icmpType < 0 || icmpType > 255 || icmpCode < 0 || icmpCode > 255 {
+			fmt.Println("icmp type and code value out of range 0-255")
+			isValid = false
+		}
+	case port <= 0 || port > 65535:
+		fmt.Println("port value out of range 1-65535")
+		isValid = false
+	}
+
+	return ArgValidator.ValidateQuery(groupQuery, "group") && isValid
+}
+
+// matrixTraffic - traffic of every port from --ports or the single one from --port, expects validated args
+func matrixTraffic() []analyser.Traffic {
+	if matrixPorts == "" {
+		return []analyser.Traffic{traffic()}
+	}
+	parsedProtocol, _ := analyser.ParseProtocol(protocol)
+	listOfTraffic, _ := parsePorts(matrixPorts, parsedProtocol)
+	return listOfTraffic
+}
+
+var matrixCmd = &cobra.Command{
+	Use:   "matrix",
+	Short: "show reachability between every pair of groups of instances sharing tag value",
+	Run: func(cmd *cobra.Command, args []string) {
+		setLogLevel()
+
+		if !validateMatrixArgs() {
+			os.Exit(ExitError)
+		}
+
+		clients := newAwsClients()
+		groups, err := scanner.ScanGroups(clients, groupQuery)
+		if err != nil {
+			log.Fatalf("error when scanning AWS resources - %s", err)
+		}
+
+		matrix, err := analyser.RunMatrixAnalysis(groups, clients.EC2, matrixTraffic())
+		if err != nil {
+			log.Fatalf("error when analysing data - %s", err)
+		}
+
+		if err := printer.WriteMatrix(os.Stdout, *matrix, matrixOutput); err != nil {
+			log.Fatalf("error when writing matrix - %s", err)
+		}
+
+		os.Exit(exitCode(matrix.Verdict()))
+	},
+}
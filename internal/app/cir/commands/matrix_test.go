@@ -0,0 +1,23 @@
+package commands
+
+import (
+	"github.com/michal-franc/cir/internal/app/cir/analyser"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParsePortsMixesNumbersAndNamedSets(t *testing.T) {
+	listOfTraffic, err := parsePorts("web, 8080,443,dns", analyser.ProtocolTCP)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []analyser.Traffic{
+		analyser.NewPortTraffic(analyser.ProtocolTCP, 80),
+		analyser.NewPortTraffic(analyser.ProtocolTCP, 443),
+		analyser.NewPortTraffic(analyser.ProtocolTCP, 8080),
+		analyser.NewPortTraffic(analyser.ProtocolUDP, 53),
+		analyser.NewPortTraffic(analyser.ProtocolTCP, 53),
+	}, listOfTraffic)
+
+	_, err = parsePorts("web,70000", analyser.ProtocolTCP)
+	assert.EqualError(t, err, "port '70000' is not a number in range 1-65535 or port set - use cache, db, dns, rdp, ssh, web")
+}
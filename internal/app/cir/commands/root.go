@@ -11,6 +11,17 @@ import (
 
 var cfgFile string
 
+// Exit codes of cir run, scripts can rely on them instead of parsing the output
+const (
+	ExitReachable   = 0
+	ExitUnreachable = 1
+	// ExitError - invalid arguments, aws errors or verdict couldnt be determined
+	ExitError = 2
+)
+
+// ExitViolation - cir verify found assertion which doesnt hold, unknown assertions exit with ExitError
+const ExitViolation = 1
+
 var rootCmd = &cobra.Command{
 	Use:   "CIR",
 	Short: "Can I Reach",
@@ -21,12 +32,14 @@ var rootCmd = &cobra.Command{
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(ExitError)
 	}
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
+	// log.Fatal is used for unrecoverable errors so it has to exit with error code
+	log.StandardLogger().ExitFunc = func(int) { os.Exit(ExitError) }
 }
 
 func initConfig() {
@@ -36,7 +49,7 @@ func initConfig() {
 		home, err := homedir.Dir()
 		if err != nil {
 			fmt.Println(err)
-			os.Exit(1)
+			os.Exit(ExitError)
 		}
 
 		// Search config in home directory with name ".cir.env".
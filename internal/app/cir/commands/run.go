@@ -1,102 +1,208 @@
 package commands
 
 import (
-	"context"
 	"fmt"
-	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/michal-franc/cir/internal/app/cir/analyser"
 	"github.com/michal-franc/cir/internal/app/cir/printer"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"os"
-	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/michal-franc/cir/internal/app/cir/scanner"
 )
 
 var sourceQuery string
 var destinationQuery string
 var port int32
+var protocol string
+var icmpType int32
+var icmpCode int32
 var debug bool
 var detailed bool
+var snapshotFile string
+var output string
+var allPorts bool
 
 func init() {
-	startCmd.Flags().StringVar(&sourceQuery, "from", "", "Specifies which machine the communication is initiated from eg ip:127.0.0.0 or name:my-awesome-ec2.")
-	startCmd.MarkFlagRequired("from")
-	startCmd.Flags().StringVar(&destinationQuery, "to", "", "Specifies which machine the communication is destined to go to ip:127.0.0.0 or name:my-awesome-ec2.")
-	startCmd.MarkFlagRequired("to")
-	startCmd.Flags().Int32Var(&port, "port", -1, "Specifies which port should be checked.")
-	startCmd.MarkFlagRequired("port")
+	startCmd.Flags().StringVar(&sourceQuery, "from", "", "Specifies which machine the communication is initiated from eg ip:127.0.0.0, name:my-awesome-ec2 or tag:Team=payments,vpc:vpc-123 - required unless --snapshot is used.")
+	startCmd.Flags().StringVar(&destinationQuery, "to", "", "Specifies which machine the communication is destined to go to eg ip:127.0.0.0, name:my-awesome-ec2 or tag:Team=payments,vpc:vpc-123 - required unless --snapshot is used.")
+	startCmd.Flags().Int32Var(&port, "port", -1, "Specifies which port should be checked - required for tcp and udp, for lb destination selects the listener (all listeners if not set).")
+	startCmd.Flags().StringVar(&protocol, "protocol", "tcp", "Specifies which protocol should be checked - tcp, udp or icmp.")
+	startCmd.Flags().Int32Var(&icmpType, "icmp-type", 8, "Specifies which icmp type should be checked when protocol is icmp - default is echo request (ping).")
+	startCmd.Flags().Int32Var(&icmpCode, "icmp-code", 0, "Specifies which icmp code should be checked when protocol is icmp.")
 	startCmd.Flags().BoolVar(&debug, "debug", false, "Specifies if debug messages should be emitted.")
 	startCmd.Flags().BoolVar(&detailed, "detailed", false, "Will print detailed analysis regardless if there is one analysis or more.")
+	startCmd.Flags().StringVar(&output, "output", printer.OutputText, "Specifies the output format - text, json or yaml.")
+	startCmd.Flags().BoolVar(&allPorts, "all-ports", false, "Lists tcp and udp port ranges which can flow from source to destination instead of checking single port.")
+	startCmd.Flags().StringVar(&snapshotFile, "snapshot", "", "Analyses offline using aws responses recorded with 'cir snapshot', --from and --to default to the queries from the snapshot - only run supports it.")
 	rootCmd.AddCommand(startCmd)
 }
 
-func validateArgs() bool {
+// validateArgs - protocolChanged is true if --protocol was passed, all ports analysis checks tcp and udp so it cant be narrowed
+func validateArgs(protocolChanged bool) bool {
 	isValid := true
 
-	if port <= 0 || port > 65535 {
-		fmt.Println("port value out of range 1-65535")
+	if output != printer.OutputText && output != printer.OutputJSON && output != printer.OutputYAML {
+		fmt.Printf("output format '%s' is not supported - use text, json or yaml\n", output)
+		isValid = false
+	}
+
+	if sourceQuery == "" || destinationQuery == "" {
+		fmt.Println("--from and --to are required")
 		isValid = false
 	}
 
-	if strings.Contains("from", "ip") {
-		isValid = ArgValidator.ValidateIP(sourceQuery, "from") && isValid
+	parsedProtocol, err := analyser.ParseProtocol(protocol)
+	if err != nil {
+		fmt.Println(err)
+		isValid = false
 	}
-	if strings.Contains("to", "ip") {
-		isValid = ArgValidator.ValidateIP(destinationQuery, "to") && isValid
+
+	if allPorts {
+		if port != -1 {
+			fmt.Println("--all-ports cant be combined with --port")
+			isValid = false
+		}
+		if protocolChanged {
+			fmt.Println("--all-ports cant be combined with --protocol - it lists both tcp and udp, check icmp without --all-ports")
+			isValid = false
+		}
+		if isLoadBalancerQuery(destinationQuery) {
+			fmt.Println("--all-ports doesnt support load balancer destination - use --port to check its listeners")
+			isValid = false
+		}
+	} else if isLoadBalancerQuery(destinationQuery) {
+		if protocolChanged {
+			fmt.Println("--protocol doesnt support load balancer destination - protocol of its listeners is used")
+			isValid = false
+		}
+		// listener port is optional for load balancer, all listeners are checked without it
+		if port != -1 && (port <= 0 || port > 65535) {
+			fmt.Println("port value out of range 1-65535")
+			isValid = false
+		}
+	} else if parsedProtocol == analyser.ProtocolICMP {
+		if port != -1 {
+			fmt.Println("--port cant be combined with --protocol icmp")
+			isValid = false
+		}
+		if icmpType < 0 || icmpType > 255 || icmpCode < 0 || icmpCode > 255 {
+			fmt.Println("icmp type and code value out of range 0-255")
+			isValid = false
+		}
+	} else if port <= 0 || port > 65535 {
+		fmt.Println("port value out of range 1-65535")
+		isValid = false
 	}
 
+	isValid = ArgValidator.ValidateQuery(sourceQuery, "from") && isValid
+	isValid = ArgValidator.ValidateQuery(destinationQuery, "to") && isValid
+
 	return isValid
 }
 
+func isLoadBalancerQuery(query string) bool {
+	parsedQuery, err := scanner.ParseQuery(query)
+	if err != nil {
+		return false
+	}
+	term, ok := parsedQuery.ServiceTerm()
+	return ok && term.Kind == scanner.QueryLB
+}
+
+// runAnalysis - load balancer destination is analysed in legs, port selects its listener
+func runAnalysis(data *scanner.AwsData, client scanner.EC2API, traffic analyser.Traffic, port int32) ([]analyser.Analysis, error) {
+	if data.LoadBalancer != nil {
+		return analyser.RunLoadBalancerAnalysis(*data, client, port)
+	}
+	return analyser.RunAnalysis(*data, client, traffic)
+}
+
+// traffic - builds traffic description from flags, expects validated args
+func traffic() analyser.Traffic {
+	parsedProtocol, _ := analyser.ParseProtocol(protocol)
+	if parsedProtocol == analyser.ProtocolICMP {
+		return analyser.NewIcmpTraffic(icmpType, icmpCode)
+	}
+	return analyser.NewPortTraffic(parsedProtocol, port)
+}
+
 var startCmd = &cobra.Command{
 	Use:   "run",
 	Short: "run analysis",
 	Run: func(cmd *cobra.Command, args []string) {
-		if !validateArgs() {
-			os.Exit(1)
-		}
-
-		log.SetLevel(log.WarnLevel)
+		setLogLevel()
 
-		if debug {
-			log.SetLevel(log.DebugLevel)
+		// snapshot is loaded before validation as it provides default queries
+		var clients scanner.AwsClients
+		if snapshotFile != "" {
+			clients = loadSnapshotClients()
 		}
 
-		cfg, err := config.LoadDefaultConfig(context.Background())
-		if err != nil {
-			log.Fatalf("unable to load SDK config, %v", err)
+		if !validateArgs(cmd.Flags().Changed("protocol")) {
+			os.Exit(ExitError)
 		}
 
-		creds, err := cfg.Credentials.Retrieve(context.Background())
-		if err != nil {
-			log.Fatal("no credentials or invalid credentials provided")
-		}
-
-		if creds.Expired() {
-			log.Fatal("aws credentials have expired - aborting")
+		if clients.EC2 == nil {
+			clients = newAwsClients()
 		}
 
-		ec2Svc := ec2.NewFromConfig(cfg)
-		data, err := scanner.ScanAwsEc2(ec2Svc, sourceQuery, destinationQuery)
+		data, err := scanner.ScanAws(clients, sourceQuery, destinationQuery)
 		if err != nil {
 			log.Fatalf("error when scanning AWS resources - %s", err)
 		}
 
-		listOfAnalysis, err := analyser.RunAnalysis(*data, ec2Svc, port)
+		if allPorts {
+			os.Exit(exitCode(printAllowedPorts(data, clients.EC2)))
+		}
+
+		listOfAnalysis, err := runAnalysis(data, clients.EC2, traffic(), port)
 		if err != nil {
 			log.Fatalf("error when analysing data - %s", err)
 		}
 
-		for _, a := range listOfAnalysis {
-			printer.PrintAnalysis(a, len(listOfAnalysis) <= 1 || detailed)
+		if output == printer.OutputText {
+			// pairs in the same group have the same checks so only one of them is printed in details
+			representatives := analyser.GroupRepresentatives(listOfAnalysis)
+			for _, a := range representatives {
+				printer.PrintAnalysis(a, len(representatives) <= 1 || detailed)
+			}
+
+			// we want to print summary at the end if there are more than one listOfAnalysis
+			if len(listOfAnalysis) > 1 {
+				printer.PrintSummary(listOfAnalysis)
+			}
+		} else if err := printer.WriteReport(os.Stdout, listOfAnalysis, output); err != nil {
+			log.Fatalf("error when writing report - %s", err)
 		}
 
-		// we want to print summary at the end if there are more than one listOfAnalysis
-		if len(listOfAnalysis) > 1 {
-			printer.PrintSummary(listOfAnalysis)
-		}
+		os.Exit(exitCode(analyser.OverallVerdict(listOfAnalysis)))
 	},
 }
+
+// exitCode - unknown verdict is treated as error as reachability couldnt be determined
+func exitCode(verdict analyser.Verdict) int {
+	switch verdict {
+	case analyser.VerdictReachable:
+		return ExitReachable
+	case analyser.VerdictUnreachable:
+		return ExitUnreachable
+	}
+	return ExitError
+}
+
+// printAllowedPorts - all ports analysis answers which ports can flow instead of checking the traffic, returns its verdict
+func printAllowedPorts(data *scanner.AwsData, client scanner.EC2API) analyser.Verdict {
+	listOfAllowedPorts, err := analyser.RunAllPortsAnalysis(*data, client)
+	if err != nil {
+		log.Fatalf("error when analysing data - %s", err)
+	}
+
+	if output == printer.OutputText {
+		printer.PrintAllowedPorts(listOfAllowedPorts, detailed)
+	} else if err := printer.WriteAllowedPortsReport(os.Stdout, listOfAllowedPorts, output); err != nil {
+		log.Fatalf("error when writing report - %s", err)
+	}
+
+	return analyser.OverallAllowedPortsVerdict(listOfAllowedPorts)
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/michal-franc/cir/internal/app/cir/analyser"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"github.com/michal-franc/cir/internal/app/cir/snapshot"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var snapshotOut string
+
+func init() {
+	snapshotCmd.Flags().StringVar(&sourceQuery, "from", "", "Specifies which machine the communication is initiated from eg ip:127.0.0.0 or name:my-awesome-ec2.")
+	snapshotCmd.MarkFlagRequired("from")
+	snapshotCmd.Flags().StringVar(&destinationQuery, "to", "", "Specifies which machine the communication is destined to go to ip:127.0.0.0 or name:my-awesome-ec2.")
+	snapshotCmd.MarkFlagRequired("to")
+	snapshotCmd.Flags().StringVar(&snapshotOut, "out", "", "Specifies the file the snapshot is written to.")
+	snapshotCmd.MarkFlagRequired("out")
+	snapshotCmd.Flags().BoolVar(&debug, "debug", false, "Specifies if debug messages should be emitted.")
+	rootCmd.AddCommand(snapshotCmd)
+}
+
+// recordAnalysis - analysis makes its own calls eg. vpc peering, transit gateway or nat gateway subnet
+// it is run for every kind of traffic so the snapshot can be replayed with any --protocol, --port or --all-ports
+// load balancer is analysed on all of its listeners and targets, calls repeated by the runs are recorded once
+func recordAnalysis(data *scanner.AwsData, recorder *snapshot.Recorder) error {
+	if data.LoadBalancer != nil {
+		_, err := analyser.RunLoadBalancerAnalysis(*data, recorder, -1)
+		return err
+	}
+
+	if _, err := analyser.RunAllPortsAnalysis(*data, recorder); err != nil {
+		return err
+	}
+	// paths without any allowed port are skipped by all ports analysis
+	listOfTraffic := []analyser.Traffic{analyser.NewPortTraffic(analyser.ProtocolTCP, 443), analyser.NewPortTraffic(analyser.ProtocolUDP, 53), analyser.NewIcmpTraffic(8, 0)}
+	for _, traffic := range listOfTraffic {
+		if _, err := analyser.RunAnalysis(*data, recorder, traffic); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "record aws responses needed for analysis so it can be replayed offline with 'cir run --snapshot'",
+	Run: func(cmd *cobra.Command, args []string) {
+		setLogLevel()
+
+		recorder := snapshot.NewRecorder(newAwsClients(), sourceQuery, destinationQuery)
+		data, err := scanner.ScanAws(recorder.Clients(), sourceQuery, destinationQuery)
+		if err != nil {
+			log.Fatalf("error when scanning AWS resources - %s", err)
+		}
+
+		if err := recordAnalysis(data, recorder); err != nil {
+			log.Fatalf("error when analysing data - %s", err)
+		}
+
+		recorded := recorder.Snapshot()
+		if err := recorded.Save(snapshotOut); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("recorded %d aws responses to %s\n", len(recorded.Responses), snapshotOut)
+	},
+}
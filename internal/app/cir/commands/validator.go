@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
 	"net"
 )
 
@@ -11,18 +12,29 @@ type Validator struct{}
 // ArgValidator - global instance of validator
 var ArgValidator = &Validator{}
 
-// ValidateIP - validates IP - will only return true if correct IPV4
+// ValidateIP - validates IP - will only return true if correct IPV4 or IPV6
 func (*Validator) ValidateIP(ip string, paramName string) bool {
-	parsedIP := net.ParseIP(ip)
-	if parsedIP == nil {
+	if net.ParseIP(ip) == nil {
 		fmt.Printf("%s unable to parse ip\n", paramName)
 		return false
 	}
 
-	if parsedIP.To4() == nil {
-		fmt.Printf("%s param is ipv6 - not supported yet\n", paramName)
+	return true
+}
+
+// ValidateQuery - validates query syntax and ips used in it, ips with wildcards are passed to aws as they are
+func (v *Validator) ValidateQuery(query string, paramName string) bool {
+	parsedQuery, err := scanner.ParseQuery(query)
+	if err != nil {
+		fmt.Printf("%s %s\n", paramName, err)
 		return false
 	}
 
-	return true
+	isValid := true
+	for _, term := range parsedQuery {
+		if term.Kind == scanner.QueryIP && !term.HasWildcard() {
+			isValid = v.ValidateIP(term.Value, paramName) && isValid
+		}
+	}
+	return isValid
 }
@@ -11,6 +11,7 @@ func TestInvalidIPsReturnFalse(t *testing.T) {
 		"1.1.-1",          // -1
 		"256.122.122.122", // 256 is > than max for ip 255
 		"invalid",         // text is not ip
+		"2600:1f18::g1",   // g is not hex digit
 	}
 
 	for _, ip := range invalidIPs {
@@ -26,6 +27,8 @@ func TestValidIPsReturnTrue(t *testing.T) {
 		"0.0.0.0", // this is valid IP - TODO: but maybe we should not allow it?
 		"255.255.255.255",
 		"192.168.0.1",
+		"2600:1f18:1000:10::5",
+		"::",
 	}
 
 	for _, ip := range validIPs {
@@ -34,3 +37,19 @@ func TestValidIPsReturnTrue(t *testing.T) {
 		assert.True(t, result)
 	}
 }
+
+func TestQueryWithInvalidIPReturnsFalse(t *testing.T) {
+	assert.False(t, ArgValidator.ValidateQuery("ip:256.1.1.1", ""))
+	assert.False(t, ArgValidator.ValidateQuery("vpc:vpc-1,ip:invalid", ""))
+	assert.False(t, ArgValidator.ValidateQuery("unknown:value", ""))
+}
+
+func TestQueryWithIpv6ReturnsTrue(t *testing.T) {
+	assert.True(t, ArgValidator.ValidateQuery("ip:2600:1f18:1000:10::5", ""))
+	assert.True(t, ArgValidator.ValidateQuery("2600:1f18:1000:10::5", ""))
+}
+
+func TestQueryWithWildcardIPReturnsTrue(t *testing.T) {
+	assert.True(t, ArgValidator.ValidateQuery("ip:10.0.1.*", ""))
+	assert.True(t, ArgValidator.ValidateQuery("name:ip:like-name", ""))
+}
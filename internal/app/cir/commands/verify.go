@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"github.com/michal-franc/cir/internal/app/cir/printer"
+	"github.com/michal-franc/cir/internal/app/cir/verify"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+)
+
+var expectationsFile string
+var junitFile string
+
+func init() {
+	verifyCmd.Flags().StringVarP(&expectationsFile, "file", "f", "", "Specifies the yaml file with assertions eg. api must reach db on 5432.")
+	verifyCmd.MarkFlagRequired("file")
+	verifyCmd.Flags().StringVar(&junitFile, "junit", "", "Specifies the file junit xml report is written to, one test case per assertion.")
+	verifyCmd.Flags().BoolVar(&debug, "debug", false, "Specifies if debug messages should be emitted.")
+	rootCmd.AddCommand(verifyCmd)
+}
+
+// verifyExitCode - violation wins over assertions which couldnt be checked as it is certain
+func verifyExitCode(results []verify.Result) int {
+	exitCode := ExitReachable
+	for _, result := range results {
+		switch result.Status {
+		case verify.StatusViolated:
+			return ExitViolation
+		case verify.StatusUnknown, verify.StatusError:
+			exitCode = ExitError
+		}
+	}
+	return exitCode
+}
+
+// writeJUnit - close error is returned as well as buffered report could be lost on it
+func writeJUnit(path string, suiteName string, results []verify.Result) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := printer.WriteJUnit(file, suiteName, results); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "check assertions about reachability from yaml file, exits with 1 if any doesnt hold",
+	Run: func(cmd *cobra.Command, args []string) {
+		setLogLevel()
+
+		expectations, err := verify.Load(expectationsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		results := verify.Run(newAwsClients(), *expectations)
+		printer.PrintVerify(results)
+		if junitFile != "" {
+			if err := writeJUnit(junitFile, filepath.Base(expectationsFile), results); err != nil {
+				// exit code 1 is reserved for violated assertion
+				log.Errorf("error when writing junit report - %s", err)
+				os.Exit(ExitError)
+			}
+		}
+
+		os.Exit(verifyExitCode(results))
+	},
+}
@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"github.com/michal-franc/cir/internal/app/cir/verify"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyExitCodePrefersViolation(t *testing.T) {
+	result := func(status verify.Status) verify.Result { return verify.Result{Status: status} }
+
+	assert.Equal(t, ExitReachable, verifyExitCode([]verify.Result{result(verify.StatusHolds)}))
+	assert.Equal(t, ExitError, verifyExitCode([]verify.Result{result(verify.StatusHolds), result(verify.StatusUnknown)}))
+	assert.Equal(t, ExitViolation, verifyExitCode([]verify.Result{result(verify.StatusError), result(verify.StatusViolated)}))
+}
+
+func TestWriteJUnitReturnsErrorInsteadOfExiting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cir-verify")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	results := []verify.Result{{Assertion: verify.Assertion{Name: "api reaches db"}, Status: verify.StatusHolds}}
+
+	path := filepath.Join(dir, "report.xml")
+	assert.Nil(t, writeJUnit(path, "expectations.yaml", results))
+	content, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), `name="api reaches db"`)
+
+	assert.NotNil(t, writeJUnit(filepath.Join(dir, "missing", "report.xml"), "expectations.yaml", results))
+}
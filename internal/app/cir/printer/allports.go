@@ -0,0 +1,109 @@
+package printer
+
+import (
+	"fmt"
+	"github.com/liamg/tml"
+	"github.com/michal-franc/cir/internal/app/cir/analyser"
+	"io"
+	"strings"
+)
+
+// AllowedPortsReport - machine readable result of cir run --all-ports
+type AllowedPortsReport struct {
+	SchemaVersion int                  `json:"schema_version" yaml:"schema_version"`
+	Verdict       analyser.Verdict     `json:"verdict" yaml:"verdict"`
+	Results       []ReportAllowedPorts `json:"results" yaml:"results"`
+}
+
+// ReportAllowedPorts - port ranges which can flow from source interface to destination interface
+type ReportAllowedPorts struct {
+	Source        ReportEndpoint         `json:"source" yaml:"source"`
+	Destination   ReportEndpoint         `json:"destination" yaml:"destination"`
+	AddressFamily analyser.AddressFamily `json:"address_family" yaml:"address_family"`
+	Verdict       analyser.Verdict       `json:"verdict" yaml:"verdict"`
+	Reachable     []ReportPortRange      `json:"reachable" yaml:"reachable"`
+	Unknown       []ReportPortRange      `json:"unknown" yaml:"unknown"`
+	// analysis of every protocol on its first port, checks not depending on the port apply to all of its ports
+	Paths []ReportResult `json:"paths" yaml:"paths"`
+}
+
+// ReportPortRange - ports of the protocol, from and to are equal for single port
+type ReportPortRange struct {
+	Protocol analyser.Protocol `json:"protocol" yaml:"protocol"`
+	FromPort int32             `json:"from_port" yaml:"from_port"`
+	ToPort   int32             `json:"to_port" yaml:"to_port"`
+}
+
+func toReportPortRanges(ranges []analyser.PortRange) []ReportPortRange {
+	reportRanges := []ReportPortRange{}
+	for _, r := range ranges {
+		reportRanges = append(reportRanges, ReportPortRange{r.Protocol, r.From, r.To})
+	}
+	return reportRanges
+}
+
+func toReportAllowedPorts(p analyser.AllowedPorts) ReportAllowedPorts {
+	paths := []ReportResult{}
+	for _, a := range p.Paths {
+		paths = append(paths, toReportResult(a))
+	}
+	return ReportAllowedPorts{
+		Source:        ReportEndpoint{ID: p.SourceID, InterfaceID: p.SourceInterfaceID, IP: p.SourceIP},
+		Destination:   ReportEndpoint{ID: p.DestinationID, InterfaceID: p.DestinationInterfaceID, IP: p.DestinationIP},
+		AddressFamily: p.AddressFamily,
+		Verdict:       p.Verdict(),
+		Reachable:     toReportPortRanges(p.Reachable),
+		Unknown:       toReportPortRanges(p.Unknown),
+		Paths:         paths,
+	}
+}
+
+// NewAllowedPortsReport - converts allowed ports of every interface pair to report
+func NewAllowedPortsReport(listOfAllowedPorts []analyser.AllowedPorts) AllowedPortsReport {
+	results := []ReportAllowedPorts{}
+	for _, p := range listOfAllowedPorts {
+		results = append(results, toReportAllowedPorts(p))
+	}
+	return AllowedPortsReport{ReportSchemaVersion, analyser.OverallAllowedPortsVerdict(listOfAllowedPorts), results}
+}
+
+// WriteAllowedPortsReport - writes allowed ports report in json or yaml format
+func WriteAllowedPortsReport(w io.Writer, listOfAllowedPorts []analyser.AllowedPorts, format string) error {
+	return encodeReport(w, NewAllowedPortsReport(listOfAllowedPorts), format)
+}
+
+// toStringPortRanges - eg. tcp 443, tcp 8000-8100, udp 53
+func toStringPortRanges(ranges []analyser.PortRange) string {
+	values := []string{}
+	for _, r := range ranges {
+		values = append(values, r.String())
+	}
+	return strings.Join(values, ", ")
+}
+
+// PrintAllowedPorts - prints port ranges which can flow between every interface pair, checks blocking the path are printed below the pair
+// detailed prints the analysis of every protocol
+func PrintAllowedPorts(listOfAllowedPorts []analyser.AllowedPorts, detailed bool) {
+	for _, p := range listOfAllowedPorts {
+		source := fmt.Sprintf("%s (%s %s)", p.SourceID, p.SourceInterfaceID, p.SourceIP)
+		destination := fmt.Sprintf("%s (%s %s)", p.DestinationID, p.DestinationInterfaceID, p.DestinationIP)
+		ports := toStringPortRanges(p.Reachable)
+		if ports == "" {
+			ports = "no ports"
+		}
+		tml.Printf("%s %s can reach %s over %s on: %s\n", toStringVerdict(p.Verdict()), source, destination, p.AddressFamily, ports)
+		if len(p.Unknown) > 0 {
+			tml.Printf("  %s unknown: %s\n", toStringVerdict(analyser.VerdictUnknown), toStringPortRanges(p.Unknown))
+		}
+		for _, check := range p.BlockingChecks() {
+			fmt.Print("  ")
+			printCheck(*check)
+		}
+
+		if detailed {
+			for _, path := range p.Paths {
+				PrintAnalysis(path, true)
+			}
+		}
+	}
+}
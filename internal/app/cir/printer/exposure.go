@@ -0,0 +1,160 @@
+package printer
+
+import (
+	"fmt"
+	"github.com/liamg/tml"
+	"github.com/michal-franc/cir/internal/app/cir/analyser"
+	"io"
+)
+
+// ExposureReport - machine readable result of cir exposure
+type ExposureReport struct {
+	SchemaVersion int                   `json:"schema_version" yaml:"schema_version"`
+	Verdict       analyser.Verdict      `json:"verdict" yaml:"verdict"`
+	Sources       []ReportExposedSource `json:"sources" yaml:"sources"`
+	// instances found in the sources which can reach the destination
+	Instances []ReportResult `json:"instances" yaml:"instances"`
+}
+
+// ReportExposedSource - range or security group allowed to reach the destination interface
+type ReportExposedSource struct {
+	Destination   ReportEndpoint         `json:"destination" yaml:"destination"`
+	Traffic       ReportTraffic          `json:"traffic" yaml:"traffic"`
+	AddressFamily analyser.AddressFamily `json:"address_family" yaml:"address_family"`
+	Kind          analyser.SourceKind    `json:"kind" yaml:"kind"`
+	Cidr          string                 `json:"cidr,omitempty" yaml:"cidr,omitempty"`
+	GroupID       string                 `json:"group_id,omitempty" yaml:"group_id,omitempty"`
+	Origin        string                 `json:"origin" yaml:"origin"`
+	Via           string                 `json:"via,omitempty" yaml:"via,omitempty"`
+	VpcID         string                 `json:"vpc_id,omitempty" yaml:"vpc_id,omitempty"`
+	Verdict       analyser.Verdict       `json:"verdict" yaml:"verdict"`
+	Checks        []ReportCheck          `json:"checks" yaml:"checks"`
+}
+
+func toReportExposedSource(s analyser.ExposedSource) ReportExposedSource {
+	return ReportExposedSource{
+		Destination:   ReportEndpoint{ID: s.DestinationID, InterfaceID: s.DestinationInterfaceID, IP: s.DestinationIP},
+		Traffic:       toReportTraffic(s.Traffic),
+		AddressFamily: s.AddressFamily,
+		Kind:          s.Kind,
+		Cidr:          s.Cidr,
+		GroupID:       s.GroupID,
+		Origin:        s.Origin,
+		Via:           s.Via,
+		VpcID:         s.VpcID,
+		Verdict:       s.Verdict(),
+		Checks: []ReportCheck{
+			toReportCheck("destination_security_groups_ingress", s.CanEnterDestination),
+			toReportCheck("destination_subnet_route", s.DestinationSubnetHasRoute),
+			toReportCheck("connection_active", s.ConnectionIsActive),
+			toReportCheck("destination_nacl_inbound", s.DestinationNaclAllowsInbound),
+			toReportCheck("destination_nacl_return", s.DestinationNaclAllowsReturn),
+		},
+	}
+}
+
+// NewExposureReport - converts exposed sources and analysis of their instances to report
+func NewExposureReport(exposedSources []analyser.ExposedSource, instances []analyser.Analysis) ExposureReport {
+	report := ExposureReport{ReportSchemaVersion, analyser.ExposedVerdict(exposedSources), []ReportExposedSource{}, []ReportResult{}}
+	for _, s := range exposedSources {
+		report.Sources = append(report.Sources, toReportExposedSource(s))
+	}
+	for _, a := range instances {
+		report.Instances = append(report.Instances, toReportResult(a))
+	}
+	return report
+}
+
+// WriteExposureReport - writes exposure report in json or yaml format
+func WriteExposureReport(w io.Writer, exposedSources []analyser.ExposedSource, instances []analyser.Analysis, format string) error {
+	return encodeReport(w, NewExposureReport(exposedSources, instances), format)
+}
+
+func toStringExposedSource(s analyser.ExposedSource) string {
+	description := fmt.Sprintf("%s %s", s.Kind, s.Cidr)
+	if s.GroupID != "" {
+		description = fmt.Sprintf("%s %s", s.Kind, s.GroupID)
+	}
+	if s.Via != "" {
+		description = fmt.Sprintf("%s via %s", description, s.Via)
+	}
+	if s.VpcID != "" {
+		description = fmt.Sprintf("%s (%s)", description, s.VpcID)
+	}
+	return fmt.Sprintf("%s - %s", description, s.Origin)
+}
+
+func countVerdict(exposedSources []analyser.ExposedSource, verdict analyser.Verdict) int {
+	count := 0
+	for _, s := range exposedSources {
+		if s.Verdict() == verdict {
+			count++
+		}
+	}
+	return count
+}
+
+// printExposedSources - reasons are the checks which didnt pass
+func printExposedSources(exposedSources []analyser.ExposedSource, verdict analyser.Verdict, withReasons bool) {
+	for _, s := range exposedSources {
+		if s.Verdict() != verdict {
+			continue
+		}
+		tml.Printf("%s %s\n", toStringVerdict(verdict), toStringExposedSource(s))
+		if !withReasons {
+			continue
+		}
+		for _, check := range s.Checks() {
+			if !check.IsPassing() {
+				printCheck(*check)
+			}
+		}
+	}
+}
+
+// PrintExposure - lists sources which can reach every destination interface, unknown ones separately with the checks which couldnt be evaluated
+// unreachable sources are printed only in detailed mode
+func PrintExposure(exposedSources []analyser.ExposedSource, instances []analyser.Analysis, detailed bool) {
+	byDestination := [][]analyser.ExposedSource{}
+	index := map[string]int{}
+	for _, s := range exposedSources {
+		key := fmt.Sprintf("%s|%s", s.DestinationInterfaceID, s.DestinationIP)
+		i, ok := index[key]
+		if !ok {
+			i = len(byDestination)
+			index[key] = i
+			byDestination = append(byDestination, []analyser.ExposedSource{})
+		}
+		byDestination[i] = append(byDestination[i], s)
+	}
+
+	if len(byDestination) == 0 {
+		fmt.Println("No inbound rule of the destination allows this traffic")
+	}
+
+	for _, sources := range byDestination {
+		first := sources[0]
+		tml.Printf("<yellow>Sources which can reach %s (%s %s) on %s over %s</yellow>\n", first.DestinationID, first.DestinationInterfaceID,
+			first.DestinationIP, first.Traffic, first.AddressFamily)
+		tml.Println("<yellow>---------------------------</yellow>")
+		printExposedSources(sources, analyser.VerdictReachable, false)
+		if countVerdict(sources, analyser.VerdictUnknown) > 0 {
+			fmt.Println("\nUnknown: (not supported yet or aws api errors)")
+			printExposedSources(sources, analyser.VerdictUnknown, true)
+		}
+		if detailed && countVerdict(sources, analyser.VerdictUnreachable) > 0 {
+			fmt.Println("\nUnreachable:")
+			printExposedSources(sources, analyser.VerdictUnreachable, true)
+		}
+		fmt.Println()
+	}
+
+	if len(instances) > 0 {
+		tml.Println("<yellow>Instances which can reach the destination</yellow>")
+		tml.Println("<yellow>---------------------------</yellow>")
+		// instances come from separate runs so their equivalence groups cant be merged
+		for _, a := range instances {
+			tml.Printf("%s %s can reach %s\n", toStringVerdict(a.Verdict()), toStringSource(a), toStringDestination(a))
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package printer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/michal-franc/cir/internal/app/cir/analyser"
+	"html/template"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output formats supported by cir matrix
+const (
+	OutputTable = "table"
+	OutputCSV   = "csv"
+	OutputHTML  = "html"
+)
+
+func toStringTraffic(traffic []analyser.Traffic) string {
+	values := []string{}
+	for _, t := range traffic {
+		values = append(values, t.String())
+	}
+	return strings.Join(values, ", ")
+}
+
+// toStringMatrixCell - verdict with reachable pairs out of all, cell without pairs eg. group with single instance to itself is empty
+func toStringMatrixCell(cell analyser.MatrixCell, symbol bool) string {
+	if cell.Pairs == 0 {
+		return "-"
+	}
+	verdict := string(cell.Verdict())
+	if symbol {
+		switch cell.Verdict() {
+		case analyser.VerdictReachable:
+			verdict = "✓"
+		case analyser.VerdictPartial:
+			verdict = "~"
+		case analyser.VerdictUnknown:
+			verdict = "?"
+		default:
+			verdict = "×"
+		}
+	}
+	return fmt.Sprintf("%s %d/%d", verdict, cell.Reachable, cell.Pairs)
+}
+
+// matrixFailure - pair of the cell which isnt reachable with the checks which didnt pass
+type matrixFailure struct {
+	Source      string
+	Destination string
+	Traffic     string
+	Checks      []ReportCheck
+}
+
+func matrixFailures(cell analyser.MatrixCell) []matrixFailure {
+	failures := []matrixFailure{}
+	for _, a := range cell.Failing {
+		failure := matrixFailure{toStringSource(a), toStringDestination(a), a.Traffic.String(), []ReportCheck{}}
+		for _, check := range toReportResult(a).Checks {
+			if check.Status != analyser.CheckPass {
+				failure.Checks = append(failure.Checks, check)
+			}
+		}
+		failures = append(failures, failure)
+	}
+	return failures
+}
+
+// WriteMatrixTable - rows are the sources and columns the destinations
+func WriteMatrixTable(w io.Writer, matrix analyser.Matrix) error {
+	fmt.Fprintf(w, "Reachability on %s\n\n", toStringTraffic(matrix.Traffic))
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "from \\ to\t%s\t\n", strings.Join(matrix.Groups, "\t"))
+	for i, row := range matrix.Cells {
+		cells := []string{}
+		for _, cell := range row {
+			cells = append(cells, toStringMatrixCell(cell, true))
+		}
+		fmt.Fprintf(table, "%s\t%s\t\n", matrix.Groups[i], strings.Join(cells, "\t"))
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "\n✓ all pairs reachable, ~ some pairs reachable, × no pair reachable, ? couldnt be evaluated - reachable/all pairs")
+	return nil
+}
+
+// WriteMatrixCSV - first row and column are the group names, source groups in rows
+func WriteMatrixCSV(w io.Writer, matrix analyser.Matrix) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append([]string{"source"}, matrix.Groups...)); err != nil {
+		return err
+	}
+	for i, row := range matrix.Cells {
+		record := []string{matrix.Groups[i]}
+		for _, cell := range row {
+			record = append(record, toStringMatrixCell(cell, false))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+type htmlMatrixCell struct {
+	ID          string
+	Source      string
+	Destination string
+	Verdict     analyser.Verdict
+	Label       string
+	Failures    []matrixFailure
+}
+
+type htmlMatrixRow struct {
+	Name  string
+	Cells []htmlMatrixCell
+}
+
+type htmlMatrix struct {
+	Traffic string
+	Groups  []string
+	Rows    []htmlMatrixRow
+}
+
+var matrixTemplate = template.Must(template.New("matrix").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>cir matrix - {{.Traffic}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.4em 0.8em; text-align: center; }
+td.cell { cursor: pointer; }
+.reachable { background: #c8e6c9; }
+.partial { background: #fff3c4; }
+.unreachable { background: #ffcdd2; }
+.unknown { background: #e0e0e0; }
+.details { display: none; margin-top: 1.5em; }
+.details.shown { display: block; }
+.pass { color: #2e7d32; }
+.fail { color: #c62828; }
+.unknown-check { color: #f9a825; }
+</style>
+</head>
+<body>
+<h1>Reachability on {{.Traffic}}</h1>
+<p>Rows are the sources, columns the destinations. Click a cell to see the failing checks.</p>
+<table>
+<tr><th>from \ to</th>{{range .Groups}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><th>{{.Name}}</th>{{range .Cells}}<td class="cell {{.Verdict}}" data-details="{{.ID}}">{{.Label}}</td>{{end}}</tr>
+{{end}}</table>
+{{range .Rows}}{{range .Cells}}<div class="details" id="{{.ID}}">
+<h2>{{.Source}} to {{.Destination}} - {{.Label}}</h2>
+{{if .Failures}}{{range .Failures}}<h3>{{.Source}} to {{.Destination}} on {{.Traffic}}</h3>
+<ul>{{range .Checks}}<li class="{{if eq .Status "unknown"}}unknown-check{{else}}fail{{end}}">{{.Name}} - {{.Reason}} ({{.ReasonCode}})</li>{{end}}</ul>
+{{end}}{{else}}<p>No failing checks.</p>
+{{end}}</div>
+{{end}}{{end}}<script>
+document.querySelectorAll("td.cell").forEach(function (cell) {
+  cell.addEventListener("click", function () {
+    document.querySelectorAll(".details.shown").forEach(function (details) {
+      details.classList.remove("shown");
+    });
+    document.getElementById(cell.dataset.details).classList.add("shown");
+  });
+});
+</script>
+</body>
+</html>
+`))
+
+// WriteMatrixHTML - standalone page with the grid, clicking a cell shows the checks failing for its pairs
+func WriteMatrixHTML(w io.Writer, matrix analyser.Matrix) error {
+	page := htmlMatrix{Traffic: toStringTraffic(matrix.Traffic), Groups: matrix.Groups, Rows: []htmlMatrixRow{}}
+	for i, row := range matrix.Cells {
+		htmlRow := htmlMatrixRow{Name: matrix.Groups[i], Cells: []htmlMatrixCell{}}
+		for j, cell := range row {
+			htmlRow.Cells = append(htmlRow.Cells, htmlMatrixCell{
+				ID:          fmt.Sprintf("cell-%d-%d", i, j),
+				Source:      cell.Source,
+				Destination: cell.Destination,
+				Verdict:     cell.Verdict(),
+				Label:       toStringMatrixCell(cell, false),
+				Failures:    matrixFailures(cell),
+			})
+		}
+		page.Rows = append(page.Rows, htmlRow)
+	}
+	return matrixTemplate.Execute(w, page)
+}
+
+// WriteMatrix - writes the matrix as terminal table, csv or html page
+func WriteMatrix(w io.Writer, matrix analyser.Matrix, format string) error {
+	switch format {
+	case OutputTable:
+		return WriteMatrixTable(w, matrix)
+	case OutputCSV:
+		return WriteMatrixCSV(w, matrix)
+	case OutputHTML:
+		return WriteMatrixHTML(w, matrix)
+	}
+	return fmt.Errorf("output format '%s' is not supported - use table, csv or html", format)
+}
@@ -0,0 +1,47 @@
+package printer
+
+import (
+	"bytes"
+	"github.com/michal-franc/cir/internal/app/cir/analyser"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func partialMatrix() analyser.Matrix {
+	failing := analysisWithAllChecks(analyser.Check{Status: analyser.CheckPass})
+	failing.CanEnterDestination = &analyser.Check{Status: analyser.CheckFail, ReasonCode: analyser.ReasonCode("no_rule"), Reason: "no rule <allows> 443"}
+	return analyser.Matrix{
+		Groups:  []string{"api", "db"},
+		Traffic: []analyser.Traffic{analyser.NewPortTraffic(analyser.ProtocolTCP, 443)},
+		Cells: [][]analyser.MatrixCell{
+			{{Source: "api", Destination: "api"}, {Source: "api", Destination: "db", Pairs: 2, Reachable: 1, Failing: []analyser.Analysis{failing}}},
+			{{Source: "db", Destination: "api", Pairs: 2, Reachable: 2}, {Source: "db", Destination: "db", Pairs: 1}},
+		},
+	}
+}
+
+func TestMatrixTableAndCSVShowVerdictOfEveryCell(t *testing.T) {
+	var table bytes.Buffer
+	assert.Nil(t, WriteMatrix(&table, partialMatrix(), OutputTable))
+	assert.Contains(t, table.String(), "Reachability on tcp port 443")
+	assert.Regexp(t, `api\s+-\s+~ 1/2`, table.String())
+	assert.Regexp(t, `db\s+✓ 2/2\s+× 0/1`, table.String())
+
+	var csv bytes.Buffer
+	assert.Nil(t, WriteMatrix(&csv, partialMatrix(), OutputCSV))
+	assert.Equal(t, "source,api,db\napi,-,partial 1/2\ndb,reachable 2/2,unreachable 0/1\n", csv.String())
+
+	assert.EqualError(t, WriteMatrix(&csv, partialMatrix(), OutputJSON), "output format 'json' is not supported - use table, csv or html")
+}
+
+func TestMatrixHTMLListsFailingChecksOfTheCell(t *testing.T) {
+	var page bytes.Buffer
+
+	assert.Nil(t, WriteMatrix(&page, partialMatrix(), OutputHTML))
+
+	html := page.String()
+	assert.Contains(t, html, `<td class="cell partial" data-details="cell-0-1">partial 1/2</td>`)
+	assert.Contains(t, html, `<div class="details" id="cell-0-1">`)
+	assert.Contains(t, html, "destination_security_groups_ingress - no rule &lt;allows&gt; 443 (no_rule)")
+	assert.NotContains(t, html, "source_security_groups_egress")
+}
@@ -4,29 +4,125 @@ import (
 	"fmt"
 	"github.com/liamg/tml"
 	"github.com/michal-franc/cir/internal/app/cir/analyser"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
 )
 
-func printRedGreen(message string, b bool) {
-	if b {
-		tml.Printf("<green>✓</green> %s\n", message)
-	} else {
-		tml.Printf("<red>×</red> %s\n", message)
+func toStringStatus(status analyser.CheckStatus) string {
+	switch status {
+	case analyser.CheckPass:
+		return "<green>✓</green>"
+	case analyser.CheckUnknown:
+		return "<yellow>?</yellow>"
 	}
+	return "<red>×</red>"
+}
+
+func printStatus(message string, checks ...*analyser.Check) {
+	tml.Printf("%s %s\n", toStringStatus(analyser.CombineStatus(checks...)), message)
 }
 
 func printCheck(c analyser.Check) {
-	if c.IsPassing {
-		tml.Printf("<green>✓</green> -> %s\n", c.Reason)
-	} else {
-		tml.Printf("<red>×</red> -> %s\n", c.Reason)
+	if c.Status == analyser.CheckUnknown {
+		tml.Printf("%s -> %s (%s)\n", toStringStatus(c.Status), c.Reason, c.ReasonCode)
+		return
+	}
+	tml.Printf("%s -> %s\n", toStringStatus(c.Status), c.Reason)
+}
+
+// toStringEndpoint - owner is shown for interfaces found directly as their id alone doesnt tell what they are
+func toStringEndpoint(id string, interfaceID string, ip string, owner scanner.InterfaceOwner) string {
+	if owner.String() != "" {
+		return fmt.Sprintf("%s (%s %s - %s)", id, interfaceID, ip, owner)
+	}
+	return fmt.Sprintf("%s (%s %s)", id, interfaceID, ip)
+}
+
+func toStringSource(a analyser.Analysis) string {
+	return toStringEndpoint(a.SourceID, a.SourceInterfaceID, a.SourceIP, a.SourceOwner)
+}
+
+func toStringDestination(a analyser.Analysis) string {
+	return toStringEndpoint(a.DestinationID, a.DestinationInterfaceID, a.DestinationIP, a.DestinationOwner)
+}
+
+// toStringLeg - load balancer analysis mixes legs and ports so they are shown with every result
+func toStringLeg(a analyser.Analysis) string {
+	if a.Leg == "" {
+		return ""
+	}
+	return fmt.Sprintf("[%s %s] ", a.Leg, a.Traffic)
+}
+
+func toStringVerdict(verdict analyser.Verdict) string {
+	switch verdict {
+	case analyser.VerdictReachable:
+		return "<green>✓</green>"
+	case analyser.VerdictUnknown:
+		return "<yellow>?</yellow>"
+	}
+	return "<red>×</red>"
+}
+
+// summaryGroup - interface pairs sharing the same analysis
+type summaryGroup struct {
+	analysis     analyser.Analysis
+	sources      map[string]bool
+	destinations map[string]bool
+}
+
+// groupForSummary - groups analysis by equivalence group keeping the order of first occurrence
+func groupForSummary(listOfAnalysis []analyser.Analysis) []*summaryGroup {
+	groups := []*summaryGroup{}
+	byID := map[int]*summaryGroup{}
+	for _, a := range listOfAnalysis {
+		group, ok := byID[a.Group]
+		if !ok {
+			group = &summaryGroup{a, map[string]bool{}, map[string]bool{}}
+			byID[a.Group] = group
+			groups = append(groups, group)
+		}
+		group.sources[a.SourceInterfaceID] = true
+		group.destinations[a.DestinationInterfaceID] = true
+	}
+	return groups
+}
+
+func toStringOthers(count int) string {
+	if count <= 1 {
+		return ""
+	}
+	return fmt.Sprintf(" and %d similar", count-1)
+}
+
+func printSummaryGroup(group *summaryGroup) {
+	a := group.analysis
+	pairs := ""
+	if a.GroupSize > 1 {
+		pairs = fmt.Sprintf(" (%d pairs)", a.GroupSize)
 	}
+	tml.Printf("%s %s%s%s can reach %s%s%s\n", toStringVerdict(a.Verdict()), toStringLeg(a), toStringSource(a), toStringOthers(len(group.sources)),
+		toStringDestination(a), toStringOthers(len(group.destinations)), pairs)
 }
 
 // PrintSummary - prints quick summary of list of analysis
+// pairs of interfaces with the same network configuration are grouped into one line
+// unknown results are listed separately as they are not real denials but checks which couldnt be evaluated
 func PrintSummary(listOfAnalysis []analyser.Analysis) {
 	fmt.Println("\nSummary: (if you want more details use --detailed flag)")
-	for _, a := range listOfAnalysis {
-		printRedGreen(fmt.Sprintf("%s can reach %s", a.SourceID, a.DestinationID), a.CanTheyConnect())
+	unknownGroups := []*summaryGroup{}
+	for _, group := range groupForSummary(listOfAnalysis) {
+		if group.analysis.Verdict() == analyser.VerdictUnknown {
+			unknownGroups = append(unknownGroups, group)
+			continue
+		}
+		printSummaryGroup(group)
+	}
+
+	if len(unknownGroups) > 0 {
+		fmt.Println("\nUnknown: (not supported yet or aws api errors)")
+		for _, group := range unknownGroups {
+			printSummaryGroup(group)
+		}
 	}
 }
 
@@ -36,29 +132,70 @@ func PrintAnalysis(analysis analyser.Analysis, detailed bool) {
 		return
 	}
 
-	tml.Printf("<yellow>Check if %s can reach %s on port %d</yellow>\n", analysis.SourceID, analysis.DestinationID, analysis.DestinationPort)
+	tml.Printf("<yellow>Check if %s can reach %s on %s over %s</yellow>\n", toStringSource(analysis), toStringDestination(analysis),
+		analysis.Traffic, analysis.AddressFamily)
 	tml.Println("<yellow>---------------------------</yellow>")
 
-	if analysis.AreInTheSameVpc {
+	switch analysis.Leg {
+	case analyser.LegClientToLoadBalancer:
+		tml.Println("(client to load balancer listener)")
+	case analyser.LegLoadBalancerToTarget:
+		tml.Println("(load balancer node to target)")
+	case analyser.LegClientToTarget:
+		tml.Println("(load balancer node to target - client ip preserved so target has to allow the client)")
+	case analyser.LegHealthCheck:
+		tml.Println("(load balancer node health check of target)")
+	}
+
+	if analysis.GroupSize > 1 {
+		tml.Printf("(same network configuration - result applies to %d interface pairs)\n", analysis.GroupSize)
+	}
+
+	if analysis.IsToInternet {
+		tml.Println("(dest - in the internet)")
+	} else if analysis.IsFromInternet {
+		tml.Printf("(source - in the internet, dest reached on public ip %s)\n", analysis.DestinationPublicIP)
+	} else if analysis.AreInTheSameVpc {
 		tml.Println("(source and dest - in the same vpc)")
 	} else {
 		tml.Println("(source and dest - in different vpcs)")
 	}
 
-	printRedGreen("security groups:", analysis.CanEnterDestination.IsPassing && analysis.CanEscapeSource.IsPassing)
+	printStatus("security groups:", analysis.CanEnterDestination, analysis.CanEscapeSource)
 	printCheck(*analysis.CanEscapeSource)
 	printCheck(*analysis.CanEnterDestination)
 	fmt.Println()
-	printRedGreen("subnets:", analysis.SourceSubnetHasRoute.IsPassing && analysis.DestinationSubnetHasRoute.IsPassing)
+	printStatus("subnets:", analysis.SourceSubnetHasRoute, analysis.DestinationSubnetHasRoute)
 	printCheck(*analysis.SourceSubnetHasRoute)
 	if !analysis.AreInTheSameVpc { // display only dest subnet if diff vpc
 		printCheck(*analysis.DestinationSubnetHasRoute)
 	}
 	fmt.Println()
-	if !analysis.AreInTheSameVpc {
-		printRedGreen("vpc connection:", analysis.ConnectionBetweenVPCsIsActive.IsPassing && analysis.ConnectionBetweenVPCsIsValid.IsPassing)
+	printStatus("network acls:", analysis.SourceNaclAllowsOutbound, analysis.DestinationNaclAllowsInbound,
+		analysis.DestinationNaclAllowsReturn, analysis.SourceNaclAllowsReturn)
+	printCheck(*analysis.SourceNaclAllowsOutbound)
+	if !analysis.AreInTheSameSubnet { // same subnet traffic doesnt go through network acl
+		printCheck(*analysis.DestinationNaclAllowsInbound)
+		printCheck(*analysis.DestinationNaclAllowsReturn)
+		printCheck(*analysis.SourceNaclAllowsReturn)
+	}
+	fmt.Println()
+	if analysis.IsToInternet || analysis.IsFromInternet {
+		printStatus("internet:", analysis.NatGatewayIsAvailable, analysis.NatSubnetNaclAllowsTraffic, analysis.InternetGatewayIsReachable)
+		printCheck(*analysis.NatGatewayIsAvailable)
+		printCheck(*analysis.NatSubnetNaclAllowsTraffic)
+		printCheck(*analysis.InternetGatewayIsReachable)
+	} else if !analysis.AreInTheSameVpc {
+		printStatus("vpc connection:", analysis.ConnectionBetweenVPCsIsActive, analysis.ConnectionBetweenVPCsIsValid)
 		printCheck(*analysis.ConnectionBetweenVPCsIsValid)
 		printCheck(*analysis.ConnectionBetweenVPCsIsActive)
 	}
+	if analysis.TransitGatewayID != "" {
+		fmt.Println()
+		printStatus(fmt.Sprintf("tgw routing (%s):", analysis.TransitGatewayID), analysis.TransitGatewayRouteToDestination,
+			analysis.TransitGatewayRouteToSource)
+		printCheck(*analysis.TransitGatewayRouteToDestination)
+		printCheck(*analysis.TransitGatewayRouteToSource)
+	}
 	tml.Println("<yellow>---------------------------</yellow>")
 }
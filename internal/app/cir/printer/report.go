@@ -0,0 +1,149 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/michal-franc/cir/internal/app/cir/analyser"
+	"gopkg.in/yaml.v3"
+	"io"
+)
+
+// ReportSchemaVersion - version of the json/yaml report, bumped when fields are removed or change meaning
+const ReportSchemaVersion = 1
+
+// Output formats supported by cir run
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+)
+
+// Report - machine readable result of the whole run
+type Report struct {
+	SchemaVersion int              `json:"schema_version" yaml:"schema_version"`
+	Verdict       analyser.Verdict `json:"verdict" yaml:"verdict"`
+	Results       []ReportResult   `json:"results" yaml:"results"`
+}
+
+// ReportResult - result of checking if source interface can reach destination interface
+type ReportResult struct {
+	Source           ReportEndpoint         `json:"source" yaml:"source"`
+	Destination      ReportEndpoint         `json:"destination" yaml:"destination"`
+	Traffic          ReportTraffic          `json:"traffic" yaml:"traffic"`
+	AddressFamily    analyser.AddressFamily `json:"address_family" yaml:"address_family"`
+	Leg              analyser.Leg           `json:"leg,omitempty" yaml:"leg,omitempty"`
+	SameVpc          bool                   `json:"same_vpc" yaml:"same_vpc"`
+	SameSubnet       bool                   `json:"same_subnet" yaml:"same_subnet"`
+	TransitGatewayID string                 `json:"transit_gateway_id,omitempty" yaml:"transit_gateway_id,omitempty"`
+	ToInternet       bool                   `json:"to_internet,omitempty" yaml:"to_internet,omitempty"`
+	FromInternet     bool                   `json:"from_internet,omitempty" yaml:"from_internet,omitempty"`
+	NatGatewayID     string                 `json:"nat_gateway_id,omitempty" yaml:"nat_gateway_id,omitempty"`
+	Verdict          analyser.Verdict       `json:"verdict" yaml:"verdict"`
+	// results with the same group share the checks
+	Group  int           `json:"group" yaml:"group"`
+	Checks []ReportCheck `json:"checks" yaml:"checks"`
+}
+
+// ReportEndpoint - resource and its network interface
+type ReportEndpoint struct {
+	ID          string `json:"id" yaml:"id"`
+	InterfaceID string `json:"interface_id" yaml:"interface_id"`
+	IP          string `json:"ip" yaml:"ip"`
+	// set for interfaces found by eni or ip query
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	RequesterID string `json:"requester_id,omitempty" yaml:"requester_id,omitempty"`
+	// address the internet reaches the destination on
+	PublicIP string `json:"public_ip,omitempty" yaml:"public_ip,omitempty"`
+}
+
+// ReportTraffic - ports are set for tcp and udp, icmp type and code for icmp
+type ReportTraffic struct {
+	Protocol analyser.Protocol `json:"protocol" yaml:"protocol"`
+	FromPort *int32            `json:"from_port,omitempty" yaml:"from_port,omitempty"`
+	ToPort   *int32            `json:"to_port,omitempty" yaml:"to_port,omitempty"`
+	IcmpType *int32            `json:"icmp_type,omitempty" yaml:"icmp_type,omitempty"`
+	IcmpCode *int32            `json:"icmp_code,omitempty" yaml:"icmp_code,omitempty"`
+}
+
+// ReportCheck - single check with stable name
+type ReportCheck struct {
+	Name       string               `json:"name" yaml:"name"`
+	Status     analyser.CheckStatus `json:"status" yaml:"status"`
+	ReasonCode analyser.ReasonCode  `json:"reason_code" yaml:"reason_code"`
+	Reason     string               `json:"reason" yaml:"reason"`
+}
+
+func toReportCheck(name string, check *analyser.Check) ReportCheck {
+	return ReportCheck{name, check.Status, check.ReasonCode, check.Reason}
+}
+
+func toReportTraffic(traffic analyser.Traffic) ReportTraffic {
+	if traffic.Protocol.IsICMP() {
+		return ReportTraffic{Protocol: traffic.Protocol, IcmpType: &traffic.IcmpType, IcmpCode: &traffic.IcmpCode}
+	}
+	return ReportTraffic{Protocol: traffic.Protocol, FromPort: &traffic.FromPort, ToPort: &traffic.ToPort}
+}
+
+func toReportResult(a analyser.Analysis) ReportResult {
+	return ReportResult{
+		Source:           ReportEndpoint{a.SourceID, a.SourceInterfaceID, a.SourceIP, a.SourceOwner.Description, a.SourceOwner.RequesterID, ""},
+		Destination:      ReportEndpoint{a.DestinationID, a.DestinationInterfaceID, a.DestinationIP, a.DestinationOwner.Description, a.DestinationOwner.RequesterID, a.DestinationPublicIP},
+		Traffic:          toReportTraffic(a.Traffic),
+		AddressFamily:    a.AddressFamily,
+		Leg:              a.Leg,
+		SameVpc:          a.AreInTheSameVpc,
+		SameSubnet:       a.AreInTheSameSubnet,
+		TransitGatewayID: a.TransitGatewayID,
+		ToInternet:       a.IsToInternet,
+		FromInternet:     a.IsFromInternet,
+		NatGatewayID:     a.NatGatewayID,
+		Verdict:          a.Verdict(),
+		Group:            a.Group,
+		Checks: []ReportCheck{
+			toReportCheck("source_security_groups_egress", a.CanEscapeSource),
+			toReportCheck("destination_security_groups_ingress", a.CanEnterDestination),
+			toReportCheck("source_subnet_route", a.SourceSubnetHasRoute),
+			toReportCheck("destination_subnet_route", a.DestinationSubnetHasRoute),
+			toReportCheck("vpc_connection_valid", a.ConnectionBetweenVPCsIsValid),
+			toReportCheck("vpc_connection_active", a.ConnectionBetweenVPCsIsActive),
+			toReportCheck("tgw_route_to_destination", a.TransitGatewayRouteToDestination),
+			toReportCheck("tgw_route_to_source", a.TransitGatewayRouteToSource),
+			toReportCheck("source_nacl_outbound", a.SourceNaclAllowsOutbound),
+			toReportCheck("destination_nacl_inbound", a.DestinationNaclAllowsInbound),
+			toReportCheck("destination_nacl_return", a.DestinationNaclAllowsReturn),
+			toReportCheck("source_nacl_return", a.SourceNaclAllowsReturn),
+			toReportCheck("nat_gateway_available", a.NatGatewayIsAvailable),
+			toReportCheck("nat_subnet_nacl", a.NatSubnetNaclAllowsTraffic),
+			toReportCheck("internet_gateway_reachable", a.InternetGatewayIsReachable),
+		},
+	}
+}
+
+// NewReport - converts list of analysis to report
+func NewReport(listOfAnalysis []analyser.Analysis) Report {
+	results := []ReportResult{}
+	for _, a := range listOfAnalysis {
+		results = append(results, toReportResult(a))
+	}
+	return Report{ReportSchemaVersion, analyser.OverallVerdict(listOfAnalysis), results}
+}
+
+// WriteReport - writes report in json or yaml format
+func WriteReport(w io.Writer, listOfAnalysis []analyser.Analysis, format string) error {
+	return encodeReport(w, NewReport(listOfAnalysis), format)
+}
+
+// encodeReport - json and yaml encoding shared by reports of all commands
+func encodeReport(w io.Writer, report interface{}, format string) error {
+	switch format {
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case OutputYAML:
+		encoder := yaml.NewEncoder(w)
+		defer encoder.Close()
+		return encoder.Encode(report)
+	}
+	return fmt.Errorf("output format '%s' is not supported - use text, json or yaml", format)
+}
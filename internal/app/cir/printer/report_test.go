@@ -0,0 +1,93 @@
+package printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/michal-franc/cir/internal/app/cir/analyser"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func analysisWithAllChecks(check analyser.Check) analyser.Analysis {
+	c := func() *analyser.Check { copied := check; return &copied }
+	return analyser.Analysis{
+		SourceID: "i-source", SourceInterfaceID: "eni-source", SourceIP: "10.0.0.1",
+		DestinationID: "i-destination", DestinationInterfaceID: "eni-destination", DestinationIP: "10.0.0.2",
+		Traffic:         analyser.NewPortTraffic(analyser.ProtocolTCP, 443),
+		CanEscapeSource: c(), CanEnterDestination: c(), SourceSubnetHasRoute: c(), DestinationSubnetHasRoute: c(),
+		ConnectionBetweenVPCsIsValid: c(), ConnectionBetweenVPCsIsActive: c(),
+		TransitGatewayRouteToDestination: c(), TransitGatewayRouteToSource: c(),
+		SourceNaclAllowsOutbound: c(), DestinationNaclAllowsInbound: c(), DestinationNaclAllowsReturn: c(), SourceNaclAllowsReturn: c(),
+		NatGatewayIsAvailable: c(), NatSubnetNaclAllowsTraffic: c(), InternetGatewayIsReachable: c(),
+	}
+}
+
+func TestJSONReportContainsEveryCheckAndVerdict(t *testing.T) {
+	reachable := analysisWithAllChecks(analyser.Check{Status: analyser.CheckPass, ReasonCode: analyser.ReasonSameVpc, Reason: "same vpc"})
+	unreachable := analysisWithAllChecks(analyser.Check{Status: analyser.CheckFail, ReasonCode: analyser.ReasonRouteNotFound, Reason: "no route"})
+
+	out := &bytes.Buffer{}
+	assert.Nil(t, WriteReport(out, []analyser.Analysis{reachable, unreachable}, OutputJSON))
+
+	report := Report{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, ReportSchemaVersion, report.SchemaVersion)
+	assert.Equal(t, analyser.VerdictUnreachable, report.Verdict)
+	assert.Len(t, report.Results, 2)
+	assert.Equal(t, analyser.VerdictReachable, report.Results[0].Verdict)
+	assert.Equal(t, "eni-source", report.Results[0].Source.InterfaceID)
+	assert.Equal(t, int32(443), *report.Results[0].Traffic.FromPort)
+	assert.Nil(t, report.Results[0].Traffic.IcmpType)
+	assert.Len(t, report.Results[1].Checks, 15)
+	assert.Equal(t, analyser.ReasonRouteNotFound, report.Results[1].Checks[0].ReasonCode)
+}
+
+func TestYAMLReportUsesSnakeCaseFields(t *testing.T) {
+	out := &bytes.Buffer{}
+	assert.Nil(t, WriteReport(out, []analyser.Analysis{}, OutputYAML))
+
+	assert.Contains(t, out.String(), "schema_version: 1")
+	assert.Contains(t, out.String(), "verdict: unknown")
+}
+
+func TestExposureReportListsSourcesAndInstances(t *testing.T) {
+	pass := &analyser.Check{Status: analyser.CheckPass, ReasonCode: analyser.ReasonSameVpc, Reason: "same vpc"}
+	source := analyser.ExposedSource{
+		DestinationID: "i-db", DestinationInterfaceID: "eni-db", DestinationIP: "10.0.0.9",
+		Traffic: analyser.NewPortTraffic(analyser.ProtocolTCP, 5432), Kind: analyser.SourceVpc, Cidr: "10.0.0.0/16", VpcID: "vpc-1",
+		CanEnterDestination: pass, DestinationSubnetHasRoute: pass, ConnectionIsActive: pass, DestinationNaclAllowsInbound: pass, DestinationNaclAllowsReturn: pass,
+	}
+	instance := analysisWithAllChecks(*pass)
+
+	out := &bytes.Buffer{}
+	assert.Nil(t, WriteExposureReport(out, []analyser.ExposedSource{source}, []analyser.Analysis{instance}, OutputJSON))
+
+	report := ExposureReport{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, analyser.VerdictReachable, report.Verdict)
+	assert.Len(t, report.Sources, 1)
+	assert.Equal(t, analyser.SourceVpc, report.Sources[0].Kind)
+	assert.Equal(t, "10.0.0.0/16", report.Sources[0].Cidr)
+	assert.Len(t, report.Sources[0].Checks, 5)
+	assert.Len(t, report.Instances, 1)
+	assert.Equal(t, "eni-source", report.Instances[0].Source.InterfaceID)
+}
+
+func TestAllowedPortsReportListsPortRanges(t *testing.T) {
+	allowedPorts := analyser.AllowedPorts{
+		SourceID: "i-source", SourceInterfaceID: "eni-source", SourceIP: "10.0.0.1",
+		DestinationID: "i-destination", DestinationInterfaceID: "eni-destination", DestinationIP: "10.0.0.2",
+		Reachable: []analyser.PortRange{{Protocol: analyser.ProtocolTCP, From: 8000, To: 8100}},
+		Unknown:   []analyser.PortRange{},
+		Paths:     []analyser.Analysis{analysisWithAllChecks(analyser.Check{Status: analyser.CheckPass})},
+	}
+
+	out := &bytes.Buffer{}
+	assert.Nil(t, WriteAllowedPortsReport(out, []analyser.AllowedPorts{allowedPorts}, OutputJSON))
+
+	report := AllowedPortsReport{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, analyser.VerdictReachable, report.Verdict)
+	assert.Equal(t, []ReportPortRange{{analyser.ProtocolTCP, 8000, 8100}}, report.Results[0].Reachable)
+	assert.Len(t, report.Results[0].Paths, 1)
+}
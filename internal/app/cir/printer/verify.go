@@ -0,0 +1,122 @@
+package printer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/liamg/tml"
+	"github.com/michal-franc/cir/internal/app/cir/analyser"
+	"github.com/michal-franc/cir/internal/app/cir/verify"
+	"io"
+	"strings"
+)
+
+func toStringAssertionStatus(status verify.Status) string {
+	switch status {
+	case verify.StatusHolds:
+		return "<green>✓</green>"
+	case verify.StatusUnknown, verify.StatusError:
+		return "<yellow>?</yellow>"
+	}
+	return "<red>×</red>"
+}
+
+// evidenceLines - pair of every evidence group with the checks which made it contradict the assertion
+// pairs contradicting unreachable assertion are reachable so they have no failing checks to show
+func evidenceLines(result verify.Result) []string {
+	lines := []string{}
+	for _, a := range result.Evidence {
+		lines = append(lines, fmt.Sprintf("%s%s -> %s%s", toStringLeg(a), toStringSource(a), toStringDestination(a), toStringOthers(a.GroupSize)))
+		for _, check := range a.Checks() {
+			if !check.IsPassing() {
+				lines = append(lines, fmt.Sprintf("  %s - %s (%s)", check.Status, check.Reason, check.ReasonCode))
+			}
+		}
+	}
+	return lines
+}
+
+// PrintVerify - lists every assertion with its status, the pairs deciding violated and unknown ones are listed under them
+func PrintVerify(results []verify.Result) {
+	counts := map[verify.Status]int{}
+	for _, result := range results {
+		counts[result.Status]++
+		tml.Printf("%s %s - %s\n", toStringAssertionStatus(result.Status), result.Assertion.Name, result.Message())
+		if result.Status == verify.StatusHolds {
+			continue
+		}
+		for _, line := range evidenceLines(result) {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+
+	fmt.Printf("\n%d assertions, %d violated, %d unknown, %d errors\n", len(results),
+		counts[verify.StatusViolated], counts[verify.StatusUnknown], counts[verify.StatusError])
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Details string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+func toStringAssertionDetails(result verify.Result) string {
+	a := result.Assertion
+	details := fmt.Sprintf("from %s to %s", a.From, a.To)
+	if a.Port != 0 || a.Protocol == string(analyser.ProtocolICMP) {
+		details = fmt.Sprintf("%s on %s", details, a.Traffic())
+	}
+	return strings.Join(append([]string{details}, evidenceLines(result)...), "\n")
+}
+
+// WriteJUnit - every assertion is a test case, violated ones are failures while unknown and errored ones are errors
+// as the pipeline should tell broken intent from assertion which couldnt be checked
+func WriteJUnit(w io.Writer, suiteName string, results []verify.Result) error {
+	suite := junitTestSuite{Name: suiteName, Tests: len(results), TestCases: []junitTestCase{}}
+	total := 0.0
+	for _, result := range results {
+		seconds := result.Duration.Seconds()
+		total += seconds
+		testCase := junitTestCase{Name: result.Assertion.Name, ClassName: "cir.verify", Time: fmt.Sprintf("%.3f", seconds)}
+
+		failure := &junitFailure{Message: result.Message(), Type: string(result.Status), Details: toStringAssertionDetails(result)}
+		switch result.Status {
+		case verify.StatusViolated:
+			suite.Failures++
+			testCase.Failure = failure
+		case verify.StatusUnknown, verify.StatusError:
+			suite.Errors++
+			testCase.Error = failure
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	suite.Time = fmt.Sprintf("%.3f", total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,33 @@
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/michal-franc/cir/internal/app/cir/analyser"
+	"github.com/michal-franc/cir/internal/app/cir/verify"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestJUnitReportHasTestCasePerAssertion(t *testing.T) {
+	unreachable := analysisWithAllChecks(analyser.Check{Status: analyser.CheckPass})
+	unreachable.CanEnterDestination = &analyser.Check{Status: analyser.CheckFail, ReasonCode: analyser.ReasonCode("no_rule"), Reason: "no rule allows 5432"}
+	mustReach := verify.Assertion{Name: "api reaches db", From: "tag:Tier=api", To: "tag:Tier=db", Protocol: "tcp", Port: 5432, Expect: verify.MustReach}
+	results := []verify.Result{
+		{Assertion: mustReach, Status: verify.StatusViolated, Pairs: 1, Mismatched: 1, Evidence: []analyser.Analysis{unreachable}, Duration: 1500 * time.Millisecond},
+		{Assertion: verify.Assertion{Name: "batch cant reach db", Expect: verify.MustNotReach}, Status: verify.StatusHolds, Pairs: 2},
+		{Assertion: verify.Assertion{Name: "web reaches cache", Expect: verify.MustReach}, Status: verify.StatusError, Err: fmt.Errorf("ec2 with query 'name:web' not found")},
+	}
+
+	var report bytes.Buffer
+	assert.Nil(t, WriteJUnit(&report, "expectations.yaml", results))
+
+	xml := report.String()
+	assert.Contains(t, xml, `<testsuite name="expectations.yaml" tests="3" failures="1" errors="1" time="1.500">`)
+	assert.Contains(t, xml, `<testcase name="api reaches db" classname="cir.verify" time="1.500">`)
+	assert.Contains(t, xml, `<failure message="expected reachable but 1 of 1 pairs are unreachable" type="violated">from tag:Tier=api to tag:Tier=db on tcp port 5432`)
+	assert.Contains(t, xml, "fail - no rule allows 5432 (no_rule)")
+	assert.Contains(t, xml, `<testcase name="batch cant reach db" classname="cir.verify" time="0.000"></testcase>`)
+	assert.Contains(t, xml, `<error message="ec2 with query &#39;name:web&#39; not found" type="error">`)
+}
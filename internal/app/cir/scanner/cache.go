@@ -0,0 +1,205 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	log "github.com/sirupsen/logrus"
+	"sort"
+)
+
+// describeBatchSize - max number of ids or filter values sent in single describe call
+const describeBatchSize = 100
+
+// resourceCache - security groups, route tables, network acls and prefix lists of all scanned resources
+// loaded in batches once per run and shared by sources and destinations
+type resourceCache struct {
+	securityGroups map[string]types.SecurityGroup
+	// route tables explicitly associated with subnet by subnet id
+	subnetRouteTables map[string]types.RouteTable
+	// main route tables by vpc id
+	mainRouteTables   map[string]types.RouteTable
+	subnetNetworkAcls map[string]types.NetworkAcl
+	prefixLists       PrefixLists
+}
+
+func newResourceCache() *resourceCache {
+	return &resourceCache{
+		securityGroups:    map[string]types.SecurityGroup{},
+		subnetRouteTables: map[string]types.RouteTable{},
+		mainRouteTables:   map[string]types.RouteTable{},
+		subnetNetworkAcls: map[string]types.NetworkAcl{},
+		prefixLists:       PrefixLists{},
+	}
+}
+
+// batches - splits ids into chunks of describeBatchSize
+func batches(ids []string) [][]string {
+	result := [][]string{}
+	for start := 0; start < len(ids); start += describeBatchSize {
+		end := start + describeBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		result = append(result, ids[start:end])
+	}
+	return result
+}
+
+// sortedKeys - deterministic order keeps the calls stable which matters for snapshot replay
+func sortedKeys(set map[string]bool) []string {
+	keys := []string{}
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// loadResourceCache - fetches everything needed by network interfaces of the resources
+func loadResourceCache(resources []resource, client EC2API) (*resourceCache, error) {
+	groupIDs := map[string]bool{}
+	subnetIDs := map[string]bool{}
+	subnetVpcIDs := map[string]string{}
+	for _, r := range resources {
+		for _, ref := range r.Interfaces {
+			if ref.Internet {
+				continue
+			}
+			for _, groupID := range ref.GroupIDs {
+				groupIDs[groupID] = true
+			}
+			subnetIDs[ref.SubnetID] = true
+			subnetVpcIDs[ref.SubnetID] = ref.VpcID
+		}
+	}
+
+	cache := newResourceCache()
+	if err := cache.loadSecurityGroups(sortedKeys(groupIDs), client); err != nil {
+		return nil, err
+	}
+	if err := cache.loadRouteTables(sortedKeys(subnetIDs), subnetVpcIDs, client); err != nil {
+		return nil, err
+	}
+	if err := cache.loadNetworkAcls(sortedKeys(subnetIDs), client); err != nil {
+		return nil, err
+	}
+	cache.loadPrefixLists(client)
+	return cache, nil
+}
+
+func (c *resourceCache) loadSecurityGroups(groupIDs []string, client EC2API) error {
+	for _, batch := range batches(groupIDs) {
+		log.Debugf("looking for %d security groups", len(batch))
+		var nextToken *string
+		for {
+			result, err := client.DescribeSecurityGroups(context.Background(), &ec2.DescribeSecurityGroupsInput{
+				GroupIds:  batch,
+				NextToken: nextToken,
+			})
+			if err != nil {
+				return fmt.Errorf("error when looking for security groups %s", err)
+			}
+			for _, securityGroup := range result.SecurityGroups {
+				c.securityGroups[*securityGroup.GroupId] = securityGroup
+			}
+			if result.NextToken == nil {
+				break
+			}
+			nextToken = result.NextToken
+		}
+	}
+	return nil
+}
+
+// loadRouteTables - loads route tables associated with subnets, main route tables are loaded only for vpcs
+// which have subnets without explicit association
+func (c *resourceCache) loadRouteTables(subnetIDs []string, subnetVpcIDs map[string]string, client EC2API) error {
+	for _, batch := range batches(subnetIDs) {
+		log.Debugf("looking for route tables of %d subnets", len(batch))
+		routeTables, err := describeRouteTables(client, []types.Filter{
+			{Name: aws.String("association.subnet-id"), Values: batch},
+		})
+		if err != nil {
+			return err
+		}
+		for _, routeTable := range routeTables {
+			for _, association := range routeTable.Associations {
+				if association.SubnetId != nil {
+					c.subnetRouteTables[*association.SubnetId] = routeTable
+				}
+			}
+		}
+	}
+
+	vpcIDs := map[string]bool{}
+	for _, subnetID := range subnetIDs {
+		if _, ok := c.subnetRouteTables[subnetID]; !ok && subnetVpcIDs[subnetID] != "" {
+			vpcIDs[subnetVpcIDs[subnetID]] = true
+		}
+	}
+
+	for _, batch := range batches(sortedKeys(vpcIDs)) {
+		log.Debugf("looking for main route tables of %d vpcs", len(batch))
+		routeTables, err := describeRouteTables(client, []types.Filter{
+			{Name: aws.String("association.main"), Values: []string{"true"}},
+			{Name: aws.String("vpc-id"), Values: batch},
+		})
+		if err != nil {
+			return err
+		}
+		for _, routeTable := range routeTables {
+			c.mainRouteTables[*routeTable.VpcId] = routeTable
+		}
+	}
+	return nil
+}
+
+func describeRouteTables(client EC2API, filters []types.Filter) ([]types.RouteTable, error) {
+	routeTables := []types.RouteTable{}
+	var nextToken *string
+	for {
+		result, err := client.DescribeRouteTables(context.Background(), &ec2.DescribeRouteTablesInput{
+			Filters:   filters,
+			NextToken: nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error when looking for route table %s", err)
+		}
+		routeTables = append(routeTables, result.RouteTables...)
+		if result.NextToken == nil {
+			return routeTables, nil
+		}
+		nextToken = result.NextToken
+	}
+}
+
+func (c *resourceCache) loadNetworkAcls(subnetIDs []string, client EC2API) error {
+	for _, batch := range batches(subnetIDs) {
+		log.Debugf("looking for network acls of %d subnets", len(batch))
+		var nextToken *string
+		for {
+			result, err := client.DescribeNetworkAcls(context.Background(), &ec2.DescribeNetworkAclsInput{
+				Filters:   []types.Filter{{Name: aws.String("association.subnet-id"), Values: batch}},
+				NextToken: nextToken,
+			})
+			if err != nil {
+				return fmt.Errorf("error when looking for network acl %s", err)
+			}
+			for _, networkAcl := range result.NetworkAcls {
+				for _, association := range networkAcl.Associations {
+					if association.SubnetId != nil {
+						c.subnetNetworkAcls[*association.SubnetId] = networkAcl
+					}
+				}
+			}
+			if result.NextToken == nil {
+				break
+			}
+			nextToken = result.NextToken
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+// ecsAttachmentENI - attachment of the task running in awsvpc network mode holding its network interface
+const ecsAttachmentENI = "ElasticNetworkInterface"
+
+const ecsDetailNetworkInterfaceID = "networkInterfaceId"
+
+// lastARNSegment - name or id from ecs arn eg. arn:aws:ecs:eu-west-1:123:task/my-cluster/0123 -> 0123
+func lastARNSegment(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
+
+// findECSTasks - query is cluster/service or cluster for all its tasks, service can use wildcards
+// only running tasks in awsvpc network mode have their own network interface, other tasks are skipped
+func findECSTasks(query QueryTerm, clients AwsClients) ([]resource, error) {
+	if clients.ECS == nil {
+		return nil, fmt.Errorf("ecs client not configured")
+	}
+
+	clusterAndService := strings.SplitN(query.Value, "/", 2)
+	cluster := clusterAndService[0]
+	if strings.ContainsAny(cluster, "*?") {
+		return nil, fmt.Errorf("%s query '%s' - cluster cant use wildcards", query.Kind, query.Value)
+	}
+
+	services := []*string{nil}
+	if len(clusterAndService) > 1 {
+		serviceNames, err := findECSServices(cluster, QueryTerm{Kind: query.Kind, Value: clusterAndService[1]}, clients.ECS)
+		if err != nil {
+			return nil, err
+		}
+		services = []*string{}
+		for _, serviceName := range serviceNames {
+			services = append(services, aws.String(serviceName))
+		}
+	}
+
+	taskArns := []string{}
+	for _, service := range services {
+		serviceTaskArns, err := listECSTasks(cluster, service, clients.ECS)
+		if err != nil {
+			return nil, err
+		}
+		taskArns = append(taskArns, serviceTaskArns...)
+	}
+
+	tasks := []types.Task{}
+	for _, batch := range batches(taskArns) {
+		result, err := clients.ECS.DescribeTasks(context.Background(), &ecs.DescribeTasksInput{Cluster: aws.String(cluster), Tasks: batch})
+		if err != nil {
+			return nil, fmt.Errorf("error when looking for ecs tasks %s", err)
+		}
+		tasks = append(tasks, result.Tasks...)
+	}
+
+	taskENIs := map[string]string{}
+	eniIDs := map[string]bool{}
+	for _, task := range tasks {
+		eniID := taskNetworkInterfaceID(task)
+		if eniID == "" {
+			log.Warnf("ecs task %s doesnt use awsvpc network mode - skipping", *task.TaskArn)
+			continue
+		}
+		taskENIs[*task.TaskArn] = eniID
+		eniIDs[eniID] = true
+	}
+
+	enis := map[string]ec2types.NetworkInterface{}
+	for _, batch := range batches(sortedKeys(eniIDs)) {
+		batchENIs, err := describeNetworkInterfaces(clients.EC2, []ec2types.Filter{{Name: aws.String("network-interface-id"), Values: batch}})
+		if err != nil {
+			return nil, err
+		}
+		for _, eni := range batchENIs {
+			enis[*eni.NetworkInterfaceId] = eni
+		}
+	}
+
+	resources := []resource{}
+	for _, task := range tasks {
+		eni, ok := enis[taskENIs[*task.TaskArn]]
+		if !ok {
+			continue
+		}
+		ref := eniToInterfaceRef(eni, "")
+		resources = append(resources, resource{
+			ID:         fmt.Sprintf("%s/%s", cluster, lastARNSegment(*task.TaskArn)),
+			VpcID:      ref.VpcID,
+			Interfaces: []interfaceRef{ref},
+		})
+	}
+
+	if len(resources) <= 0 {
+		return nil, fmt.Errorf("%s with query '%s' not found", query.Kind, query.Value)
+	}
+	return resources, nil
+}
+
+// findECSServices - ecs api doesnt support wildcards so services of the cluster are listed and matched locally
+func findECSServices(cluster string, query QueryTerm, client ECSAPI) ([]string, error) {
+	if !query.HasWildcard() {
+		return []string{query.Value}, nil
+	}
+
+	serviceNames := []string{}
+	input := &ecs.ListServicesInput{Cluster: aws.String(cluster)}
+	for {
+		result, err := client.ListServices(context.Background(), input)
+		if err != nil {
+			return nil, fmt.Errorf("error when looking for ecs services %s", err)
+		}
+		for _, serviceArn := range result.ServiceArns {
+			if query.Matches(lastARNSegment(serviceArn)) {
+				serviceNames = append(serviceNames, lastARNSegment(serviceArn))
+			}
+		}
+		if result.NextToken == nil {
+			return serviceNames, nil
+		}
+		input.NextToken = result.NextToken
+	}
+}
+
+func listECSTasks(cluster string, service *string, client ECSAPI) ([]string, error) {
+	taskArns := []string{}
+	input := &ecs.ListTasksInput{Cluster: aws.String(cluster), ServiceName: service, DesiredStatus: types.DesiredStatusRunning}
+	for {
+		result, err := client.ListTasks(context.Background(), input)
+		if err != nil {
+			return nil, fmt.Errorf("error when looking for ecs tasks %s", err)
+		}
+		taskArns = append(taskArns, result.TaskArns...)
+		if result.NextToken == nil {
+			return taskArns, nil
+		}
+		input.NextToken = result.NextToken
+	}
+}
+
+// taskNetworkInterfaceID - empty if the task doesnt have its own network interface
+func taskNetworkInterfaceID(task types.Task) string {
+	for _, attachment := range task.Attachments {
+		if aws.ToString(attachment.Type) != ecsAttachmentENI {
+			continue
+		}
+		for _, detail := range attachment.Details {
+			if aws.ToString(detail.Name) == ecsDetailNetworkInterfaceID {
+				return aws.ToString(detail.Value)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package scanner
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// fakeTasks - cluster 'prod' with services 'api' running awsvpc task and 'worker' running bridge mode task
+type fakeTasks struct {
+	*fakeClient
+}
+
+func (f *fakeTasks) ListServices(ctx context.Context, params *ecs.ListServicesInput, optFns ...func(*ecs.Options)) (*ecs.ListServicesOutput, error) {
+	return &ecs.ListServicesOutput{ServiceArns: []string{
+		"arn:aws:ecs:eu-west-1:123:service/prod/api",
+		"arn:aws:ecs:eu-west-1:123:service/prod/worker",
+	}}, nil
+}
+
+func (f *fakeTasks) ListTasks(ctx context.Context, params *ecs.ListTasksInput, optFns ...func(*ecs.Options)) (*ecs.ListTasksOutput, error) {
+	tasks := map[string][]string{
+		"api":    {"arn:aws:ecs:eu-west-1:123:task/prod/a1"},
+		"worker": {"arn:aws:ecs:eu-west-1:123:task/prod/w1"},
+	}
+	return &ecs.ListTasksOutput{TaskArns: tasks[aws.ToString(params.ServiceName)]}, nil
+}
+
+func (f *fakeTasks) DescribeTasks(ctx context.Context, params *ecs.DescribeTasksInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTasksOutput, error) {
+	tasks := []ecstypes.Task{}
+	for _, arn := range params.Tasks {
+		task := ecstypes.Task{TaskArn: aws.String(arn)}
+		if arn == "arn:aws:ecs:eu-west-1:123:task/prod/a1" {
+			task.Attachments = []ecstypes.Attachment{{
+				Type:    aws.String(ecsAttachmentENI),
+				Details: []ecstypes.KeyValuePair{{Name: aws.String(ecsDetailNetworkInterfaceID), Value: aws.String("eni-a1")}},
+			}}
+		}
+		tasks = append(tasks, task)
+	}
+	return &ecs.DescribeTasksOutput{Tasks: tasks}, nil
+}
+
+func (f *fakeTasks) DescribeNetworkInterfaces(ctx context.Context, params *ec2.DescribeNetworkInterfacesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	enis := []types.NetworkInterface{}
+	for _, id := range params.Filters[0].Values {
+		enis = append(enis, types.NetworkInterface{
+			NetworkInterfaceId: aws.String(id),
+			PrivateIpAddress:   aws.String("10.0.2.10"),
+			SubnetId:           aws.String("subnet-2"),
+			VpcId:              aws.String("vpc-1"),
+			Groups:             []types.GroupIdentifier{{GroupId: aws.String("sg-api")}},
+		})
+	}
+	return &ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: enis}, nil
+}
+
+func newFakeTasks() *fakeTasks {
+	return &fakeTasks{fakeClient: &fakeClient{
+		instancePages: [][]types.Reservation{{{Instances: []types.Instance{instance("i-1", "subnet-1", "sg-1")}}}},
+		calls:         map[string]int{},
+	}}
+}
+
+func TestScanResolvesECSTasksOfMatchingServices(t *testing.T) {
+	f := newFakeTasks()
+
+	data, err := ScanAws(AwsClients{EC2: f, ECS: f}, "name:web", "ecs:prod/*")
+
+	assert.Nil(t, err)
+	assert.Len(t, data.Destinations, 1)
+	assert.Equal(t, "prod/a1", data.Destinations[0].ID)
+	assert.Equal(t, "eni-a1", data.Destinations[0].NetworkInterfaces[0].ID)
+	assert.Equal(t, "sg-api", *data.Destinations[0].NetworkInterfaces[0].SecurityGroups[0].GroupId)
+}
+
+func TestECSQueryWithoutAwsvpcTasksNotFound(t *testing.T) {
+	f := newFakeTasks()
+
+	_, err := ScanAws(AwsClients{EC2: f, ECS: f}, "name:web", "ecs:prod/worker")
+
+	assert.EqualError(t, err, "ecs with query 'prod/worker' not found")
+}
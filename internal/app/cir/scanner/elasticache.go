@@ -0,0 +1,55 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	log "github.com/sirupsen/logrus"
+)
+
+// findElastiCacheNodes - query matches cache cluster id or replication group id, every cache node is a separate resource
+// elasticache api doesnt support wildcards or replication group filter so all clusters are listed and matched locally
+func findElastiCacheNodes(query QueryTerm, clients AwsClients) ([]resource, error) {
+	if clients.ElastiCache == nil {
+		return nil, fmt.Errorf("elasticache client not configured")
+	}
+
+	input := &elasticache.DescribeCacheClustersInput{ShowCacheNodeInfo: aws.Bool(true)}
+	resources := []resource{}
+	for {
+		result, err := clients.ElastiCache.DescribeCacheClusters(context.Background(), input)
+		if err != nil {
+			return nil, fmt.Errorf("error when looking for elasticache %s", err)
+		}
+
+		for _, cluster := range result.CacheClusters {
+			if !query.Matches(*cluster.CacheClusterId) && (cluster.ReplicationGroupId == nil || !query.Matches(*cluster.ReplicationGroupId)) {
+				continue
+			}
+
+			for _, node := range cluster.CacheNodes {
+				if node.Endpoint == nil || node.Endpoint.Address == nil {
+					log.Warnf("elasticache node %s/%s has no endpoint - skipping", *cluster.CacheClusterId, *node.CacheNodeId)
+					continue
+				}
+
+				nodeResource, err := endpointResource(fmt.Sprintf("%s/%s", *cluster.CacheClusterId, *node.CacheNodeId), *node.Endpoint.Address, clients)
+				if err != nil {
+					return nil, err
+				}
+				resources = append(resources, nodeResource)
+			}
+		}
+
+		if result.Marker == nil {
+			break
+		}
+		input.Marker = result.Marker
+	}
+
+	if len(resources) <= 0 {
+		return nil, fmt.Errorf("%s with query '%s' not found", query.Kind, query.Value)
+	}
+	return resources, nil
+}
@@ -0,0 +1,156 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	log "github.com/sirupsen/logrus"
+	"net"
+)
+
+// eniToInterfaceRef - ip is the address the resource is reached on, primary private ip if empty
+func eniToInterfaceRef(eni types.NetworkInterface, ip string) interfaceRef {
+	ref := interfaceRef{
+		ID:         *eni.NetworkInterfaceId,
+		PrivateIP:  aws.ToString(eni.PrivateIpAddress),
+		PrivateIPs: []string{},
+		IPv6s:      []string{},
+		SubnetID:   *eni.SubnetId,
+		VpcID:      *eni.VpcId,
+		GroupIDs:   []string{},
+	}
+	if eni.AvailabilityZone != nil {
+		ref.AvailabilityZone = *eni.AvailabilityZone
+	}
+	if eni.Association != nil && eni.Association.PublicIp != nil {
+		ref.PublicIP = *eni.Association.PublicIp
+	}
+	for _, privateIP := range eni.PrivateIpAddresses {
+		ref.PrivateIPs = append(ref.PrivateIPs, *privateIP.PrivateIpAddress)
+		if *privateIP.PrivateIpAddress == ip {
+			ref.PrivateIP = ip
+		}
+	}
+	for _, ipv6 := range eni.Ipv6Addresses {
+		ref.IPv6s = append(ref.IPv6s, *ipv6.Ipv6Address)
+	}
+	for _, group := range eni.Groups {
+		ref.GroupIDs = append(ref.GroupIDs, *group.GroupId)
+	}
+	return ref
+}
+
+func describeNetworkInterfaces(client EC2API, filters []types.Filter) ([]types.NetworkInterface, error) {
+	enis := []types.NetworkInterface{}
+	var nextToken *string
+	for {
+		result, err := client.DescribeNetworkInterfaces(context.Background(), &ec2.DescribeNetworkInterfacesInput{
+			Filters:   filters,
+			NextToken: nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error when looking for network interface %s", err)
+		}
+		enis = append(enis, result.NetworkInterfaces...)
+		if result.NextToken == nil {
+			return enis, nil
+		}
+		nextToken = result.NextToken
+	}
+}
+
+// findNetworkInterfaceByIP - looks by private ip first, publicly accessible endpoints resolve to public ip
+// ipv6 addresses are the same inside and outside of the vpc
+func findNetworkInterfaceByIP(ip string, client EC2API) (*types.NetworkInterface, error) {
+	filterNames := []string{"addresses.private-ip-address", "association.public-ip"}
+	if IsIPv6(net.ParseIP(ip)) {
+		filterNames = []string{"ipv6-addresses.ipv6-address"}
+	}
+	for _, filterName := range filterNames {
+		enis, err := describeNetworkInterfaces(client, []types.Filter{{Name: aws.String(filterName), Values: []string{ip}}})
+		if err != nil {
+			return nil, err
+		}
+		if len(enis) > 0 {
+			return &enis[0], nil
+		}
+	}
+	return nil, nil
+}
+
+// eniToResource - network interface analysed on its own, it can belong to any service eg. efs, msk or vpc endpoint
+// interfaces without security groups eg. nat gateway dont filter traffic
+func eniToResource(eni types.NetworkInterface, ip string) resource {
+	ref := eniToInterfaceRef(eni, ip)
+	ref.WithoutSecurityGroups = len(ref.GroupIDs) <= 0
+	ref.Owner = InterfaceOwner{Description: aws.ToString(eni.Description), RequesterID: aws.ToString(eni.RequesterId)}
+	return resource{ID: ref.ID, VpcID: ref.VpcID, Interfaces: []interfaceRef{ref}}
+}
+
+// findNetworkInterfaces - eni query, value is network interface id and can use wildcards
+func findNetworkInterfaces(query QueryTerm, clients AwsClients) ([]resource, error) {
+	enis, err := describeNetworkInterfaces(clients.EC2, []types.Filter{{Name: aws.String("network-interface-id"), Values: []string{query.Value}}})
+	if err != nil {
+		return nil, err
+	}
+
+	resources := []resource{}
+	for _, eni := range enis {
+		resources = append(resources, eniToResource(eni, ""))
+	}
+
+	if len(resources) <= 0 {
+		return nil, fmt.Errorf("%s with query '%s' not found", query.Kind, query.Value)
+	}
+	return resources, nil
+}
+
+// endpointInterfaces - resolves managed service endpoint (rds, elasticache) to network interfaces it is served from
+func endpointInterfaces(address string, clients AwsClients) ([]interfaceRef, error) {
+	if clients.Resolver == nil {
+		return nil, fmt.Errorf("unable to resolve endpoint '%s' - no resolver configured", address)
+	}
+
+	ips, err := clients.Resolver.LookupHost(context.Background(), address)
+	if err != nil {
+		return nil, fmt.Errorf("error when resolving endpoint '%s' %s", address, err)
+	}
+
+	refs := []interfaceRef{}
+	seen := map[string]bool{}
+	for _, ip := range ips {
+		if net.ParseIP(ip) == nil {
+			continue
+		}
+
+		eni, err := findNetworkInterfaceByIP(ip, clients.EC2)
+		if err != nil {
+			return nil, err
+		}
+		if eni == nil {
+			log.Warnf("no network interface found for ip %s of endpoint '%s'", ip, address)
+			continue
+		}
+		if seen[*eni.NetworkInterfaceId] {
+			continue
+		}
+		seen[*eni.NetworkInterfaceId] = true
+		refs = append(refs, eniToInterfaceRef(*eni, ip))
+	}
+
+	if len(refs) <= 0 {
+		return nil, fmt.Errorf("no network interface found for endpoint '%s' (resolved to %v)", address, ips)
+	}
+	return refs, nil
+}
+
+// endpointResource - resource served from endpoint, all interfaces are in the same vpc
+func endpointResource(id string, address string, clients AwsClients) (resource, error) {
+	refs, err := endpointInterfaces(address, clients)
+	if err != nil {
+		return resource{}, err
+	}
+	return resource{ID: id, VpcID: refs[0].VpcID, Interfaces: refs}, nil
+}
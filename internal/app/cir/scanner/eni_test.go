@@ -0,0 +1,80 @@
+package scanner
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// fakeInterfaces - efs mount target interface and nat gateway interface without security groups, no instances
+type fakeInterfaces struct {
+	*fakeClient
+}
+
+func (f *fakeInterfaces) DescribeNetworkInterfaces(ctx context.Context, params *ec2.DescribeNetworkInterfacesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	enis := []types.NetworkInterface{
+		{
+			NetworkInterfaceId: aws.String("eni-efs"),
+			PrivateIpAddress:   aws.String("10.0.3.10"),
+			PrivateIpAddresses: []types.NetworkInterfacePrivateIpAddress{{PrivateIpAddress: aws.String("10.0.3.10")}},
+			SubnetId:           aws.String("subnet-3"),
+			VpcId:              aws.String("vpc-1"),
+			Description:        aws.String("EFS mount target for fs-1 (fsmt-1)"),
+			RequesterId:        aws.String("amazon-elasticfilesystem"),
+			Groups:             []types.GroupIdentifier{{GroupId: aws.String("sg-efs")}},
+		},
+		{
+			NetworkInterfaceId: aws.String("eni-nat"),
+			PrivateIpAddress:   aws.String("10.0.3.20"),
+			SubnetId:           aws.String("subnet-3"),
+			VpcId:              aws.String("vpc-1"),
+			Description:        aws.String("Interface for NAT Gateway nat-1"),
+		},
+	}
+
+	found := []types.NetworkInterface{}
+	for _, eni := range enis {
+		value := *eni.NetworkInterfaceId
+		if *params.Filters[0].Name == "addresses.private-ip-address" {
+			value = *eni.PrivateIpAddress
+		}
+		if value == params.Filters[0].Values[0] {
+			found = append(found, eni)
+		}
+	}
+	return &ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: found}, nil
+}
+
+func newFakeInterfaces() *fakeInterfaces {
+	return &fakeInterfaces{fakeClient: &fakeClient{
+		instancePages: [][]types.Reservation{{}},
+		calls:         map[string]int{},
+	}}
+}
+
+func TestIPQueryFallsBackToNetworkInterface(t *testing.T) {
+	f := newFakeInterfaces()
+
+	data, err := ScanAws(AwsClients{EC2: f}, "eni:eni-nat", "10.0.3.10")
+
+	assert.Nil(t, err)
+	destination := data.Destinations[0]
+	assert.Equal(t, "eni-efs", destination.ID)
+	assert.Equal(t, "sg-efs", *destination.NetworkInterfaces[0].SecurityGroups[0].GroupId)
+	assert.Equal(t, "EFS mount target for fs-1 (fsmt-1), requested by amazon-elasticfilesystem", destination.NetworkInterfaces[0].Owner.String())
+
+	source := data.Sources[0].NetworkInterfaces[0]
+	assert.True(t, source.WithoutSecurityGroups)
+	assert.Equal(t, "Interface for NAT Gateway nat-1", source.Owner.String())
+}
+
+func TestIPQueryNotFound(t *testing.T) {
+	f := newFakeInterfaces()
+
+	_, err := ScanAws(AwsClients{EC2: f}, "eni:eni-nat", "10.0.3.99")
+
+	assert.EqualError(t, err, "ec2 or network interface with ip '10.0.3.99' not found")
+}
@@ -0,0 +1,96 @@
+package scanner
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	log "github.com/sirupsen/logrus"
+	"sort"
+)
+
+// ResourceGroup - instances sharing the value of the grouping tag eg. Tier=api
+type ResourceGroup struct {
+	Name      string
+	Resources []ResourceNetworkMetaData
+}
+
+// groupKey - tag without value is the one instances are grouped by, other terms only filter the instances
+func groupKey(query Query) (string, error) {
+	key := ""
+	for _, term := range query {
+		if term.Kind != QueryTag || term.Value != "" {
+			continue
+		}
+		if key != "" {
+			return "", fmt.Errorf("group query can have only one tag without value")
+		}
+		key = term.Key
+	}
+	if key == "" {
+		return "", fmt.Errorf("group query needs tag without value to group by eg. tag:Tier")
+	}
+	return key, nil
+}
+
+func tagValue(tags []types.Tag, key string) string {
+	for _, tag := range tags {
+		if tag.Key != nil && *tag.Key == key && tag.Value != nil {
+			return *tag.Value
+		}
+	}
+	return ""
+}
+
+// ScanGroups - every value of the tag becomes a group of the instances having it, groups are sorted by the value
+// eg. tag:Tier,vpc:vpc-123 groups instances of the vpc by their Tier tag
+func ScanGroups(clients AwsClients, groupQuery string) ([]ResourceGroup, error) {
+	parsedQuery, err := ParseQuery(groupQuery)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := parsedQuery.ServiceTerm(); ok {
+		return nil, fmt.Errorf("group query '%s' - only ec2 instances can be grouped", groupQuery)
+	}
+	key, err := groupKey(parsedQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	ec2Instances, err := findEC2s(groupQuery, clients.EC2)
+	if err != nil {
+		return nil, err
+	}
+
+	resourcesByValue := map[string][]resource{}
+	allResources := []resource{}
+	for _, ec2Instance := range ec2Instances {
+		instanceResource, err := instanceToResource(ec2Instance)
+		if err != nil {
+			return nil, err
+		}
+		value := tagValue(ec2Instance.Tags, key)
+		resourcesByValue[value] = append(resourcesByValue[value], instanceResource)
+		allResources = append(allResources, instanceResource)
+	}
+	log.Debugf("Found %d instances in %d groups\n", len(allResources), len(resourcesByValue))
+
+	cache, err := loadResourceCache(allResources, clients.EC2)
+	if err != nil {
+		return nil, err
+	}
+
+	values := []string{}
+	for value := range resourcesByValue {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	groups := []ResourceGroup{}
+	for _, value := range values {
+		resources, err := getNetworkMetaData(resourcesByValue[value], cache)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, ResourceGroup{Name: value, Resources: resources})
+	}
+	return groups, nil
+}
@@ -0,0 +1,41 @@
+package scanner
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func taggedInstance(id string, tier string) types.Instance {
+	tagged := instance(id, "subnet-1", "sg-1")
+	tagged.Tags = []types.Tag{{Key: aws.String("Name"), Value: aws.String(id)}, {Key: aws.String("Tier"), Value: aws.String(tier)}}
+	return tagged
+}
+
+func TestScanGroupsSplitsInstancesByTagValue(t *testing.T) {
+	client := &fakeClient{instancePages: [][]types.Reservation{{{Instances: []types.Instance{
+		taggedInstance("i-web-1", "web"), taggedInstance("i-db", "db"), taggedInstance("i-web-2", "web"),
+	}}}}, calls: map[string]int{}}
+
+	groups, err := ScanGroups(AwsClients{EC2: client}, "tag:Tier,vpc:vpc-1")
+
+	assert.Nil(t, err)
+	assert.Len(t, groups, 2)
+	assert.Equal(t, "db", groups[0].Name)
+	assert.Equal(t, "i-db", groups[0].Resources[0].ID)
+	assert.Equal(t, "web", groups[1].Name)
+	assert.Len(t, groups[1].Resources, 2)
+	assert.Equal(t, "sg-1", *groups[1].Resources[1].NetworkInterfaces[0].SecurityGroups[0].GroupId)
+	assert.Equal(t, 1, client.calls["DescribeSecurityGroups"])
+}
+
+func TestScanGroupsNeedsTagWithoutValue(t *testing.T) {
+	client := &fakeClient{instancePages: [][]types.Reservation{{}}, calls: map[string]int{}}
+
+	_, err := ScanGroups(AwsClients{EC2: client}, "tag:Tier=web")
+	assert.EqualError(t, err, "group query needs tag without value to group by eg. tag:Tier")
+
+	_, err = ScanGroups(AwsClients{EC2: client}, "rds:orders")
+	assert.EqualError(t, err, "group query 'rds:orders' - only ec2 instances can be grouped")
+}
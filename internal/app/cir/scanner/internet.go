@@ -0,0 +1,116 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net"
+)
+
+// InternetInterfaceID - id of the network interface representing address outside of aws network
+const InternetInterfaceID = "internet"
+
+// nonPublicRanges - ipv4 ranges not routed in the internet
+var nonPublicRanges = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+}
+
+// globalUnicastIPv6 - the only ipv6 range routed in the internet, aws assigns vpc ipv6 ranges from it
+var globalUnicastIPv6 = "2000::/3"
+
+// IsIPv6 - true for ipv6 address, ipv4 addresses written in ipv6 notation are ipv4
+func IsIPv6(ip net.IP) bool {
+	return ip != nil && ip.To4() == nil
+}
+
+// IsPublicIP - true for address routed in the internet, every ipv6 address of the vpc is public
+func IsPublicIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if IsIPv6(ip) {
+		_, cidr, _ := net.ParseCIDR(globalUnicastIPv6)
+		return cidr.Contains(ip)
+	}
+	for _, value := range nonPublicRanges {
+		_, cidr, _ := net.ParseCIDR(value)
+		if cidr.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// internetResource - addresses outside of aws network, they have no subnet or security groups
+// so only the path leaving the vpc is analysed
+func internetResource(id string, ips []string) resource {
+	internet := resource{ID: id, Interfaces: []interfaceRef{}}
+	for _, ip := range ips {
+		ref := interfaceRef{
+			ID:         InternetInterfaceID,
+			PrivateIP:  ip,
+			PrivateIPs: []string{ip},
+			IPv6s:      []string{},
+			Internet:   true,
+		}
+		if IsIPv6(net.ParseIP(ip)) {
+			ref.PrivateIP = ""
+			ref.PrivateIPs = []string{}
+			ref.IPv6s = []string{ip}
+		}
+		internet.Interfaces = append(internet.Interfaces, ref)
+	}
+	return internet
+}
+
+// findHost - hostname resolved to public ips eg. api.stripe.com
+func findHost(query QueryTerm, clients AwsClients) ([]resource, error) {
+	if clients.Resolver == nil {
+		return nil, fmt.Errorf("unable to resolve host '%s' - no resolver configured", query.Value)
+	}
+
+	ips, err := clients.Resolver.LookupHost(context.Background(), query.Value)
+	if err != nil {
+		return nil, fmt.Errorf("error when resolving host '%s' %s", query.Value, err)
+	}
+
+	publicIPs := []string{}
+	for _, ip := range ips {
+		if !IsPublicIP(net.ParseIP(ip)) {
+			log.Warnf("host '%s' resolves to non public ip %s - skipping, use ip query to analyse it", query.Value, ip)
+			continue
+		}
+		publicIPs = append(publicIPs, ip)
+	}
+
+	if len(publicIPs) <= 0 {
+		return nil, fmt.Errorf("host '%s' doesnt resolve to any public ip (resolved to %v)", query.Value, ips)
+	}
+	return []resource{internetResource(query.Value, publicIPs)}, nil
+}
+
+// internetAddresses - addresses used for the whole internet, one for every address family
+// only rules and routes covering every address (0.0.0.0/0 or ::/0) match them
+var internetAddresses = []string{net.IPv4zero.String(), net.IPv6unspecified.String()}
+
+// findCIDR - public range is analysed using the first address of every part of it the rules and routes treat differently
+func findCIDR(query QueryTerm) ([]resource, error) {
+	_, cidr, err := net.ParseCIDR(query.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%s query '%s' is not valid cidr - %s", query.Kind, query.Value, err)
+	}
+	if !IsPublicIP(cidr.IP) {
+		return nil, fmt.Errorf("%s query '%s' is not public range", query.Kind, query.Value)
+	}
+	internet := internetResource(cidr.String(), []string{cidr.IP.String()})
+	internet.Interfaces[0].Cidr = cidr.String()
+	return []resource{internet}, nil
+}
@@ -0,0 +1,67 @@
+package scanner
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+type fakeResolver map[string][]string
+
+func (f fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return f[host], nil
+}
+
+func TestPublicDestinationsAreInternetAddresses(t *testing.T) {
+	clients := AwsClients{EC2: newFakeInterfaces(), Resolver: fakeResolver{"api.example.com": {"10.0.0.1", "54.1.1.2"}}}
+
+	destinations := map[string][]string{
+		"54.1.1.1":             {"54.1.1.1", "54.1.1.1"},
+		"host:api.example.com": {"api.example.com", "54.1.1.2"},
+		"cidr:203.0.113.0/24":  {"203.0.113.0/24", "203.0.113.0"},
+	}
+	for query, expected := range destinations {
+		data, err := ScanAws(clients, "eni:eni-efs", query)
+
+		assert.Nil(t, err, query)
+		assert.Equal(t, expected[0], data.Destinations[0].ID)
+		assert.Len(t, data.Destinations[0].NetworkInterfaces, 1)
+		assert.True(t, data.Destinations[0].NetworkInterfaces[0].Internet)
+		assert.Equal(t, expected[1], data.Destinations[0].NetworkInterfaces[0].PrivateIP)
+	}
+}
+
+func TestInternetSourceCoversEveryAddress(t *testing.T) {
+	data, err := ScanAws(AwsClients{EC2: newFakeInterfaces()}, "internet", "eni:eni-efs")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "internet", data.Sources[0].ID)
+	assert.Equal(t, "0.0.0.0", data.Sources[0].NetworkInterfaces[0].PrivateIP)
+	assert.Equal(t, []string{"::"}, data.Sources[0].NetworkInterfaces[1].IPv6s)
+}
+
+func TestSourceAndDestinationCantBothBeInternet(t *testing.T) {
+	_, err := ScanAws(AwsClients{EC2: newFakeInterfaces()}, "cidr:203.0.113.0/24", "54.1.1.1")
+
+	assert.EqualError(t, err, "source and destination are both outside of aws network")
+}
+
+func TestPrivateRangesAreNotPublic(t *testing.T) {
+	assert.False(t, IsPublicIP(net.ParseIP("172.20.0.1")))
+	assert.False(t, IsPublicIP(net.ParseIP("100.64.1.1")))
+	assert.True(t, IsPublicIP(net.ParseIP("8.8.8.8")))
+	assert.True(t, IsPublicIP(net.ParseIP("2600:1f18:1000:10::5")))
+	assert.False(t, IsPublicIP(net.ParseIP("fd00::1")))
+	assert.False(t, IsPublicIP(net.ParseIP("fe80::1")))
+}
+
+func TestExposureDestinationHasToBeInAws(t *testing.T) {
+	_, err := ScanDestinations(AwsClients{EC2: newFakeInterfaces()}, "54.1.1.1")
+	assert.EqualError(t, err, "destination is outside of aws network")
+
+	data, err := ScanDestinations(AwsClients{EC2: newFakeInterfaces()}, "eni:eni-efs")
+	assert.Nil(t, err)
+	assert.Len(t, data.Sources, 0)
+	assert.Equal(t, "eni-efs", data.Destinations[0].NetworkInterfaces[0].ID)
+}
@@ -0,0 +1,109 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+)
+
+// lambdaInterfaceType - hyperplane network interfaces are shared by all functions using the same subnet and security groups
+const lambdaInterfaceType = "lambda"
+
+// findLambdaFunctions - every function attached to vpc is a resource with hyperplane interface in each of its subnets
+// lambda api doesnt support wildcards so functions are listed and matched locally
+func findLambdaFunctions(query QueryTerm, clients AwsClients) ([]resource, error) {
+	if clients.Lambda == nil {
+		return nil, fmt.Errorf("lambda client not configured")
+	}
+
+	functions, err := findLambdaFunctionConfigurations(query, clients.Lambda)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := []resource{}
+	for _, function := range functions {
+		if function.VpcConfig == nil || len(function.VpcConfig.SubnetIds) <= 0 {
+			log.Warnf("lambda %s is not attached to vpc - skipping", *function.FunctionName)
+			continue
+		}
+
+		refs, err := lambdaInterfaces(*function.VpcConfig, clients.EC2)
+		if err != nil {
+			return nil, err
+		}
+		if len(refs) <= 0 {
+			log.Warnf("no network interfaces found for lambda %s - check if the function is active", *function.FunctionName)
+			continue
+		}
+		resources = append(resources, resource{ID: *function.FunctionName, VpcID: aws.ToString(function.VpcConfig.VpcId), Interfaces: refs})
+	}
+
+	if len(resources) <= 0 {
+		return nil, fmt.Errorf("%s with query '%s' not found", query.Kind, query.Value)
+	}
+	return resources, nil
+}
+
+func findLambdaFunctionConfigurations(query QueryTerm, client LambdaAPI) ([]types.FunctionConfiguration, error) {
+	if !query.HasWildcard() {
+		result, err := client.GetFunctionConfiguration(context.Background(), &lambda.GetFunctionConfigurationInput{FunctionName: aws.String(query.Value)})
+		if err != nil {
+			return nil, fmt.Errorf("error when looking for lambda %s", err)
+		}
+		return []types.FunctionConfiguration{{FunctionName: result.FunctionName, VpcConfig: result.VpcConfig}}, nil
+	}
+
+	functions := []types.FunctionConfiguration{}
+	input := &lambda.ListFunctionsInput{}
+	for {
+		result, err := client.ListFunctions(context.Background(), input)
+		if err != nil {
+			return nil, fmt.Errorf("error when looking for lambda %s", err)
+		}
+		for _, function := range result.Functions {
+			if query.Matches(*function.FunctionName) {
+				functions = append(functions, function)
+			}
+		}
+		if result.NextMarker == nil {
+			return functions, nil
+		}
+		input.Marker = result.NextMarker
+	}
+}
+
+// lambdaInterfaces - hyperplane interface is used by the function only if it has exactly the same security groups
+func lambdaInterfaces(vpcConfig types.VpcConfigResponse, client EC2API) ([]interfaceRef, error) {
+	if len(vpcConfig.SecurityGroupIds) <= 0 {
+		return []interfaceRef{}, nil
+	}
+
+	enis, err := describeNetworkInterfaces(client, []ec2types.Filter{
+		{Name: aws.String("interface-type"), Values: []string{lambdaInterfaceType}},
+		{Name: aws.String("subnet-id"), Values: vpcConfig.SubnetIds},
+		{Name: aws.String("group-id"), Values: vpcConfig.SecurityGroupIds[:1]},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	groupIDs := append([]string{}, vpcConfig.SecurityGroupIds...)
+	sort.Strings(groupIDs)
+	refs := []interfaceRef{}
+	for _, eni := range enis {
+		ref := eniToInterfaceRef(eni, "")
+		eniGroupIDs := append([]string{}, ref.GroupIDs...)
+		sort.Strings(eniGroupIDs)
+		if strings.Join(eniGroupIDs, ",") == strings.Join(groupIDs, ",") {
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
@@ -0,0 +1,91 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// fakeFunctions - function 'orders' attached to vpc and 'cron' running outside of vpc
+// subnet-2 has hyperplane interfaces of two functions with different security groups
+type fakeFunctions struct {
+	*fakeClient
+}
+
+func (f *fakeFunctions) GetFunctionConfiguration(ctx context.Context, params *lambda.GetFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionConfigurationOutput, error) {
+	for _, function := range f.functions() {
+		if *function.FunctionName == *params.FunctionName {
+			return &lambda.GetFunctionConfigurationOutput{FunctionName: function.FunctionName, VpcConfig: function.VpcConfig}, nil
+		}
+	}
+	return nil, fmt.Errorf("function not found")
+}
+
+func (f *fakeFunctions) ListFunctions(ctx context.Context, params *lambda.ListFunctionsInput, optFns ...func(*lambda.Options)) (*lambda.ListFunctionsOutput, error) {
+	return &lambda.ListFunctionsOutput{Functions: f.functions()}, nil
+}
+
+func (f *fakeFunctions) functions() []lambdatypes.FunctionConfiguration {
+	return []lambdatypes.FunctionConfiguration{
+		{FunctionName: aws.String("orders"), VpcConfig: &lambdatypes.VpcConfigResponse{
+			VpcId:            aws.String("vpc-1"),
+			SubnetIds:        []string{"subnet-2"},
+			SecurityGroupIds: []string{"sg-orders"},
+		}},
+		{FunctionName: aws.String("cron")},
+	}
+}
+
+func (f *fakeFunctions) DescribeNetworkInterfaces(ctx context.Context, params *ec2.DescribeNetworkInterfacesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	eni := func(id string, groupIDs ...string) types.NetworkInterface {
+		groups := []types.GroupIdentifier{}
+		for _, groupID := range groupIDs {
+			groups = append(groups, types.GroupIdentifier{GroupId: aws.String(groupID)})
+		}
+		return types.NetworkInterface{
+			NetworkInterfaceId: aws.String(id),
+			PrivateIpAddress:   aws.String("10.0.2.30"),
+			SubnetId:           aws.String("subnet-2"),
+			VpcId:              aws.String("vpc-1"),
+			InterfaceType:      types.NetworkInterfaceType(lambdaInterfaceType),
+			Groups:             groups,
+		}
+	}
+	return &ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []types.NetworkInterface{
+		eni("eni-orders", "sg-orders"),
+		eni("eni-other", "sg-orders", "sg-other"),
+	}}, nil
+}
+
+func newFakeFunctions() *fakeFunctions {
+	return &fakeFunctions{fakeClient: &fakeClient{
+		instancePages: [][]types.Reservation{{{Instances: []types.Instance{instance("i-1", "subnet-1", "sg-1")}}}},
+		calls:         map[string]int{},
+	}}
+}
+
+func TestScanResolvesLambdaHyperplaneInterfaces(t *testing.T) {
+	f := newFakeFunctions()
+
+	data, err := ScanAws(AwsClients{EC2: f, Lambda: f}, "lambda:*", "name:web")
+
+	assert.Nil(t, err)
+	assert.Len(t, data.Sources, 1)
+	assert.Equal(t, "orders", data.Sources[0].ID)
+	assert.Len(t, data.Sources[0].NetworkInterfaces, 1)
+	assert.Equal(t, "eni-orders", data.Sources[0].NetworkInterfaces[0].ID)
+}
+
+func TestLambdaOutsideOfVpcNotFound(t *testing.T) {
+	f := newFakeFunctions()
+
+	_, err := ScanAws(AwsClients{EC2: f, Lambda: f}, "lambda:cron", "name:web")
+
+	assert.EqualError(t, err, "lambda with query 'cron' not found")
+}
@@ -0,0 +1,471 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbv2types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Load balancer types
+const (
+	LoadBalancerApplication = "application"
+	LoadBalancerNetwork     = "network"
+	LoadBalancerGateway     = "gateway"
+	LoadBalancerClassic     = "classic"
+)
+
+// preserveClientIPAttribute - network load balancer target group attribute, targets see the ip of the client instead of the node
+const preserveClientIPAttribute = "preserve_client_ip.enabled"
+
+// healthCheckTrafficPort - health checks use the port the target receives traffic on
+const healthCheckTrafficPort = "traffic-port"
+
+// LoadBalancer - load balancer found by lb query, its nodes are the destinations of client traffic
+type LoadBalancer struct {
+	Name         string
+	Type         string
+	Listeners    []Listener
+	TargetGroups []TargetGroup
+}
+
+// Listener - port the clients connect to, protocol is tcp or udp
+type Listener struct {
+	Protocol string
+	Port     int32
+}
+
+// TargetGroup - targets the load balancer forwards the traffic to, protocols are tcp or udp
+type TargetGroup struct {
+	Name      string
+	Protocols []string
+	// targets see the ip of the client instead of the load balancer node so their security groups have to allow the clients
+	PreserveClientIP bool
+	Targets          []Target
+}
+
+// Target - registered target with ports it receives traffic and health checks on, health checks always use tcp
+type Target struct {
+	Resource        ResourceNetworkMetaData
+	Port            int32
+	HealthCheckPort int32
+}
+
+// loadBalancerRef - load balancer found by the query before network configuration of its nodes and targets is loaded
+type loadBalancerRef struct {
+	LoadBalancer LoadBalancer
+	Nodes        resource
+	arn          string
+	classic      *elbtypes.LoadBalancerDescription
+	targetGroups []targetGroupRef
+}
+
+type targetGroupRef struct {
+	TargetGroup TargetGroup
+	Targets     []targetRef
+}
+
+type targetRef struct {
+	Resource        resource
+	Port            int32
+	HealthCheckPort int32
+}
+
+// ipProtocols - load balancer protocols mapped to ip protocols, TCP_UDP listens on both
+func ipProtocols(protocol string) []string {
+	switch strings.ToUpper(protocol) {
+	case "UDP", "GENEVE":
+		return []string{"udp"}
+	case "TCP_UDP":
+		return []string{"tcp", "udp"}
+	}
+	return []string{"tcp"}
+}
+
+func parsePort(value string) (int32, error) {
+	port, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port '%s' - %s", value, err)
+	}
+	return int32(port), nil
+}
+
+// findLoadBalancer - names of application, network and classic load balancers are matched locally to support wildcards
+// only one load balancer can be analysed at once as its listeners and targets define the traffic
+func findLoadBalancer(query QueryTerm, clients AwsClients) (*loadBalancerRef, error) {
+	if clients.ELBV2 == nil || clients.ELB == nil {
+		return nil, fmt.Errorf("load balancer clients not configured")
+	}
+
+	candidates := []*loadBalancerRef{}
+	loadBalancers, err := describeLoadBalancersV2(clients.ELBV2)
+	if err != nil {
+		return nil, err
+	}
+	for _, loadBalancer := range loadBalancers {
+		if !query.Matches(*loadBalancer.LoadBalancerName) {
+			continue
+		}
+		candidates = append(candidates, &loadBalancerRef{
+			LoadBalancer: LoadBalancer{Name: *loadBalancer.LoadBalancerName, Type: string(loadBalancer.Type)},
+			Nodes:        resource{ID: *loadBalancer.LoadBalancerName, VpcID: aws.ToString(loadBalancer.VpcId)},
+			arn:          *loadBalancer.LoadBalancerArn,
+		})
+	}
+
+	classicLoadBalancers, err := describeClassicLoadBalancers(clients.ELB)
+	if err != nil {
+		return nil, err
+	}
+	for i, loadBalancer := range classicLoadBalancers {
+		if !query.Matches(*loadBalancer.LoadBalancerName) {
+			continue
+		}
+		candidates = append(candidates, &loadBalancerRef{
+			LoadBalancer: LoadBalancer{Name: *loadBalancer.LoadBalancerName, Type: LoadBalancerClassic},
+			Nodes:        resource{ID: *loadBalancer.LoadBalancerName, VpcID: aws.ToString(loadBalancer.VPCId)},
+			classic:      &classicLoadBalancers[i],
+		})
+	}
+
+	if len(candidates) <= 0 {
+		return nil, fmt.Errorf("%s with query '%s' not found", query.Kind, query.Value)
+	}
+	if len(candidates) > 1 {
+		names := []string{}
+		for _, candidate := range candidates {
+			names = append(names, candidate.LoadBalancer.Name)
+		}
+		return nil, fmt.Errorf("%s with query '%s' matches %d load balancers (%s) - only one can be analysed at once",
+			query.Kind, query.Value, len(candidates), strings.Join(names, ", "))
+	}
+
+	ref := candidates[0]
+	if err := ref.loadNodes(clients.EC2); err != nil {
+		return nil, err
+	}
+	return ref, nil
+}
+
+func describeLoadBalancersV2(client ELBV2API) ([]elbv2types.LoadBalancer, error) {
+	loadBalancers := []elbv2types.LoadBalancer{}
+	input := &elasticloadbalancingv2.DescribeLoadBalancersInput{}
+	for {
+		result, err := client.DescribeLoadBalancers(context.Background(), input)
+		if err != nil {
+			return nil, fmt.Errorf("error when looking for load balancer %s", err)
+		}
+		loadBalancers = append(loadBalancers, result.LoadBalancers...)
+		if result.NextMarker == nil {
+			return loadBalancers, nil
+		}
+		input.Marker = result.NextMarker
+	}
+}
+
+func describeClassicLoadBalancers(client ELBAPI) ([]elbtypes.LoadBalancerDescription, error) {
+	loadBalancers := []elbtypes.LoadBalancerDescription{}
+	input := &elasticloadbalancing.DescribeLoadBalancersInput{}
+	for {
+		result, err := client.DescribeLoadBalancers(context.Background(), input)
+		if err != nil {
+			return nil, fmt.Errorf("error when looking for classic load balancer %s", err)
+		}
+		loadBalancers = append(loadBalancers, result.LoadBalancerDescriptions...)
+		if result.NextMarker == nil {
+			return loadBalancers, nil
+		}
+		input.Marker = result.NextMarker
+	}
+}
+
+// nodesDescription - aws names network interfaces of load balancer nodes after it
+// eg. 'ELB app/my-alb/50dc6c495c0c9188' or 'ELB my-classic-lb'
+func (r *loadBalancerRef) nodesDescription() string {
+	if r.classic != nil {
+		return "ELB " + r.LoadBalancer.Name
+	}
+	return "ELB " + r.arn[strings.Index(r.arn, ":loadbalancer/")+len(":loadbalancer/"):]
+}
+
+// loadNodes - nodes without security groups eg. of gateway load balancer or network load balancer created without them dont filter traffic
+func (r *loadBalancerRef) loadNodes(client EC2API) error {
+	enis, err := describeNetworkInterfaces(client, []types.Filter{{Name: aws.String("description"), Values: []string{r.nodesDescription()}}})
+	if err != nil {
+		return err
+	}
+	if len(enis) <= 0 {
+		return fmt.Errorf("no network interfaces found for lb %s", r.LoadBalancer.Name)
+	}
+
+	r.Nodes.Interfaces = []interfaceRef{}
+	for _, eni := range enis {
+		ref := eniToInterfaceRef(eni, "")
+		ref.WithoutSecurityGroups = len(ref.GroupIDs) <= 0
+		r.Nodes.Interfaces = append(r.Nodes.Interfaces, ref)
+	}
+	sort.Slice(r.Nodes.Interfaces, func(i, j int) bool {
+		return r.Nodes.Interfaces[i].AvailabilityZone < r.Nodes.Interfaces[j].AvailabilityZone
+	})
+	return nil
+}
+
+// loadTargets - loads listeners and registered targets needed for the load balancer to target leg
+func (r *loadBalancerRef) loadTargets(clients AwsClients) error {
+	if r.classic != nil {
+		r.loadClassicTargets()
+	} else if err := r.loadTargetsV2(clients.ELBV2); err != nil {
+		return err
+	}
+
+	return r.resolveTargets(clients.EC2)
+}
+
+func (r *loadBalancerRef) loadClassicTargets() {
+	healthCheckPort := int32(0)
+	if r.classic.HealthCheck != nil && r.classic.HealthCheck.Target != nil {
+		// target has format PROTOCOL:PORT[/PATH] eg. HTTP:80/health
+		target := *r.classic.HealthCheck.Target
+		target = strings.SplitN(target[strings.Index(target, ":")+1:], "/", 2)[0]
+		port, err := parsePort(target)
+		if err != nil {
+			log.Warnf("health check of lb %s - %s", r.LoadBalancer.Name, err)
+		}
+		healthCheckPort = port
+	}
+
+	instancePorts := map[int32]bool{}
+	for _, description := range r.classic.ListenerDescriptions {
+		if description.Listener == nil {
+			continue
+		}
+		r.LoadBalancer.Listeners = append(r.LoadBalancer.Listeners, Listener{"tcp", description.Listener.LoadBalancerPort})
+		instancePorts[description.Listener.InstancePort] = true
+	}
+
+	ports := []int32{}
+	for port := range instancePorts {
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+
+	group := targetGroupRef{TargetGroup: TargetGroup{Name: r.LoadBalancer.Name, Protocols: []string{"tcp"}}}
+	for _, instance := range r.classic.Instances {
+		for _, port := range ports {
+			group.Targets = append(group.Targets, targetRef{
+				Resource:        resource{ID: *instance.InstanceId},
+				Port:            port,
+				HealthCheckPort: healthCheckPort,
+			})
+		}
+	}
+	r.targetGroups = []targetGroupRef{group}
+}
+
+func (r *loadBalancerRef) loadTargetsV2(client ELBV2API) error {
+	listenersInput := &elasticloadbalancingv2.DescribeListenersInput{LoadBalancerArn: aws.String(r.arn)}
+	for {
+		result, err := client.DescribeListeners(context.Background(), listenersInput)
+		if err != nil {
+			return fmt.Errorf("error when looking for listeners %s", err)
+		}
+		for _, listener := range result.Listeners {
+			for _, protocol := range ipProtocols(string(listener.Protocol)) {
+				r.LoadBalancer.Listeners = append(r.LoadBalancer.Listeners, Listener{protocol, aws.ToInt32(listener.Port)})
+			}
+		}
+		if result.NextMarker == nil {
+			break
+		}
+		listenersInput.Marker = result.NextMarker
+	}
+
+	groupsInput := &elasticloadbalancingv2.DescribeTargetGroupsInput{LoadBalancerArn: aws.String(r.arn)}
+	for {
+		result, err := client.DescribeTargetGroups(context.Background(), groupsInput)
+		if err != nil {
+			return fmt.Errorf("error when looking for target groups %s", err)
+		}
+		for _, group := range result.TargetGroups {
+			groupRef, err := r.loadTargetGroup(group, client)
+			if err != nil {
+				return err
+			}
+			r.targetGroups = append(r.targetGroups, groupRef)
+		}
+		if result.NextMarker == nil {
+			return nil
+		}
+		groupsInput.Marker = result.NextMarker
+	}
+}
+
+// defaultPreserveClientIP - used if the attribute isnt returned, udp always preserves it, tcp and tls only for instance targets
+func defaultPreserveClientIP(group elbv2types.TargetGroup) bool {
+	return group.TargetType == elbv2types.TargetTypeEnumInstance ||
+		group.Protocol == elbv2types.ProtocolEnumUdp || group.Protocol == elbv2types.ProtocolEnumTcpUdp
+}
+
+func (r *loadBalancerRef) loadTargetGroup(group elbv2types.TargetGroup, client ELBV2API) (targetGroupRef, error) {
+	groupRef := targetGroupRef{TargetGroup: TargetGroup{
+		Name:      *group.TargetGroupName,
+		Protocols: ipProtocols(string(group.Protocol)),
+	}}
+
+	if r.LoadBalancer.Type == LoadBalancerNetwork {
+		groupRef.TargetGroup.PreserveClientIP = defaultPreserveClientIP(group)
+		attributes, err := client.DescribeTargetGroupAttributes(context.Background(), &elasticloadbalancingv2.DescribeTargetGroupAttributesInput{
+			TargetGroupArn: group.TargetGroupArn,
+		})
+		if err != nil {
+			return targetGroupRef{}, fmt.Errorf("error when looking for target group attributes %s", err)
+		}
+		for _, attribute := range attributes.Attributes {
+			if aws.ToString(attribute.Key) == preserveClientIPAttribute {
+				groupRef.TargetGroup.PreserveClientIP = aws.ToString(attribute.Value) == "true"
+			}
+		}
+	}
+
+	if group.TargetType == elbv2types.TargetTypeEnumLambda {
+		log.Warnf("target group %s has lambda targets - skipping", *group.TargetGroupName)
+		return groupRef, nil
+	}
+
+	health, err := client.DescribeTargetHealth(context.Background(), &elasticloadbalancingv2.DescribeTargetHealthInput{
+		TargetGroupArn: group.TargetGroupArn,
+	})
+	if err != nil {
+		return targetGroupRef{}, fmt.Errorf("error when looking for targets %s", err)
+	}
+
+	for _, description := range health.TargetHealthDescriptions {
+		if description.Target == nil {
+			continue
+		}
+
+		target := targetRef{Port: aws.ToInt32(group.Port)}
+		if description.Target.Port != nil {
+			target.Port = *description.Target.Port
+		}
+
+		healthCheckPort := aws.ToString(description.HealthCheckPort)
+		if healthCheckPort == "" {
+			healthCheckPort = aws.ToString(group.HealthCheckPort)
+		}
+		target.HealthCheckPort = target.Port
+		if healthCheckPort != "" && healthCheckPort != healthCheckTrafficPort {
+			if target.HealthCheckPort, err = parsePort(healthCheckPort); err != nil {
+				return targetGroupRef{}, err
+			}
+		}
+
+		// resource is resolved later for all targets at once, ip targets use the ip as id until then
+		target.Resource = resource{ID: *description.Target.Id}
+		groupRef.Targets = append(groupRef.Targets, target)
+	}
+	return groupRef, nil
+}
+
+// resolveTargets - instance targets are looked up in batches, ip targets by network interface using the ip
+// targets which cant be found eg. ips outside of aws are skipped
+func (r *loadBalancerRef) resolveTargets(client EC2API) error {
+	instanceIDs := map[string]bool{}
+	for _, group := range r.targetGroups {
+		for _, target := range group.Targets {
+			if !isIPTarget(target.Resource.ID) {
+				instanceIDs[target.Resource.ID] = true
+			}
+		}
+	}
+
+	resolved := map[string]resource{}
+	for _, batch := range batches(sortedKeys(instanceIDs)) {
+		instances, err := describeInstances(client, []types.Filter{{Name: aws.String("instance-id"), Values: batch}})
+		if err != nil {
+			return err
+		}
+		for _, instance := range instances {
+			instanceResource, err := instanceToResource(instance)
+			if err != nil {
+				return err
+			}
+			// load balancer sends traffic to the primary network interface of the instance
+			instanceResource.Interfaces = instanceResource.Interfaces[:1]
+			resolved[instanceResource.ID] = instanceResource
+		}
+	}
+
+	for i, group := range r.targetGroups {
+		targets := []targetRef{}
+		for _, target := range group.Targets {
+			id := target.Resource.ID
+			if _, ok := resolved[id]; !ok && isIPTarget(id) {
+				eni, err := findNetworkInterfaceByIP(id, client)
+				if err != nil {
+					return err
+				}
+				if eni != nil {
+					ref := eniToInterfaceRef(*eni, id)
+					resolved[id] = resource{ID: id, VpcID: ref.VpcID, Interfaces: []interfaceRef{ref}}
+				}
+			}
+
+			targetResource, ok := resolved[id]
+			if !ok {
+				log.Warnf("target %s of target group %s not found - skipping", id, group.TargetGroup.Name)
+				continue
+			}
+			target.Resource = targetResource
+			targets = append(targets, target)
+		}
+
+		if len(targets) <= 0 {
+			log.Warnf("target group %s has no targets", group.TargetGroup.Name)
+		}
+		r.targetGroups[i].Targets = targets
+	}
+	return nil
+}
+
+func isIPTarget(id string) bool {
+	return !strings.HasPrefix(id, "i-")
+}
+
+// targetResources - resources of all targets, needed to load their network configuration
+func (r *loadBalancerRef) targetResources() []resource {
+	resources := []resource{}
+	for _, group := range r.targetGroups {
+		for _, target := range group.Targets {
+			resources = append(resources, target.Resource)
+		}
+	}
+	return resources
+}
+
+// withNetworkMetaData - load balancer with network configuration of its targets
+func (r *loadBalancerRef) withNetworkMetaData(cache *resourceCache) (*LoadBalancer, error) {
+	loadBalancer := r.LoadBalancer
+	loadBalancer.TargetGroups = []TargetGroup{}
+	for _, group := range r.targetGroups {
+		targetGroup := group.TargetGroup
+		targetGroup.Targets = []Target{}
+		for _, target := range group.Targets {
+			metaData, err := getNetworkMetaData([]resource{target.Resource}, cache)
+			if err != nil {
+				return nil, err
+			}
+			targetGroup.Targets = append(targetGroup.Targets, Target{metaData[0], target.Port, target.HealthCheckPort})
+		}
+		loadBalancer.TargetGroups = append(loadBalancer.TargetGroups, targetGroup)
+	}
+	return &loadBalancer, nil
+}
@@ -0,0 +1,142 @@
+package scanner
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbv2types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// fakeLoadBalancers - network load balancer 'api' with single instance target and classic load balancer 'api-legacy'
+type fakeLoadBalancers struct {
+	*fakeClient
+	nodeDescriptions []string
+	nodeGroupID      string
+}
+
+func (f *fakeLoadBalancers) DescribeLoadBalancers(ctx context.Context, params *elasticloadbalancingv2.DescribeLoadBalancersInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeLoadBalancersOutput, error) {
+	return &elasticloadbalancingv2.DescribeLoadBalancersOutput{LoadBalancers: []elbv2types.LoadBalancer{{
+		LoadBalancerArn:  aws.String("arn:aws:elasticloadbalancing:eu-west-1:123:loadbalancer/net/api/50dc6c495c0c9188"),
+		LoadBalancerName: aws.String("api"),
+		Type:             elbv2types.LoadBalancerTypeEnumNetwork,
+		VpcId:            aws.String("vpc-1"),
+	}}}, nil
+}
+
+func (f *fakeLoadBalancers) DescribeListeners(ctx context.Context, params *elasticloadbalancingv2.DescribeListenersInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeListenersOutput, error) {
+	return &elasticloadbalancingv2.DescribeListenersOutput{Listeners: []elbv2types.Listener{
+		{Port: aws.Int32(443), Protocol: elbv2types.ProtocolEnumTls},
+		{Port: aws.Int32(53), Protocol: elbv2types.ProtocolEnumTcpUdp},
+	}}, nil
+}
+
+func (f *fakeLoadBalancers) DescribeTargetGroups(ctx context.Context, params *elasticloadbalancingv2.DescribeTargetGroupsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeTargetGroupsOutput, error) {
+	return &elasticloadbalancingv2.DescribeTargetGroupsOutput{TargetGroups: []elbv2types.TargetGroup{{
+		TargetGroupArn:  aws.String("arn:tg"),
+		TargetGroupName: aws.String("api-targets"),
+		TargetType:      elbv2types.TargetTypeEnumInstance,
+		Protocol:        elbv2types.ProtocolEnumTcp,
+		Port:            aws.Int32(8080),
+		HealthCheckPort: aws.String(healthCheckTrafficPort),
+	}}}, nil
+}
+
+func (f *fakeLoadBalancers) DescribeTargetGroupAttributes(ctx context.Context, params *elasticloadbalancingv2.DescribeTargetGroupAttributesInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeTargetGroupAttributesOutput, error) {
+	return &elasticloadbalancingv2.DescribeTargetGroupAttributesOutput{Attributes: []elbv2types.TargetGroupAttribute{
+		{Key: aws.String(preserveClientIPAttribute), Value: aws.String("false")},
+	}}, nil
+}
+
+func (f *fakeLoadBalancers) DescribeTargetHealth(ctx context.Context, params *elasticloadbalancingv2.DescribeTargetHealthInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeTargetHealthOutput, error) {
+	return &elasticloadbalancingv2.DescribeTargetHealthOutput{TargetHealthDescriptions: []elbv2types.TargetHealthDescription{
+		{Target: &elbv2types.TargetDescription{Id: aws.String("i-1")}},
+		{Target: &elbv2types.TargetDescription{Id: aws.String("i-1"), Port: aws.Int32(9090)}, HealthCheckPort: aws.String("9091")},
+	}}, nil
+}
+
+func (f *fakeLoadBalancers) DescribeNetworkInterfaces(ctx context.Context, params *ec2.DescribeNetworkInterfacesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	f.nodeDescriptions = append(f.nodeDescriptions, params.Filters[0].Values[0])
+	enis := []types.NetworkInterface{}
+	for _, zone := range []string{"eu-west-1b", "eu-west-1a"} {
+		enis = append(enis, types.NetworkInterface{
+			NetworkInterfaceId: aws.String("eni-" + zone),
+			PrivateIpAddress:   aws.String("10.0.0.20"),
+			SubnetId:           aws.String("subnet-1"),
+			VpcId:              aws.String("vpc-1"),
+			AvailabilityZone:   aws.String(zone),
+		})
+	}
+	// network load balancer can have security groups attached, they are on every node
+	if f.nodeGroupID != "" {
+		for i := range enis {
+			enis[i].Groups = []types.GroupIdentifier{{GroupId: aws.String(f.nodeGroupID)}}
+		}
+	}
+	return &ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: enis}, nil
+}
+
+type fakeClassicLoadBalancers struct{}
+
+func (f *fakeClassicLoadBalancers) DescribeLoadBalancers(ctx context.Context, params *elasticloadbalancing.DescribeLoadBalancersInput, optFns ...func(*elasticloadbalancing.Options)) (*elasticloadbalancing.DescribeLoadBalancersOutput, error) {
+	return &elasticloadbalancing.DescribeLoadBalancersOutput{}, nil
+}
+
+func newFakeLoadBalancers() *fakeLoadBalancers {
+	return &fakeLoadBalancers{fakeClient: &fakeClient{
+		instancePages: [][]types.Reservation{{{Instances: []types.Instance{instance("i-1", "subnet-1", "sg-1")}}}},
+		calls:         map[string]int{},
+	}}
+}
+
+func TestScanResolvesLoadBalancerNodesAndTargets(t *testing.T) {
+	f := newFakeLoadBalancers()
+
+	data, err := ScanAws(AwsClients{EC2: f, ELBV2: f, ELB: &fakeClassicLoadBalancers{}}, "name:web", "lb:api")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ELB net/api/50dc6c495c0c9188"}, f.nodeDescriptions)
+	assert.Len(t, data.Destinations, 1)
+	nodes := data.Destinations[0].NetworkInterfaces
+	assert.Len(t, nodes, 2)
+	assert.Equal(t, "eu-west-1a", nodes[0].AvailabilityZone)
+	assert.True(t, nodes[0].WithoutSecurityGroups)
+
+	loadBalancer := data.LoadBalancer
+	assert.Equal(t, LoadBalancerNetwork, loadBalancer.Type)
+	assert.Equal(t, []Listener{{"tcp", 443}, {"tcp", 53}, {"udp", 53}}, loadBalancer.Listeners)
+	assert.False(t, loadBalancer.TargetGroups[0].PreserveClientIP)
+
+	targets := loadBalancer.TargetGroups[0].Targets
+	assert.Len(t, targets, 2)
+	assert.Equal(t, "i-1", targets[0].Resource.ID)
+	assert.Equal(t, int32(8080), targets[0].Port)
+	assert.Equal(t, int32(8080), targets[0].HealthCheckPort)
+	assert.Equal(t, int32(9090), targets[1].Port)
+	assert.Equal(t, int32(9091), targets[1].HealthCheckPort)
+	assert.Equal(t, "sg-1", *targets[0].Resource.NetworkInterfaces[0].SecurityGroups[0].GroupId)
+}
+
+func TestNetworkLoadBalancerNodesKeepAttachedSecurityGroups(t *testing.T) {
+	f := newFakeLoadBalancers()
+	f.nodeGroupID = "sg-nlb"
+
+	data, err := ScanAws(AwsClients{EC2: f, ELBV2: f, ELB: &fakeClassicLoadBalancers{}}, "name:web", "lb:api")
+
+	assert.Nil(t, err)
+	node := data.Destinations[0].NetworkInterfaces[0]
+	assert.False(t, node.WithoutSecurityGroups)
+	assert.Equal(t, "sg-nlb", *node.SecurityGroups[0].GroupId)
+}
+
+func TestLoadBalancerQueryNotFound(t *testing.T) {
+	f := newFakeLoadBalancers()
+
+	_, err := ScanAws(AwsClients{EC2: f, ELBV2: f, ELB: &fakeClassicLoadBalancers{}}, "name:web", "lb:missing")
+
+	assert.EqualError(t, err, "lb with query 'missing' not found")
+}
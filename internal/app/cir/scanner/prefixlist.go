@@ -0,0 +1,85 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// PrefixLists - entries of managed prefix lists by prefix list id
+// prefix list missing in the map couldnt be loaded
+type PrefixLists map[string][]types.PrefixListEntry
+
+// Entries - cidrs of the prefix list, false if it wasnt loaded
+func (p PrefixLists) Entries(prefixListID string) ([]types.PrefixListEntry, bool) {
+	entries, ok := p[prefixListID]
+	return entries, ok
+}
+
+// referencedPrefixLists - prefix lists used by security group rules and routes
+func referencedPrefixLists(securityGroups map[string]types.SecurityGroup, routeTables []types.RouteTable) []string {
+	ids := map[string]bool{}
+	for _, securityGroup := range securityGroups {
+		permissions := append([]types.IpPermission{}, securityGroup.IpPermissions...)
+		for _, permission := range append(permissions, securityGroup.IpPermissionsEgress...) {
+			for _, prefixList := range permission.PrefixListIds {
+				if prefixList.PrefixListId != nil {
+					ids[*prefixList.PrefixListId] = true
+				}
+			}
+		}
+	}
+	for _, routeTable := range routeTables {
+		for _, route := range routeTable.Routes {
+			if route.DestinationPrefixListId != nil {
+				ids[*route.DestinationPrefixListId] = true
+			}
+		}
+	}
+	return sortedKeys(ids)
+}
+
+// GetManagedPrefixListEntries - all entries of the prefix list, the call is paginated
+func GetManagedPrefixListEntries(client EC2API, prefixListID string) ([]types.PrefixListEntry, error) {
+	entries := []types.PrefixListEntry{}
+	var nextToken *string
+	for {
+		result, err := client.GetManagedPrefixListEntries(context.Background(), &ec2.GetManagedPrefixListEntriesInput{
+			PrefixListId: aws.String(prefixListID),
+			NextToken:    nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error when looking for prefix list %s entries %s", prefixListID, err)
+		}
+		entries = append(entries, result.Entries...)
+		if result.NextToken == nil {
+			return entries, nil
+		}
+		nextToken = result.NextToken
+	}
+}
+
+// loadPrefixLists - there is no batch call so every prefix list is loaded on its own
+// prefix list which cant be read eg. shared from other account is left out and reported as unknown by the checks
+func (c *resourceCache) loadPrefixLists(client EC2API) {
+	routeTables := []types.RouteTable{}
+	for _, routeTable := range c.subnetRouteTables {
+		routeTables = append(routeTables, routeTable)
+	}
+	for _, routeTable := range c.mainRouteTables {
+		routeTables = append(routeTables, routeTable)
+	}
+
+	for _, prefixListID := range referencedPrefixLists(c.securityGroups, routeTables) {
+		log.Debugf("looking for entries of prefix list %s", prefixListID)
+		entries, err := GetManagedPrefixListEntries(client, prefixListID)
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+		c.prefixLists[prefixListID] = entries
+	}
+}
@@ -0,0 +1,54 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// fakePrefixLists - every security group allows pl-office and pl-partner, only pl-office can be read
+type fakePrefixLists struct {
+	*fakeClient
+}
+
+func (f *fakePrefixLists) DescribeSecurityGroups(ctx context.Context, params *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error) {
+	groups := []types.SecurityGroup{}
+	for _, id := range params.GroupIds {
+		groups = append(groups, types.SecurityGroup{GroupId: aws.String(id), IpPermissions: []types.IpPermission{{
+			PrefixListIds: []types.PrefixListId{{PrefixListId: aws.String("pl-office")}, {PrefixListId: aws.String("pl-partner")}},
+		}}})
+	}
+	return &ec2.DescribeSecurityGroupsOutput{SecurityGroups: groups}, nil
+}
+
+func (f *fakePrefixLists) GetManagedPrefixListEntries(ctx context.Context, params *ec2.GetManagedPrefixListEntriesInput, optFns ...func(*ec2.Options)) (*ec2.GetManagedPrefixListEntriesOutput, error) {
+	f.calls["GetManagedPrefixListEntries"]++
+	if *params.PrefixListId != "pl-office" {
+		return nil, fmt.Errorf("access denied")
+	}
+	if params.NextToken == nil {
+		return &ec2.GetManagedPrefixListEntriesOutput{Entries: []types.PrefixListEntry{{Cidr: aws.String("192.168.0.0/16")}}, NextToken: aws.String("1")}, nil
+	}
+	return &ec2.GetManagedPrefixListEntriesOutput{Entries: []types.PrefixListEntry{{Cidr: aws.String("172.16.0.0/12")}}}, nil
+}
+
+func TestPrefixListsUsedBySecurityGroupsAreLoadedOnce(t *testing.T) {
+	client := &fakePrefixLists{fakeClient: &fakeClient{
+		instancePages: [][]types.Reservation{{{Instances: []types.Instance{instance("i-1", "subnet-1", "sg-1"), instance("i-2", "subnet-2", "sg-2")}}}},
+		calls:         map[string]int{},
+	}}
+
+	data, err := ScanAws(AwsClients{EC2: client}, "name:web", "name:web")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, client.calls["GetManagedPrefixListEntries"])
+	entries, loaded := data.Sources[1].NetworkInterfaces[0].PrefixLists.Entries("pl-office")
+	assert.True(t, loaded)
+	assert.Len(t, entries, 2)
+	_, loaded = data.Sources[1].NetworkInterfaces[0].PrefixLists.Entries("pl-partner")
+	assert.False(t, loaded)
+}
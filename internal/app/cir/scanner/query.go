@@ -0,0 +1,201 @@
+package scanner
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"net"
+	"path"
+	"strings"
+)
+
+// QueryKind - type of the query term, the part before ':'
+type QueryKind string
+
+// Supported query kinds
+const (
+	QueryIP     QueryKind = "ip"
+	QueryName   QueryKind = "name"
+	QueryID     QueryKind = "id"
+	QueryTag    QueryKind = "tag"
+	QuerySG     QueryKind = "sg"
+	QuerySubnet QueryKind = "subnet"
+	QueryVpc    QueryKind = "vpc"
+	QueryASG    QueryKind = "asg"
+)
+
+// Query kinds resolving resources of other services than ec2, they cant be combined with other terms
+const (
+	QueryRDS         QueryKind = "rds"
+	QueryRDSCluster  QueryKind = "rds-cluster"
+	QueryElastiCache QueryKind = "elasticache"
+	QueryLB          QueryKind = "lb"
+	QueryECS         QueryKind = "ecs"
+	QueryLambda      QueryKind = "lambda"
+	QueryENI         QueryKind = "eni"
+	QueryHost        QueryKind = "host"
+	QueryCIDR        QueryKind = "cidr"
+	// QueryInternet - whole internet, used without value
+	QueryInternet QueryKind = "internet"
+)
+
+// queryTermSeparator - terms separated by it have to match all (AND)
+const queryTermSeparator = ","
+
+const asgTagName = "aws:autoscaling:groupName"
+
+// QueryTerm - single filter eg. tag:Team=payments, Key is only used by tag
+type QueryTerm struct {
+	Kind  QueryKind
+	Key   string
+	Value string
+}
+
+// Query - all terms have to match
+type Query []QueryTerm
+
+func isQueryKind(kind QueryKind) bool {
+	switch kind {
+	case QueryIP, QueryName, QueryID, QueryTag, QuerySG, QuerySubnet, QueryVpc, QueryASG:
+		return true
+	}
+	return isServiceQueryKind(kind)
+}
+
+func isServiceQueryKind(kind QueryKind) bool {
+	switch kind {
+	case QueryRDS, QueryRDSCluster, QueryElastiCache, QueryLB, QueryECS, QueryLambda, QueryENI, QueryHost, QueryCIDR, QueryInternet:
+		return true
+	}
+	return false
+}
+
+// HasWildcard - aws filters support '*' and '?' wildcards
+func (t QueryTerm) HasWildcard() bool {
+	return strings.ContainsAny(t.Key+t.Value, "*?")
+}
+
+func parseQueryTerm(term string) (QueryTerm, error) {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return QueryTerm{}, fmt.Errorf("empty query term")
+	}
+
+	if strings.EqualFold(term, string(QueryInternet)) {
+		return QueryTerm{Kind: QueryInternet}, nil
+	}
+
+	separatorIndex := strings.Index(term, ":")
+	if separatorIndex < 0 {
+		// just assume that by default we do search by IP
+		return QueryTerm{Kind: QueryIP, Value: term}, nil
+	}
+
+	kind := QueryKind(strings.ToLower(term[:separatorIndex]))
+	value := term[separatorIndex+1:]
+	if !isQueryKind(kind) {
+		if net.ParseIP(term) != nil {
+			return QueryTerm{Kind: QueryIP, Value: term}, nil
+		}
+		return QueryTerm{}, fmt.Errorf("unknown query type '%s' - use ip, name, id, tag, sg, subnet, vpc, asg, rds, rds-cluster, elasticache, lb, ecs, lambda, eni, host, cidr or internet", kind)
+	}
+
+	if value == "" {
+		return QueryTerm{}, fmt.Errorf("query '%s' has no value", term)
+	}
+
+	if kind != QueryTag {
+		return QueryTerm{Kind: kind, Value: value}, nil
+	}
+
+	// tag:Key matches any value, tag:Key=Value only the given value
+	keyValue := strings.SplitN(value, "=", 2)
+	if keyValue[0] == "" {
+		return QueryTerm{}, fmt.Errorf("query '%s' has no tag key", term)
+	}
+	if len(keyValue) == 1 {
+		return QueryTerm{Kind: kind, Key: keyValue[0]}, nil
+	}
+	return QueryTerm{Kind: kind, Key: keyValue[0], Value: keyValue[1]}, nil
+}
+
+// ParseQuery - parses query eg. 'tag:Team=payments,subnet:subnet-123' where all terms have to match
+func ParseQuery(query string) (Query, error) {
+	parsed := Query{}
+	filterNames := map[string]bool{}
+	terms := strings.Split(query, queryTermSeparator)
+	for _, term := range terms {
+		queryTerm, err := parseQueryTerm(term)
+		if err != nil {
+			return nil, err
+		}
+
+		if isServiceQueryKind(queryTerm.Kind) {
+			if len(terms) > 1 {
+				return nil, fmt.Errorf("query '%s' - %s cant be combined with other terms", query, queryTerm.Kind)
+			}
+			return Query{queryTerm}, nil
+		}
+
+		// aws treats multiple values of one filter as OR so the same filter cant be used twice
+		filterName := *queryTerm.filter().Name
+		if filterNames[filterName] {
+			return nil, fmt.Errorf("query '%s' uses '%s' more than once - use wildcards instead", query, queryTerm.Kind)
+		}
+		filterNames[filterName] = true
+		parsed = append(parsed, queryTerm)
+	}
+	return parsed, nil
+}
+
+// ServiceTerm - returns the term if query resolves resources of other service than ec2
+func (q Query) ServiceTerm() (QueryTerm, bool) {
+	if len(q) == 1 && isServiceQueryKind(q[0].Kind) {
+		return q[0], true
+	}
+	return QueryTerm{}, false
+}
+
+// Matches - checks if value matches the term value with '*' and '?' wildcards
+// used for services which dont support wildcards in their filters
+func (t QueryTerm) Matches(value string) bool {
+	matched, err := path.Match(t.Value, value)
+	return err == nil && matched
+}
+
+// filter - DescribeInstances filter matching the term
+func (t QueryTerm) filter() types.Filter {
+	switch t.Kind {
+	case QueryName:
+		return types.Filter{Name: aws.String("tag:Name"), Values: []string{t.Value}}
+	case QueryID:
+		return types.Filter{Name: aws.String("instance-id"), Values: []string{t.Value}}
+	case QueryTag:
+		if t.Value == "" {
+			return types.Filter{Name: aws.String("tag-key"), Values: []string{t.Key}}
+		}
+		return types.Filter{Name: aws.String("tag:" + t.Key), Values: []string{t.Value}}
+	case QuerySG:
+		return types.Filter{Name: aws.String("network-interface.group-id"), Values: []string{t.Value}}
+	case QuerySubnet:
+		return types.Filter{Name: aws.String("network-interface.subnet-id"), Values: []string{t.Value}}
+	case QueryVpc:
+		return types.Filter{Name: aws.String("vpc-id"), Values: []string{t.Value}}
+	case QueryASG:
+		return types.Filter{Name: aws.String("tag:" + asgTagName), Values: []string{t.Value}}
+	}
+	// wildcard ip cant be parsed so the family is told by its separator
+	if strings.Contains(t.Value, ":") {
+		return types.Filter{Name: aws.String("network-interface.ipv6-addresses.ipv6-address"), Values: []string{t.Value}}
+	}
+	return types.Filter{Name: aws.String("network-interface.addresses.private-ip-address"), Values: []string{t.Value}}
+}
+
+// Filters - DescribeInstances filters, aws applies AND between them
+func (q Query) Filters() []types.Filter {
+	filters := []types.Filter{}
+	for _, term := range q {
+		filters = append(filters, term.filter())
+	}
+	return filters
+}
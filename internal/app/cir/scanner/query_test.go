@@ -0,0 +1,89 @@
+package scanner
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestQueryKindIsTakenFromPrefixOnly(t *testing.T) {
+	query, err := ParseQuery("name:proxy-ip:3128")
+
+	assert.Nil(t, err)
+	assert.Equal(t, Query{{Kind: QueryName, Value: "proxy-ip:3128"}}, query)
+	assert.Equal(t, "tag:Name", *query.Filters()[0].Name)
+}
+
+func TestQueryWithoutPrefixIsIP(t *testing.T) {
+	query, err := ParseQuery("10.0.0.1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "network-interface.addresses.private-ip-address", *query.Filters()[0].Name)
+	assert.Equal(t, []string{"10.0.0.1"}, query.Filters()[0].Values)
+}
+
+func TestIpv6QueryUsesIpv6Filter(t *testing.T) {
+	for _, value := range []string{"2600:1f18::5", "ip:2600:1f18::5", "ip:2600:1f18::*"} {
+		query, err := ParseQuery(value)
+
+		assert.Nil(t, err, value)
+		assert.Equal(t, "network-interface.ipv6-addresses.ipv6-address", *query.Filters()[0].Name, value)
+	}
+}
+
+func TestQueryTermsAreMappedToFilters(t *testing.T) {
+	query, err := ParseQuery("id:i-1,tag:Team=payments,sg:sg-1,subnet:subnet-1,vpc:vpc-1,asg:web-*,tag:aws:cloudformation:stack-name")
+
+	assert.Nil(t, err)
+	filters := query.Filters()
+	expected := [][]string{
+		{"instance-id", "i-1"},
+		{"tag:Team", "payments"},
+		{"network-interface.group-id", "sg-1"},
+		{"network-interface.subnet-id", "subnet-1"},
+		{"vpc-id", "vpc-1"},
+		{"tag:aws:autoscaling:groupName", "web-*"},
+		{"tag-key", "aws:cloudformation:stack-name"},
+	}
+	assert.Len(t, filters, len(expected))
+	for i, filter := range filters {
+		assert.Equal(t, expected[i][0], *filter.Name)
+		assert.Equal(t, []string{expected[i][1]}, filter.Values)
+	}
+}
+
+func TestInvalidQueriesReturnError(t *testing.T) {
+	invalidQueries := []string{
+		"unknown:value",
+		"vpc:",
+		"tag:=value",
+		"vpc:vpc-1,",
+		"sg:sg-1,sg:sg-2",
+		"rds:my-db,vpc:vpc-1",
+		"elasticache:",
+		"ecs:prod/api,vpc:vpc-1",
+		"lambda:",
+		"eni:eni-1,vpc:vpc-1",
+		"internet,vpc:vpc-1",
+	}
+
+	for _, query := range invalidQueries {
+		_, err := ParseQuery(query)
+		assert.NotNil(t, err, query)
+	}
+}
+
+func TestServiceQueryIsSingleTerm(t *testing.T) {
+	query, err := ParseQuery("rds-cluster:orders-*")
+
+	assert.Nil(t, err)
+	term, ok := query.ServiceTerm()
+	assert.True(t, ok)
+	assert.Equal(t, QueryRDSCluster, term.Kind)
+	assert.True(t, term.Matches("orders-eu"))
+	assert.False(t, term.Matches("payments"))
+
+	query, err = ParseQuery("name:web")
+	assert.Nil(t, err)
+	_, ok = query.ServiceTerm()
+	assert.False(t, ok)
+}
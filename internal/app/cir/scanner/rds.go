@@ -0,0 +1,120 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	log "github.com/sirupsen/logrus"
+)
+
+func describeDBInstances(client RDSAPI, input rds.DescribeDBInstancesInput) ([]types.DBInstance, error) {
+	instances := []types.DBInstance{}
+	for {
+		result, err := client.DescribeDBInstances(context.Background(), &input)
+		if err != nil {
+			return nil, fmt.Errorf("error when looking for rds %s", err)
+		}
+		instances = append(instances, result.DBInstances...)
+		if result.Marker == nil {
+			return instances, nil
+		}
+		input.Marker = result.Marker
+	}
+}
+
+func describeDBClusters(client RDSAPI, input rds.DescribeDBClustersInput) ([]types.DBCluster, error) {
+	clusters := []types.DBCluster{}
+	for {
+		result, err := client.DescribeDBClusters(context.Background(), &input)
+		if err != nil {
+			return nil, fmt.Errorf("error when looking for rds cluster %s", err)
+		}
+		clusters = append(clusters, result.DBClusters...)
+		if result.Marker == nil {
+			return clusters, nil
+		}
+		input.Marker = result.Marker
+	}
+}
+
+// rdsInstancesToResources - instances without endpoint (eg. still creating) cant be reached so they are skipped
+func rdsInstancesToResources(instances []types.DBInstance, query QueryTerm, clients AwsClients) ([]resource, error) {
+	resources := []resource{}
+	for _, instance := range instances {
+		if instance.Endpoint == nil || instance.Endpoint.Address == nil {
+			log.Warnf("rds %s has no endpoint - skipping", *instance.DBInstanceIdentifier)
+			continue
+		}
+
+		instanceResource, err := endpointResource(*instance.DBInstanceIdentifier, *instance.Endpoint.Address, clients)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, instanceResource)
+	}
+
+	if len(resources) <= 0 {
+		return nil, fmt.Errorf("%s with query '%s' not found", query.Kind, query.Value)
+	}
+	return resources, nil
+}
+
+// findRDSInstances - rds api doesnt support wildcards so all instances are listed and matched locally
+func findRDSInstances(query QueryTerm, clients AwsClients) ([]resource, error) {
+	if clients.RDS == nil {
+		return nil, fmt.Errorf("rds client not configured")
+	}
+
+	input := rds.DescribeDBInstancesInput{}
+	if !query.HasWildcard() {
+		input.DBInstanceIdentifier = aws.String(query.Value)
+	}
+
+	instances, err := describeDBInstances(clients.RDS, input)
+	if err != nil {
+		return nil, err
+	}
+
+	matching := []types.DBInstance{}
+	for _, instance := range instances {
+		if query.Matches(*instance.DBInstanceIdentifier) {
+			matching = append(matching, instance)
+		}
+	}
+	return rdsInstancesToResources(matching, query, clients)
+}
+
+// findRDSClusterInstances - every instance (writer and readers) of aurora cluster is a separate resource
+func findRDSClusterInstances(query QueryTerm, clients AwsClients) ([]resource, error) {
+	if clients.RDS == nil {
+		return nil, fmt.Errorf("rds client not configured")
+	}
+
+	clusterIDs := []string{query.Value}
+	if query.HasWildcard() {
+		clusters, err := describeDBClusters(clients.RDS, rds.DescribeDBClustersInput{})
+		if err != nil {
+			return nil, err
+		}
+		clusterIDs = []string{}
+		for _, cluster := range clusters {
+			if query.Matches(*cluster.DBClusterIdentifier) {
+				clusterIDs = append(clusterIDs, *cluster.DBClusterIdentifier)
+			}
+		}
+	}
+
+	instances := []types.DBInstance{}
+	for _, batch := range batches(clusterIDs) {
+		batchInstances, err := describeDBInstances(clients.RDS, rds.DescribeDBInstancesInput{
+			Filters: []types.Filter{{Name: aws.String("db-cluster-id"), Values: batch}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, batchInstances...)
+	}
+	return rdsInstancesToResources(instances, query, clients)
+}
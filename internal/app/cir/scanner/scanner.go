@@ -3,180 +3,532 @@ package scanner
 import (
 	"context"
 	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	log "github.com/sirupsen/logrus"
-	"strings"
+	"net"
+	"sort"
 )
 
+// NetworkInterface - single network interface (eni) attached to the resource with its own subnet and security groups
+type NetworkInterface struct {
+	ID string
+	// empty if the interface is analysed only over ipv6 eg. ipv6 only subnet or resource found by ipv6 address
+	PrivateIP  string
+	PrivateIPs []string
+	// ipv6 addresses of dual stack interface, analysed separately from the ipv4 ones
+	IPv6s    []string
+	SubnetID string
+	// needed for transit gateway which routes only from availability zones it has subnet attached in
+	AvailabilityZone string
+	SecurityGroups   []types.SecurityGroup
+	RouteTable       types.RouteTable
+	// true if subnet has no explicit route table association and uses the main route table of the vpc
+	RouteTableIsMain bool
+	NetworkAcl       types.NetworkAcl
+	// entries of prefix lists used by security groups and route table of the interface
+	PrefixLists PrefixLists
+	// true for interfaces which traffic isnt filtered by security groups eg. network load balancer nodes
+	WithoutSecurityGroups bool
+	// set for interfaces found directly by eni or ip query, tells which resource the interface belongs to
+	Owner InterfaceOwner
+	// empty if the interface has no public ip, used to reach the internet through internet gateway
+	PublicIP string
+	// true for addresses outside of aws network, they have no subnet, route table or security groups
+	Internet bool
+	// range found by cidr query, the address is its first one and the analysis splits it by the rules inside it
+	Cidr string
+}
+
+// InterfaceOwner - what the network interface was created for eg. efs mount target or vpc endpoint
+type InterfaceOwner struct {
+	Description string
+	// aws service or account which created the interface on our behalf eg. amazon-elasticfilesystem
+	RequesterID string
+}
+
+// String - empty if the interface has no description and wasnt created by aws service
+func (o InterfaceOwner) String() string {
+	switch {
+	case o.Description != "" && o.RequesterID != "":
+		return fmt.Sprintf("%s, requested by %s", o.Description, o.RequesterID)
+	case o.RequesterID != "":
+		return fmt.Sprintf("requested by %s", o.RequesterID)
+	}
+	return o.Description
+}
+
+// SecurityGroupIDs - ids of all security groups attached to the network interface
+func (n NetworkInterface) SecurityGroupIDs() []string {
+	ids := []string{}
+	for _, securityGroup := range n.SecurityGroups {
+		ids = append(ids, *securityGroup.GroupId)
+	}
+	return ids
+}
+
 // ResourceNetworkMetaData - main struct for single aws resource network metadata
 type ResourceNetworkMetaData struct {
-	ID            string
-	PrivateIP     string
-	VpcID         string
-	SecurityGroup types.SecurityGroup
-	SubnetID      string
-	RouteTable    types.RouteTable
+	ID                string
+	VpcID             string
+	NetworkInterfaces []NetworkInterface
 }
 
 // AwsData - main struct holding scanned resources for further processing
 type AwsData struct {
 	Sources      []ResourceNetworkMetaData
 	Destinations []ResourceNetworkMetaData
+	// set if destination is load balancer, destinations are its nodes
+	LoadBalancer *LoadBalancer
+}
+
+// resource - aws resource found by the query with its network interfaces
+// security groups, route tables and network acls are loaded later for all resources at once
+type resource struct {
+	ID         string
+	VpcID      string
+	Interfaces []interfaceRef
+}
+
+// isInternet - internet resources are the only ones found by internet queries
+func isInternet(resources []resource) bool {
+	return len(resources) > 0 && len(resources[0].Interfaces) > 0 && resources[0].Interfaces[0].Internet
 }
 
-// ScanAwsEc2 - initiates ec2 aws scan
-func ScanAwsEc2(client *ec2.Client, sourceQuery string, destinationQuery string) (*AwsData, error) {
-	ec2InstancesSource, err := findEC2s(sourceQuery, client)
-	log.Debugf("Found %d source instances\n", len(ec2InstancesSource))
+// interfaceRef - network interface of the resource with ids of things it is using
+type interfaceRef struct {
+	ID               string
+	PrivateIP        string
+	PrivateIPs       []string
+	IPv6s            []string
+	SubnetID         string
+	VpcID            string
+	AvailabilityZone string
+	GroupIDs         []string
+	// network load balancer nodes dont have security groups
+	WithoutSecurityGroups bool
+	Owner                 InterfaceOwner
+	PublicIP              string
+	Internet              bool
+	Cidr                  string
+}
+
+// ScanAws - finds resources matching source and destination queries and loads their network configuration
+func ScanAws(clients AwsClients, sourceQuery string, destinationQuery string) (*AwsData, error) {
+	sourceResources, err := findResources(sourceQuery, clients)
+	log.Debugf("Found %d source resources\n", len(sourceResources))
 	if err != nil {
 		return nil, err
 	}
+	destinationResources, loadBalancer, err := findDestinationResources(destinationQuery, clients)
+	log.Debugf("Found %d destination resources\n", len(destinationResources))
+	if err != nil {
+		return nil, err
+	}
+
+	if isInternet(sourceResources) && isInternet(destinationResources) {
+		return nil, fmt.Errorf("source and destination are both outside of aws network")
+	}
+
+	allResources := append(append([]resource{}, sourceResources...), destinationResources...)
+	if loadBalancer != nil {
+		allResources = append(allResources, loadBalancer.targetResources()...)
+	}
 
-	ec2InstancesDestination, err := findEC2s(destinationQuery, client)
-	log.Debugf("Found %d destination instances\n", len(ec2InstancesSource))
+	cache, err := loadResourceCache(allResources, clients.EC2)
 	if err != nil {
 		return nil, err
 	}
 
-	awsData := AwsData{
-		Sources:      []ResourceNetworkMetaData{},
-		Destinations: []ResourceNetworkMetaData{},
+	sources, err := getNetworkMetaData(sourceResources, cache)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, ec2Instance := range ec2InstancesSource {
-		metaDataInstance := ResourceNetworkMetaData{
-			ID:        *ec2Instance.InstanceId,
-			PrivateIP: *ec2Instance.PrivateIpAddress,
-			VpcID:     *ec2Instance.VpcId,
-			SubnetID:  *ec2Instance.SubnetId,
-		}
+	destinations, err := getNetworkMetaData(destinationResources, cache)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &AwsData{
+		Sources:      sources,
+		Destinations: destinations,
+	}
 
-		securityGroup, err := getSecurityGroupsByID(ec2Instance, client)
+	if loadBalancer != nil {
+		data.LoadBalancer, err = loadBalancer.withNetworkMetaData(cache)
 		if err != nil {
 			return nil, err
 		}
-		metaDataInstance.SecurityGroup = securityGroup
+	}
+	return data, nil
+}
 
-		routeTable, err := getRouteTablesForEc2(ec2Instance, client)
+// ScanDestinations - finds resources matching the query and loads their network configuration, used when sources arent known
+// load balancer query returns its nodes
+func ScanDestinations(clients AwsClients, destinationQuery string) (*AwsData, error) {
+	destinationResources, err := findResources(destinationQuery, clients)
+	log.Debugf("Found %d destination resources\n", len(destinationResources))
+	if err != nil {
+		return nil, err
+	}
+
+	if isInternet(destinationResources) {
+		return nil, fmt.Errorf("destination is outside of aws network")
+	}
+
+	cache, err := loadResourceCache(destinationResources, clients.EC2)
+	if err != nil {
+		return nil, err
+	}
+
+	destinations, err := getNetworkMetaData(destinationResources, cache)
+	if err != nil {
+		return nil, err
+	}
+	return &AwsData{Sources: []ResourceNetworkMetaData{}, Destinations: destinations}, nil
+}
+
+// findDestinationResources - for load balancer its nodes are the destinations and its targets are loaded for the second leg
+func findDestinationResources(query string, clients AwsClients) ([]resource, *loadBalancerRef, error) {
+	parsedQuery, err := ParseQuery(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if term, ok := parsedQuery.ServiceTerm(); ok && term.Kind == QueryLB {
+		loadBalancer, err := findLoadBalancer(term, clients)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		metaDataInstance.RouteTable = routeTable
+		if err := loadBalancer.loadTargets(clients); err != nil {
+			return nil, nil, err
+		}
+		return []resource{loadBalancer.Nodes}, loadBalancer, nil
+	}
 
-		awsData.Sources = append(awsData.Sources, metaDataInstance)
+	resources, err := findResources(query, clients)
+	return resources, nil, err
+}
+
+// findResources - ec2 instances by default, other services if the query uses their type eg. rds:my-db
+func findResources(query string, clients AwsClients) ([]resource, error) {
+	parsedQuery, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, ec2Instance := range ec2InstancesDestination {
-		metaDataInstance := ResourceNetworkMetaData{
-			ID:        *ec2Instance.InstanceId,
-			PrivateIP: *ec2Instance.PrivateIpAddress,
-			VpcID:     *ec2Instance.VpcId,
-			SubnetID:  *ec2Instance.SubnetId,
+	if term, ok := parsedQuery.ServiceTerm(); ok {
+		switch term.Kind {
+		case QueryRDS:
+			return findRDSInstances(term, clients)
+		case QueryRDSCluster:
+			return findRDSClusterInstances(term, clients)
+		case QueryElastiCache:
+			return findElastiCacheNodes(term, clients)
+		case QueryLB:
+			loadBalancer, err := findLoadBalancer(term, clients)
+			if err != nil {
+				return nil, err
+			}
+			return []resource{loadBalancer.Nodes}, nil
+		case QueryECS:
+			return findECSTasks(term, clients)
+		case QueryLambda:
+			return findLambdaFunctions(term, clients)
+		case QueryENI:
+			return findNetworkInterfaces(term, clients)
+		case QueryHost:
+			return findHost(term, clients)
+		case QueryCIDR:
+			return findCIDR(term)
+		case QueryInternet:
+			return []resource{internetResource(string(QueryInternet), internetAddresses)}, nil
 		}
+	}
+
+	if len(parsedQuery) == 1 && parsedQuery[0].Kind == QueryIP {
+		return findByIP(parsedQuery[0], clients)
+	}
 
-		securityGroup, err := getSecurityGroupsByID(ec2Instance, client)
+	ec2Instances, err := findEC2s(query, clients.EC2)
+	if err != nil {
+		return nil, err
+	}
+	return instancesToResources(ec2Instances)
+}
+
+// findByIP - ip not owned by any instance can still belong to other resource backed by network interface
+func findByIP(query QueryTerm, clients AwsClients) ([]resource, error) {
+	ec2Instances, err := describeInstances(clients.EC2, Query{query}.Filters())
+	if err != nil {
+		return nil, err
+	}
+	if len(ec2Instances) > 0 {
+		resources, err := instancesToResources(ec2Instances)
 		if err != nil {
 			return nil, err
 		}
-		metaDataInstance.SecurityGroup = securityGroup
+		return onlyAddressFamilyOf(resources, query.Value), nil
+	}
+
+	log.Debugf("no ec2 with ip %s - looking for network interface", query.Value)
+	eni, err := findNetworkInterfaceByIP(query.Value, clients.EC2)
+	if err != nil {
+		return nil, err
+	}
+	if eni != nil {
+		return onlyAddressFamilyOf([]resource{eniToResource(*eni, query.Value)}, query.Value), nil
+	}
+
+	if IsPublicIP(net.ParseIP(query.Value)) {
+		log.Debugf("%s is not owned by any network interface - analysing it as internet address", query.Value)
+		return []resource{internetResource(query.Value, []string{query.Value})}, nil
+	}
+	return nil, fmt.Errorf("ec2 or network interface with ip '%s' not found", query.Value)
+}
 
-		routeTable, err := getRouteTablesForEc2(ec2Instance, client)
+// onlyAddressFamilyOf - resource found by ip is analysed only over the address family of the ip
+// the queried ipv6 address is the only one analysed, interfaces of the resource not holding it are dropped
+func onlyAddressFamilyOf(resources []resource, ip string) []resource {
+	ipv6 := IsIPv6(net.ParseIP(ip))
+	for i := range resources {
+		interfaces := []interfaceRef{}
+		for _, ref := range resources[i].Interfaces {
+			if !ipv6 {
+				ref.IPv6s = []string{}
+				interfaces = append(interfaces, ref)
+				continue
+			}
+			for _, address := range ref.IPv6s {
+				if address == ip {
+					ref.PrivateIP = ""
+					ref.IPv6s = []string{ip}
+					interfaces = append(interfaces, ref)
+					break
+				}
+			}
+		}
+		resources[i].Interfaces = interfaces
+	}
+	return resources
+}
+
+func instancesToResources(ec2Instances []types.Instance) ([]resource, error) {
+	resources := []resource{}
+	for _, ec2Instance := range ec2Instances {
+		instanceResource, err := instanceToResource(ec2Instance)
 		if err != nil {
 			return nil, err
 		}
-		metaDataInstance.RouteTable = routeTable
+		resources = append(resources, instanceResource)
+	}
+	return resources, nil
+}
+
+func instanceToResource(ec2Instance types.Instance) (resource, error) {
+	if len(ec2Instance.NetworkInterfaces) <= 0 {
+		return resource{}, fmt.Errorf("no network interfaces found for ec2:%s", *ec2Instance.InstanceId)
+	}
 
-		awsData.Destinations = append(awsData.Destinations, metaDataInstance)
+	instanceResource := resource{
+		ID:         *ec2Instance.InstanceId,
+		VpcID:      *ec2Instance.VpcId,
+		Interfaces: []interfaceRef{},
 	}
 
-	return &awsData, nil
+	// primary network interface first as this is the one most of the traffic goes through
+	instanceInterfaces := append([]types.InstanceNetworkInterface{}, ec2Instance.NetworkInterfaces...)
+	sort.Slice(instanceInterfaces, func(i, j int) bool {
+		return deviceIndex(instanceInterfaces[i]) < deviceIndex(instanceInterfaces[j])
+	})
+
+	for _, instanceInterface := range instanceInterfaces {
+		ref := interfaceRef{
+			ID:         *instanceInterface.NetworkInterfaceId,
+			PrivateIP:  aws.ToString(instanceInterface.PrivateIpAddress),
+			PrivateIPs: []string{},
+			IPv6s:      []string{},
+			SubnetID:   *instanceInterface.SubnetId,
+			VpcID:      *instanceInterface.VpcId,
+			GroupIDs:   []string{},
+		}
+		for _, privateIP := range instanceInterface.PrivateIpAddresses {
+			ref.PrivateIPs = append(ref.PrivateIPs, *privateIP.PrivateIpAddress)
+		}
+		for _, ipv6 := range instanceInterface.Ipv6Addresses {
+			ref.IPv6s = append(ref.IPv6s, *ipv6.Ipv6Address)
+		}
+		if instanceInterface.Association != nil && instanceInterface.Association.PublicIp != nil {
+			ref.PublicIP = *instanceInterface.Association.PublicIp
+		}
+		for _, group := range instanceInterface.Groups {
+			ref.GroupIDs = append(ref.GroupIDs, *group.GroupId)
+		}
+		if ec2Instance.Placement != nil && ec2Instance.Placement.AvailabilityZone != nil {
+			ref.AvailabilityZone = *ec2Instance.Placement.AvailabilityZone
+		}
+		instanceResource.Interfaces = append(instanceResource.Interfaces, ref)
+	}
+
+	return instanceResource, nil
 }
 
-func findEC2s(query string, client *ec2.Client) ([]types.Instance, error) {
-	log.Debugf("Looking for EC2 with query: '%s'", query)
-	var filterName string
-	var filterValue string
-	if strings.Contains(query, "ip:") {
-		filterName = "network-interface.addresses.private-ip-address"
-		filterValue = query[3:]
-	} else if strings.Contains(query, "name:") {
-		filterName = "tag:Name"
-		filterValue = query[5:]
-	} else {
-		// just assume that by default we do search by IP
-		filterName = "network-interface.addresses.private-ip-address"
-		filterValue = query
+func getNetworkMetaData(resources []resource, cache *resourceCache) ([]ResourceNetworkMetaData, error) {
+	listOfMetaData := []ResourceNetworkMetaData{}
+
+	for _, r := range resources {
+		metaData := ResourceNetworkMetaData{
+			ID:                r.ID,
+			VpcID:             r.VpcID,
+			NetworkInterfaces: []NetworkInterface{},
+		}
+
+		for _, ref := range r.Interfaces {
+			networkInterface, err := getNetworkInterface(ref, cache)
+			if err != nil {
+				return nil, err
+			}
+			metaData.NetworkInterfaces = append(metaData.NetworkInterfaces, networkInterface)
+		}
+
+		listOfMetaData = append(listOfMetaData, metaData)
 	}
-	queryEc2 := &ec2.DescribeInstancesInput{
-		Filters: []types.Filter{
-			{
-				Name:   &filterName,
-				Values: []string{filterValue},
-			},
-		},
+
+	return listOfMetaData, nil
+}
+
+func deviceIndex(instanceInterface types.InstanceNetworkInterface) int32 {
+	if instanceInterface.Attachment == nil {
+		return 0
 	}
+	return instanceInterface.Attachment.DeviceIndex
+}
 
-	ec2result, err := client.DescribeInstances(context.Background(), queryEc2)
+func getNetworkInterface(ref interfaceRef, cache *resourceCache) (NetworkInterface, error) {
+	networkInterface := NetworkInterface{
+		ID:                    ref.ID,
+		PrivateIP:             ref.PrivateIP,
+		PrivateIPs:            ref.PrivateIPs,
+		IPv6s:                 ref.IPv6s,
+		SubnetID:              ref.SubnetID,
+		AvailabilityZone:      ref.AvailabilityZone,
+		WithoutSecurityGroups: ref.WithoutSecurityGroups,
+		Owner:                 ref.Owner,
+		PublicIP:              ref.PublicIP,
+		Internet:              ref.Internet,
+		Cidr:                  ref.Cidr,
+	}
 
+	if ref.Internet {
+		return networkInterface, nil
+	}
+
+	if !ref.WithoutSecurityGroups {
+		securityGroups, err := getSecurityGroupsByIDs(ref.GroupIDs, networkInterface.ID, cache)
+		if err != nil {
+			return NetworkInterface{}, err
+		}
+		networkInterface.SecurityGroups = securityGroups
+	}
+
+	routeTable, isMain, err := getRouteTableForSubnet(networkInterface.SubnetID, ref.VpcID, cache)
 	if err != nil {
-		return nil, fmt.Errorf("error when looking for ec2 %s", err)
+		return NetworkInterface{}, err
 	}
+	networkInterface.RouteTable = routeTable
+	networkInterface.RouteTableIsMain = isMain
 
-	if len(ec2result.Reservations) <= 0 {
-		return nil, fmt.Errorf("ec2 with query '%s' not found", query)
+	networkAcl, err := getNetworkAclForSubnet(networkInterface.SubnetID, cache)
+	if err != nil {
+		return NetworkInterface{}, err
 	}
-	if len(ec2result.Reservations[0].Instances) <= 0 {
-		return nil, fmt.Errorf("ec2 with query '%s' not found", query)
+	networkInterface.NetworkAcl = networkAcl
+	networkInterface.PrefixLists = cache.prefixLists
+
+	return networkInterface, nil
+}
+
+func findEC2s(query string, client EC2API) ([]types.Instance, error) {
+	log.Debugf("Looking for EC2 with query: '%s'", query)
+	parsedQuery, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
 	}
 
-	instances := &[]types.Instance{}
+	instances, err := describeInstances(client, parsedQuery.Filters())
+	if err != nil {
+		return nil, err
+	}
 
-	for _, reservation := range ec2result.Reservations {
-		for _, instance := range reservation.Instances {
-			*instances = append(*instances, instance)
-		}
+	if len(instances) <= 0 {
+		return nil, fmt.Errorf("ec2 with query '%s' not found", query)
 	}
 
-	return *instances, nil
+	return instances, nil
 }
 
-func getSecurityGroupsByID(ec2Instance types.Instance, ec2Svc *ec2.Client) (types.SecurityGroup, error) {
-	groupID := ec2Instance.SecurityGroups[0].GroupId
+func describeInstances(client EC2API, filters []types.Filter) ([]types.Instance, error) {
+	instances := []types.Instance{}
+	var nextToken *string
+	for {
+		ec2result, err := client.DescribeInstances(context.Background(), &ec2.DescribeInstancesInput{
+			Filters:   filters,
+			NextToken: nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error when looking for ec2 %s", err)
+		}
+
+		for _, reservation := range ec2result.Reservations {
+			instances = append(instances, reservation.Instances...)
+		}
 
-	securityGroupQuery := &ec2.DescribeSecurityGroupsInput{
-		GroupIds: []string{*groupID},
+		if ec2result.NextToken == nil {
+			return instances, nil
+		}
+		nextToken = ec2result.NextToken
 	}
-	log.Debugf("looking for security group for instance %s", *ec2Instance.InstanceId)
-	securityGroupsResult, err := ec2Svc.DescribeSecurityGroups(context.Background(), securityGroupQuery)
-	if err != nil {
-		return types.SecurityGroup{}, err
+}
+
+func getSecurityGroupsByIDs(groupIDs []string, networkInterfaceID string, cache *resourceCache) ([]types.SecurityGroup, error) {
+	if len(groupIDs) <= 0 {
+		return nil, fmt.Errorf("no security groups attached to eni:%s", networkInterfaceID)
 	}
 
-	if len(securityGroupsResult.SecurityGroups) <= 0 {
-		return types.SecurityGroup{}, fmt.Errorf("security group for ec2:%s not found", *ec2Instance.InstanceId)
+	securityGroups := []types.SecurityGroup{}
+	for _, groupID := range groupIDs {
+		securityGroup, ok := cache.securityGroups[groupID]
+		if !ok {
+			return nil, fmt.Errorf("security group %s for eni:%s not found", groupID, networkInterfaceID)
+		}
+		securityGroups = append(securityGroups, securityGroup)
 	}
 
-	log.Debugf("found %d security groups", len(securityGroupsResult.SecurityGroups))
-	return securityGroupsResult.SecurityGroups[0], nil
+	return securityGroups, nil
 }
 
-func getRouteTablesForEc2(ec2Instance types.Instance, ec2Svc *ec2.Client) (types.RouteTable, error) {
-	log.Debug("Checking subnet routing table")
-	filterSubnetID := "association.subnet-id"
-	routeTableQuery := &ec2.DescribeRouteTablesInput{
-		Filters: []types.Filter{
-			{
-				Name:   &filterSubnetID,
-				Values: []string{*ec2Instance.SubnetId},
-			},
-		},
+// getRouteTableForSubnet - returns route table explicitly associated with the subnet
+// if there is none aws uses main route table of the vpc, in that case true is returned
+func getRouteTableForSubnet(subnetID string, vpcID string, cache *resourceCache) (types.RouteTable, bool, error) {
+	if routeTable, ok := cache.subnetRouteTables[subnetID]; ok {
+		return routeTable, false, nil
+	}
+
+	log.Debugf("no route table associated with subnet %s - using main route table of vpc %s", subnetID, vpcID)
+	if routeTable, ok := cache.mainRouteTables[vpcID]; ok {
+		return routeTable, true, nil
 	}
 
-	routeTables, _ := ec2Svc.DescribeRouteTables(context.Background(), routeTableQuery)
+	return types.RouteTable{}, false, fmt.Errorf("no route table found for subnet '%s' and no main route table found for vpc '%s'", subnetID, vpcID)
+}
 
-	if len(routeTables.RouteTables) <= 0 {
-		return types.RouteTable{}, fmt.Errorf("no route table found for ec2 with ip '%s'", *ec2Instance.PrivateIpAddress)
+func getNetworkAclForSubnet(subnetID string, cache *resourceCache) (types.NetworkAcl, error) {
+	// every subnet is associated with exactly one network acl
+	networkAcl, ok := cache.subnetNetworkAcls[subnetID]
+	if !ok {
+		return types.NetworkAcl{}, fmt.Errorf("no network acl found for subnet '%s'", subnetID)
 	}
-	return routeTables.RouteTables[0], nil
+	return networkAcl, nil
 }
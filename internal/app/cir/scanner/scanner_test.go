@@ -0,0 +1,266 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	elasticachetypes "github.com/aws/aws-sdk-go-v2/service/elasticache/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// fakeClient - returns instances in pages and counts describe calls
+type fakeClient struct {
+	EC2API
+	instancePages [][]types.Reservation
+	calls         map[string]int
+}
+
+func (f *fakeClient) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	f.calls["DescribeInstances"]++
+	page := 0
+	if params.NextToken != nil {
+		fmt.Sscanf(*params.NextToken, "%d", &page)
+	}
+	output := &ec2.DescribeInstancesOutput{Reservations: f.instancePages[page]}
+	if page+1 < len(f.instancePages) {
+		output.NextToken = aws.String(fmt.Sprintf("%d", page+1))
+	}
+	return output, nil
+}
+
+func (f *fakeClient) DescribeSecurityGroups(ctx context.Context, params *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error) {
+	f.calls["DescribeSecurityGroups"]++
+	groups := []types.SecurityGroup{}
+	for _, id := range params.GroupIds {
+		groups = append(groups, types.SecurityGroup{GroupId: aws.String(id)})
+	}
+	return &ec2.DescribeSecurityGroupsOutput{SecurityGroups: groups}, nil
+}
+
+func (f *fakeClient) DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+	f.calls["DescribeRouteTables"]++
+	// subnet-1 has explicit route table, other subnets use the main one
+	if *params.Filters[0].Name == "association.subnet-id" {
+		return &ec2.DescribeRouteTablesOutput{RouteTables: []types.RouteTable{{
+			RouteTableId: aws.String("rtb-subnet"),
+			VpcId:        aws.String("vpc-1"),
+			Associations: []types.RouteTableAssociation{{SubnetId: aws.String("subnet-1")}},
+		}}}, nil
+	}
+	return &ec2.DescribeRouteTablesOutput{RouteTables: []types.RouteTable{{
+		RouteTableId: aws.String("rtb-main"),
+		VpcId:        aws.String("vpc-1"),
+		Associations: []types.RouteTableAssociation{{Main: true}},
+	}}}, nil
+}
+
+func (f *fakeClient) DescribeNetworkAcls(ctx context.Context, params *ec2.DescribeNetworkAclsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkAclsOutput, error) {
+	f.calls["DescribeNetworkAcls"]++
+	associations := []types.NetworkAclAssociation{}
+	for _, subnetID := range params.Filters[0].Values {
+		associations = append(associations, types.NetworkAclAssociation{SubnetId: aws.String(subnetID)})
+	}
+	return &ec2.DescribeNetworkAclsOutput{NetworkAcls: []types.NetworkAcl{{NetworkAclId: aws.String("acl-1"), Associations: associations}}}, nil
+}
+
+func instance(id string, subnetID string, groupID string) types.Instance {
+	return types.Instance{
+		InstanceId: aws.String(id),
+		VpcId:      aws.String("vpc-1"),
+		NetworkInterfaces: []types.InstanceNetworkInterface{{
+			NetworkInterfaceId: aws.String("eni-" + id),
+			PrivateIpAddress:   aws.String("10.0.0.1"),
+			SubnetId:           aws.String(subnetID),
+			VpcId:              aws.String("vpc-1"),
+			Groups:             []types.GroupIdentifier{{GroupId: aws.String(groupID)}},
+		}},
+	}
+}
+
+func TestScanPaginatesInstancesAndBatchesDescribeCalls(t *testing.T) {
+	client := &fakeClient{
+		instancePages: [][]types.Reservation{
+			{{Instances: []types.Instance{}}, {Instances: []types.Instance{instance("i-1", "subnet-1", "sg-1")}}},
+			{{Instances: []types.Instance{instance("i-2", "subnet-2", "sg-2"), instance("i-3", "subnet-1", "sg-1")}}},
+		},
+		calls: map[string]int{},
+	}
+
+	data, err := ScanAws(AwsClients{EC2: client}, "name:web", "name:web")
+
+	assert.Nil(t, err)
+	assert.Len(t, data.Sources, 3)
+	assert.Len(t, data.Destinations, 3)
+	assert.Equal(t, 4, client.calls["DescribeInstances"])
+	assert.Equal(t, 1, client.calls["DescribeSecurityGroups"])
+	assert.Equal(t, 2, client.calls["DescribeRouteTables"])
+	assert.Equal(t, 1, client.calls["DescribeNetworkAcls"])
+
+	assert.Equal(t, "rtb-subnet", *data.Sources[0].NetworkInterfaces[0].RouteTable.RouteTableId)
+	assert.False(t, data.Sources[0].NetworkInterfaces[0].RouteTableIsMain)
+	assert.Equal(t, "rtb-main", *data.Sources[1].NetworkInterfaces[0].RouteTable.RouteTableId)
+	assert.True(t, data.Sources[1].NetworkInterfaces[0].RouteTableIsMain)
+	assert.Equal(t, "sg-2", *data.Sources[1].NetworkInterfaces[0].SecurityGroups[0].GroupId)
+}
+
+func TestIpv6QueryAnalysesOnlyTheQueriedAddress(t *testing.T) {
+	dualStack := instance("i-1", "subnet-1", "sg-1")
+	dualStack.NetworkInterfaces[0].Ipv6Addresses = []types.InstanceIpv6Address{{Ipv6Address: aws.String("2600:1f18::5")}, {Ipv6Address: aws.String("2600:1f18::6")}}
+	client := &fakeClient{instancePages: [][]types.Reservation{{{Instances: []types.Instance{dualStack}}}}, calls: map[string]int{}}
+
+	data, err := ScanAws(AwsClients{EC2: client}, "ip:2600:1f18::6", "name:web")
+
+	assert.Nil(t, err)
+	assert.Empty(t, data.Sources[0].NetworkInterfaces[0].PrivateIP)
+	assert.Equal(t, []string{"2600:1f18::6"}, data.Sources[0].NetworkInterfaces[0].IPv6s)
+	assert.Equal(t, "10.0.0.1", data.Destinations[0].NetworkInterfaces[0].PrivateIP)
+	assert.Len(t, data.Destinations[0].NetworkInterfaces[0].IPv6s, 2)
+}
+
+func TestIpv6QueryDropsInterfacesWithoutTheQueriedAddress(t *testing.T) {
+	resources := []resource{{ID: "i-1", Interfaces: []interfaceRef{
+		{ID: "eni-1", PrivateIP: "10.0.0.1", IPv6s: []string{"2600:1f18::5"}},
+		{ID: "eni-2", PrivateIP: "10.0.0.2", IPv6s: []string{"2600:1f18::6"}},
+	}}}
+
+	filtered := onlyAddressFamilyOf(resources, "2600:1f18::6")
+
+	assert.Len(t, filtered[0].Interfaces, 1)
+	assert.Equal(t, "eni-2", filtered[0].Interfaces[0].ID)
+	assert.Empty(t, filtered[0].Interfaces[0].PrivateIP)
+	assert.Equal(t, []string{"2600:1f18::6"}, filtered[0].Interfaces[0].IPv6s)
+}
+
+func TestBatchesSplitIDs(t *testing.T) {
+	ids := make([]string, describeBatchSize+1)
+
+	assert.Len(t, batches(ids), 2)
+	assert.Len(t, batches(ids)[1], 1)
+	assert.Len(t, batches([]string{}), 0)
+}
+
+// fakeServices - rds and elasticache endpoints resolving to network interfaces of fakeClient
+type fakeServices struct {
+	*fakeClient
+	dbInstances   []rdstypes.DBInstance
+	cacheClusters []elasticachetypes.CacheCluster
+	hosts         map[string][]string
+}
+
+func (f *fakeServices) DescribeDBInstances(ctx context.Context, params *rds.DescribeDBInstancesInput, optFns ...func(*rds.Options)) (*rds.DescribeDBInstancesOutput, error) {
+	f.calls["DescribeDBInstances"]++
+	instances := []rdstypes.DBInstance{}
+	for _, instance := range f.dbInstances {
+		if params.DBInstanceIdentifier != nil && *params.DBInstanceIdentifier != *instance.DBInstanceIdentifier {
+			continue
+		}
+		if len(params.Filters) > 0 && (instance.DBClusterIdentifier == nil || *instance.DBClusterIdentifier != params.Filters[0].Values[0]) {
+			continue
+		}
+		instances = append(instances, instance)
+	}
+	return &rds.DescribeDBInstancesOutput{DBInstances: instances}, nil
+}
+
+func (f *fakeServices) DescribeDBClusters(ctx context.Context, params *rds.DescribeDBClustersInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClustersOutput, error) {
+	return &rds.DescribeDBClustersOutput{}, nil
+}
+
+func (f *fakeServices) DescribeCacheClusters(ctx context.Context, params *elasticache.DescribeCacheClustersInput, optFns ...func(*elasticache.Options)) (*elasticache.DescribeCacheClustersOutput, error) {
+	return &elasticache.DescribeCacheClustersOutput{CacheClusters: f.cacheClusters}, nil
+}
+
+func (f *fakeServices) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return f.hosts[host], nil
+}
+
+func (f *fakeServices) DescribeNetworkInterfaces(ctx context.Context, params *ec2.DescribeNetworkInterfacesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	if *params.Filters[0].Name != "addresses.private-ip-address" {
+		return &ec2.DescribeNetworkInterfacesOutput{}, nil
+	}
+	ip := params.Filters[0].Values[0]
+	return &ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []types.NetworkInterface{{
+		NetworkInterfaceId: aws.String("eni-" + ip),
+		PrivateIpAddress:   aws.String(ip),
+		PrivateIpAddresses: []types.NetworkInterfacePrivateIpAddress{{PrivateIpAddress: aws.String(ip)}},
+		SubnetId:           aws.String("subnet-2"),
+		VpcId:              aws.String("vpc-1"),
+		Groups:             []types.GroupIdentifier{{GroupId: aws.String("sg-db")}},
+	}}}, nil
+}
+
+func (f *fakeServices) clients() AwsClients {
+	return AwsClients{EC2: f, RDS: f, ElastiCache: f, Resolver: f}
+}
+
+func newFakeServices() *fakeServices {
+	return &fakeServices{
+		fakeClient: &fakeClient{
+			instancePages: [][]types.Reservation{{{Instances: []types.Instance{instance("i-1", "subnet-1", "sg-1")}}}},
+			calls:         map[string]int{},
+		},
+		dbInstances: []rdstypes.DBInstance{
+			{DBInstanceIdentifier: aws.String("orders-1"), DBClusterIdentifier: aws.String("orders"), Endpoint: &rdstypes.Endpoint{Address: aws.String("orders-1.rds")}},
+			{DBInstanceIdentifier: aws.String("orders-2"), DBClusterIdentifier: aws.String("orders"), Endpoint: &rdstypes.Endpoint{Address: aws.String("orders-2.rds")}},
+			{DBInstanceIdentifier: aws.String("creating")},
+		},
+		cacheClusters: []elasticachetypes.CacheCluster{{
+			CacheClusterId:     aws.String("sessions-001"),
+			ReplicationGroupId: aws.String("sessions"),
+			CacheNodes:         []elasticachetypes.CacheNode{{CacheNodeId: aws.String("0001"), Endpoint: &elasticachetypes.Endpoint{Address: aws.String("sessions.cache")}}},
+		}},
+		hosts: map[string][]string{
+			"orders-1.rds":   {"10.0.1.10"},
+			"orders-2.rds":   {"10.0.1.11"},
+			"sessions.cache": {"10.0.1.20", "fd00::1"},
+		},
+	}
+}
+
+func TestScanResolvesRDSEndpointToNetworkInterface(t *testing.T) {
+	services := newFakeServices()
+
+	data, err := ScanAws(services.clients(), "name:web", "rds:orders-1")
+
+	assert.Nil(t, err)
+	assert.Len(t, data.Destinations, 1)
+	assert.Equal(t, "orders-1", data.Destinations[0].ID)
+	assert.Equal(t, "10.0.1.10", data.Destinations[0].NetworkInterfaces[0].PrivateIP)
+	assert.Equal(t, "sg-db", *data.Destinations[0].NetworkInterfaces[0].SecurityGroups[0].GroupId)
+	assert.Equal(t, "rtb-main", *data.Destinations[0].NetworkInterfaces[0].RouteTable.RouteTableId)
+}
+
+func TestScanResolvesEveryInstanceOfRDSCluster(t *testing.T) {
+	services := newFakeServices()
+
+	data, err := ScanAws(services.clients(), "name:web", "rds-cluster:orders")
+
+	assert.Nil(t, err)
+	assert.Len(t, data.Destinations, 2)
+	assert.Equal(t, "orders-2", data.Destinations[1].ID)
+}
+
+func TestScanResolvesElastiCacheNodesByReplicationGroup(t *testing.T) {
+	services := newFakeServices()
+
+	data, err := ScanAws(services.clients(), "name:web", "elasticache:sessions")
+
+	assert.Nil(t, err)
+	assert.Len(t, data.Destinations, 1)
+	assert.Equal(t, "sessions-001/0001", data.Destinations[0].ID)
+	assert.Len(t, data.Destinations[0].NetworkInterfaces, 1)
+}
+
+func TestScanFailsWhenRDSHasNoEndpoint(t *testing.T) {
+	services := newFakeServices()
+
+	_, err := ScanAws(services.clients(), "name:web", "rds:creating")
+
+	assert.EqualError(t, err, "rds with query 'creating' not found")
+}
@@ -0,0 +1,307 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"sync"
+	"time"
+)
+
+// Recorder - wraps aws clients and records every call so it can be replayed later
+type Recorder struct {
+	clients  scanner.AwsClients
+	mutex    sync.Mutex
+	snapshot Snapshot
+	// calls already recorded, replayer uses the first response of the same call anyway
+	recorded map[string]bool
+}
+
+var _ scanner.EC2API = &Recorder{}
+var _ scanner.RDSAPI = &Recorder{}
+var _ scanner.ElastiCacheAPI = &Recorder{}
+var _ scanner.ELBV2API = &Recorder{}
+var _ scanner.ELBAPI = &classicLoadBalancerRecorder{}
+var _ scanner.ECSAPI = &Recorder{}
+var _ scanner.LambdaAPI = &Recorder{}
+var _ scanner.Resolver = &Recorder{}
+
+// NewRecorder - creates recorder for the scan of given source and destination queries
+func NewRecorder(clients scanner.AwsClients, sourceQuery string, destinationQuery string) *Recorder {
+	return &Recorder{
+		clients: clients,
+		snapshot: Snapshot{
+			Version:          Version,
+			CreatedAt:        time.Now().UTC(),
+			SourceQuery:      sourceQuery,
+			DestinationQuery: destinationQuery,
+			Responses:        []Response{},
+		},
+		recorded: map[string]bool{},
+	}
+}
+
+// Clients - recorder used in place of every client
+func (r *Recorder) Clients() scanner.AwsClients {
+	return scanner.AwsClients{EC2: r, RDS: r, ElastiCache: r, ELBV2: r, ELB: &classicLoadBalancerRecorder{r}, ECS: r, Lambda: r, Resolver: r}
+}
+
+// Snapshot - returns all calls recorded so far
+func (r *Recorder) Snapshot() *Snapshot {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	s := r.snapshot
+	s.Responses = append([]Response{}, r.snapshot.Responses...)
+	return &s
+}
+
+// record - stores the call, failure to serialize is not fatal for the scan so the response is skipped
+func (r *Recorder) record(operation string, input interface{}, output interface{}, callErr error) {
+	inputJSON, err := json.Marshal(input)
+	if err != nil {
+		return
+	}
+
+	response := Response{Operation: operation, Input: inputJSON}
+	if callErr != nil {
+		response.Error = callErr.Error()
+	} else {
+		outputJSON, err := json.Marshal(output)
+		if err != nil {
+			return
+		}
+		response.Output = outputJSON
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	key := responseKey(operation, inputJSON)
+	if r.recorded[key] {
+		return
+	}
+	r.recorded[key] = true
+	r.snapshot.Responses = append(r.snapshot.Responses, response)
+}
+
+// DescribeInstances - records ec2 DescribeInstances
+func (r *Recorder) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	output, err := r.clients.EC2.DescribeInstances(ctx, params, optFns...)
+	r.record("DescribeInstances", params, output, err)
+	return output, err
+}
+
+// DescribeNetworkInterfaces - records ec2 DescribeNetworkInterfaces
+func (r *Recorder) DescribeNetworkInterfaces(ctx context.Context, params *ec2.DescribeNetworkInterfacesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	output, err := r.clients.EC2.DescribeNetworkInterfaces(ctx, params, optFns...)
+	r.record("DescribeNetworkInterfaces", params, output, err)
+	return output, err
+}
+
+// DescribeSecurityGroups - records ec2 DescribeSecurityGroups
+func (r *Recorder) DescribeSecurityGroups(ctx context.Context, params *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error) {
+	output, err := r.clients.EC2.DescribeSecurityGroups(ctx, params, optFns...)
+	r.record("DescribeSecurityGroups", params, output, err)
+	return output, err
+}
+
+// DescribeRouteTables - records ec2 DescribeRouteTables
+func (r *Recorder) DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+	output, err := r.clients.EC2.DescribeRouteTables(ctx, params, optFns...)
+	r.record("DescribeRouteTables", params, output, err)
+	return output, err
+}
+
+// DescribeNetworkAcls - records ec2 DescribeNetworkAcls
+func (r *Recorder) DescribeNetworkAcls(ctx context.Context, params *ec2.DescribeNetworkAclsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkAclsOutput, error) {
+	output, err := r.clients.EC2.DescribeNetworkAcls(ctx, params, optFns...)
+	r.record("DescribeNetworkAcls", params, output, err)
+	return output, err
+}
+
+// DescribeSubnets - records ec2 DescribeSubnets
+func (r *Recorder) DescribeSubnets(ctx context.Context, params *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error) {
+	output, err := r.clients.EC2.DescribeSubnets(ctx, params, optFns...)
+	r.record("DescribeSubnets", params, output, err)
+	return output, err
+}
+
+// DescribeVpcPeeringConnections - records ec2 DescribeVpcPeeringConnections
+func (r *Recorder) DescribeVpcPeeringConnections(ctx context.Context, params *ec2.DescribeVpcPeeringConnectionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcPeeringConnectionsOutput, error) {
+	output, err := r.clients.EC2.DescribeVpcPeeringConnections(ctx, params, optFns...)
+	r.record("DescribeVpcPeeringConnections", params, output, err)
+	return output, err
+}
+
+// DescribeTransitGateways - records ec2 DescribeTransitGateways
+func (r *Recorder) DescribeTransitGateways(ctx context.Context, params *ec2.DescribeTransitGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTransitGatewaysOutput, error) {
+	output, err := r.clients.EC2.DescribeTransitGateways(ctx, params, optFns...)
+	r.record("DescribeTransitGateways", params, output, err)
+	return output, err
+}
+
+// DescribeTransitGatewayAttachments - records ec2 DescribeTransitGatewayAttachments
+func (r *Recorder) DescribeTransitGatewayAttachments(ctx context.Context, params *ec2.DescribeTransitGatewayAttachmentsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTransitGatewayAttachmentsOutput, error) {
+	output, err := r.clients.EC2.DescribeTransitGatewayAttachments(ctx, params, optFns...)
+	r.record("DescribeTransitGatewayAttachments", params, output, err)
+	return output, err
+}
+
+// DescribeTransitGatewayVpcAttachments - records ec2 DescribeTransitGatewayVpcAttachments
+func (r *Recorder) DescribeTransitGatewayVpcAttachments(ctx context.Context, params *ec2.DescribeTransitGatewayVpcAttachmentsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTransitGatewayVpcAttachmentsOutput, error) {
+	output, err := r.clients.EC2.DescribeTransitGatewayVpcAttachments(ctx, params, optFns...)
+	r.record("DescribeTransitGatewayVpcAttachments", params, output, err)
+	return output, err
+}
+
+// DescribeNatGateways - records ec2 DescribeNatGateways
+func (r *Recorder) DescribeNatGateways(ctx context.Context, params *ec2.DescribeNatGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNatGatewaysOutput, error) {
+	output, err := r.clients.EC2.DescribeNatGateways(ctx, params, optFns...)
+	r.record("DescribeNatGateways", params, output, err)
+	return output, err
+}
+
+// DescribeInternetGateways - records ec2 DescribeInternetGateways
+func (r *Recorder) DescribeInternetGateways(ctx context.Context, params *ec2.DescribeInternetGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInternetGatewaysOutput, error) {
+	output, err := r.clients.EC2.DescribeInternetGateways(ctx, params, optFns...)
+	r.record("DescribeInternetGateways", params, output, err)
+	return output, err
+}
+
+// DescribeEgressOnlyInternetGateways - records ec2 DescribeEgressOnlyInternetGateways
+func (r *Recorder) DescribeEgressOnlyInternetGateways(ctx context.Context, params *ec2.DescribeEgressOnlyInternetGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeEgressOnlyInternetGatewaysOutput, error) {
+	output, err := r.clients.EC2.DescribeEgressOnlyInternetGateways(ctx, params, optFns...)
+	r.record("DescribeEgressOnlyInternetGateways", params, output, err)
+	return output, err
+}
+
+// GetManagedPrefixListEntries - records ec2 GetManagedPrefixListEntries
+func (r *Recorder) GetManagedPrefixListEntries(ctx context.Context, params *ec2.GetManagedPrefixListEntriesInput, optFns ...func(*ec2.Options)) (*ec2.GetManagedPrefixListEntriesOutput, error) {
+	output, err := r.clients.EC2.GetManagedPrefixListEntries(ctx, params, optFns...)
+	r.record("GetManagedPrefixListEntries", params, output, err)
+	return output, err
+}
+
+// SearchTransitGatewayRoutes - records ec2 SearchTransitGatewayRoutes
+func (r *Recorder) SearchTransitGatewayRoutes(ctx context.Context, params *ec2.SearchTransitGatewayRoutesInput, optFns ...func(*ec2.Options)) (*ec2.SearchTransitGatewayRoutesOutput, error) {
+	output, err := r.clients.EC2.SearchTransitGatewayRoutes(ctx, params, optFns...)
+	r.record("SearchTransitGatewayRoutes", params, output, err)
+	return output, err
+}
+
+// DescribeDBInstances - records rds DescribeDBInstances
+func (r *Recorder) DescribeDBInstances(ctx context.Context, params *rds.DescribeDBInstancesInput, optFns ...func(*rds.Options)) (*rds.DescribeDBInstancesOutput, error) {
+	output, err := r.clients.RDS.DescribeDBInstances(ctx, params, optFns...)
+	r.record("DescribeDBInstances", params, output, err)
+	return output, err
+}
+
+// DescribeDBClusters - records rds DescribeDBClusters
+func (r *Recorder) DescribeDBClusters(ctx context.Context, params *rds.DescribeDBClustersInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClustersOutput, error) {
+	output, err := r.clients.RDS.DescribeDBClusters(ctx, params, optFns...)
+	r.record("DescribeDBClusters", params, output, err)
+	return output, err
+}
+
+// DescribeCacheClusters - records elasticache DescribeCacheClusters
+func (r *Recorder) DescribeCacheClusters(ctx context.Context, params *elasticache.DescribeCacheClustersInput, optFns ...func(*elasticache.Options)) (*elasticache.DescribeCacheClustersOutput, error) {
+	output, err := r.clients.ElastiCache.DescribeCacheClusters(ctx, params, optFns...)
+	r.record("DescribeCacheClusters", params, output, err)
+	return output, err
+}
+
+// DescribeLoadBalancers - records elbv2 DescribeLoadBalancers
+func (r *Recorder) DescribeLoadBalancers(ctx context.Context, params *elasticloadbalancingv2.DescribeLoadBalancersInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeLoadBalancersOutput, error) {
+	output, err := r.clients.ELBV2.DescribeLoadBalancers(ctx, params, optFns...)
+	r.record("DescribeLoadBalancers", params, output, err)
+	return output, err
+}
+
+// DescribeListeners - records elbv2 DescribeListeners
+func (r *Recorder) DescribeListeners(ctx context.Context, params *elasticloadbalancingv2.DescribeListenersInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeListenersOutput, error) {
+	output, err := r.clients.ELBV2.DescribeListeners(ctx, params, optFns...)
+	r.record("DescribeListeners", params, output, err)
+	return output, err
+}
+
+// DescribeTargetGroups - records elbv2 DescribeTargetGroups
+func (r *Recorder) DescribeTargetGroups(ctx context.Context, params *elasticloadbalancingv2.DescribeTargetGroupsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeTargetGroupsOutput, error) {
+	output, err := r.clients.ELBV2.DescribeTargetGroups(ctx, params, optFns...)
+	r.record("DescribeTargetGroups", params, output, err)
+	return output, err
+}
+
+// DescribeTargetGroupAttributes - records elbv2 DescribeTargetGroupAttributes
+func (r *Recorder) DescribeTargetGroupAttributes(ctx context.Context, params *elasticloadbalancingv2.DescribeTargetGroupAttributesInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeTargetGroupAttributesOutput, error) {
+	output, err := r.clients.ELBV2.DescribeTargetGroupAttributes(ctx, params, optFns...)
+	r.record("DescribeTargetGroupAttributes", params, output, err)
+	return output, err
+}
+
+// DescribeTargetHealth - records elbv2 DescribeTargetHealth
+func (r *Recorder) DescribeTargetHealth(ctx context.Context, params *elasticloadbalancingv2.DescribeTargetHealthInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeTargetHealthOutput, error) {
+	output, err := r.clients.ELBV2.DescribeTargetHealth(ctx, params, optFns...)
+	r.record("DescribeTargetHealth", params, output, err)
+	return output, err
+}
+
+// ListServices - records ecs ListServices
+func (r *Recorder) ListServices(ctx context.Context, params *ecs.ListServicesInput, optFns ...func(*ecs.Options)) (*ecs.ListServicesOutput, error) {
+	output, err := r.clients.ECS.ListServices(ctx, params, optFns...)
+	r.record("ListServices", params, output, err)
+	return output, err
+}
+
+// ListTasks - records ecs ListTasks
+func (r *Recorder) ListTasks(ctx context.Context, params *ecs.ListTasksInput, optFns ...func(*ecs.Options)) (*ecs.ListTasksOutput, error) {
+	output, err := r.clients.ECS.ListTasks(ctx, params, optFns...)
+	r.record("ListTasks", params, output, err)
+	return output, err
+}
+
+// DescribeTasks - records ecs DescribeTasks
+func (r *Recorder) DescribeTasks(ctx context.Context, params *ecs.DescribeTasksInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTasksOutput, error) {
+	output, err := r.clients.ECS.DescribeTasks(ctx, params, optFns...)
+	r.record("DescribeTasks", params, output, err)
+	return output, err
+}
+
+// GetFunctionConfiguration - records lambda GetFunctionConfiguration
+func (r *Recorder) GetFunctionConfiguration(ctx context.Context, params *lambda.GetFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionConfigurationOutput, error) {
+	output, err := r.clients.Lambda.GetFunctionConfiguration(ctx, params, optFns...)
+	r.record("GetFunctionConfiguration", params, output, err)
+	return output, err
+}
+
+// ListFunctions - records lambda ListFunctions
+func (r *Recorder) ListFunctions(ctx context.Context, params *lambda.ListFunctionsInput, optFns ...func(*lambda.Options)) (*lambda.ListFunctionsOutput, error) {
+	output, err := r.clients.Lambda.ListFunctions(ctx, params, optFns...)
+	r.record("ListFunctions", params, output, err)
+	return output, err
+}
+
+// classicLoadBalancerRecorder - classic load balancer api has the same method names as elbv2 so it needs separate type
+type classicLoadBalancerRecorder struct {
+	recorder *Recorder
+}
+
+// DescribeLoadBalancers - records elb DescribeLoadBalancers
+func (r *classicLoadBalancerRecorder) DescribeLoadBalancers(ctx context.Context, params *elasticloadbalancing.DescribeLoadBalancersInput, optFns ...func(*elasticloadbalancing.Options)) (*elasticloadbalancing.DescribeLoadBalancersOutput, error) {
+	output, err := r.recorder.clients.ELB.DescribeLoadBalancers(ctx, params, optFns...)
+	r.recorder.record("DescribeClassicLoadBalancers", params, output, err)
+	return output, err
+}
+
+// LookupHost - records dns resolution of endpoints so replay doesnt depend on dns
+func (r *Recorder) LookupHost(ctx context.Context, host string) ([]string, error) {
+	output, err := r.clients.Resolver.LookupHost(ctx, host)
+	r.record("LookupHost", host, output, err)
+	return output, err
+}
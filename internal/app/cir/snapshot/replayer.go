@@ -0,0 +1,345 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+)
+
+// Replayer - answers ec2 calls with responses recorded in the snapshot, no aws access needed
+type Replayer struct {
+	responses map[string]Response
+}
+
+var _ scanner.EC2API = &Replayer{}
+var _ scanner.RDSAPI = &Replayer{}
+var _ scanner.ElastiCacheAPI = &Replayer{}
+var _ scanner.ELBV2API = &Replayer{}
+var _ scanner.ELBAPI = &classicLoadBalancerReplayer{}
+var _ scanner.ECSAPI = &Replayer{}
+var _ scanner.LambdaAPI = &Replayer{}
+var _ scanner.Resolver = &Replayer{}
+
+// NewReplayer - creates replayer, if the same call was recorded more than once the first response is used
+func NewReplayer(s *Snapshot) *Replayer {
+	responses := map[string]Response{}
+	for _, response := range s.Responses {
+		key := responseKey(response.Operation, response.Input)
+		if _, ok := responses[key]; !ok {
+			responses[key] = response
+		}
+	}
+	return &Replayer{responses}
+}
+
+// Clients - replayer used in place of every client
+func (r *Replayer) Clients() scanner.AwsClients {
+	return scanner.AwsClients{EC2: r, RDS: r, ElastiCache: r, ELBV2: r, ELB: &classicLoadBalancerReplayer{r}, ECS: r, Lambda: r, Resolver: r}
+}
+
+// replay - finds the response for the call and decodes it into output
+// calls missing in the snapshot are returned as errors so the analysis reports them as unknown
+func (r *Replayer) replay(operation string, input interface{}, output interface{}) error {
+	inputJSON, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("error when serializing %s input - %s", operation, err)
+	}
+
+	response, ok := r.responses[responseKey(operation, inputJSON)]
+	if !ok {
+		return fmt.Errorf("%s call with input %s not found in the snapshot", operation, inputJSON)
+	}
+
+	if response.Error != "" {
+		return errors.New(response.Error)
+	}
+
+	if err := json.Unmarshal(response.Output, output); err != nil {
+		return fmt.Errorf("error when reading %s response from the snapshot - %s", operation, err)
+	}
+	return nil
+}
+
+// DescribeInstances - replays ec2 DescribeInstances
+func (r *Replayer) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	output := &ec2.DescribeInstancesOutput{}
+	if err := r.replay("DescribeInstances", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeNetworkInterfaces - replays ec2 DescribeNetworkInterfaces
+func (r *Replayer) DescribeNetworkInterfaces(ctx context.Context, params *ec2.DescribeNetworkInterfacesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	output := &ec2.DescribeNetworkInterfacesOutput{}
+	if err := r.replay("DescribeNetworkInterfaces", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeSecurityGroups - replays ec2 DescribeSecurityGroups
+func (r *Replayer) DescribeSecurityGroups(ctx context.Context, params *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error) {
+	output := &ec2.DescribeSecurityGroupsOutput{}
+	if err := r.replay("DescribeSecurityGroups", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeRouteTables - replays ec2 DescribeRouteTables
+func (r *Replayer) DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+	output := &ec2.DescribeRouteTablesOutput{}
+	if err := r.replay("DescribeRouteTables", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeNetworkAcls - replays ec2 DescribeNetworkAcls
+func (r *Replayer) DescribeNetworkAcls(ctx context.Context, params *ec2.DescribeNetworkAclsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkAclsOutput, error) {
+	output := &ec2.DescribeNetworkAclsOutput{}
+	if err := r.replay("DescribeNetworkAcls", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeSubnets - replays ec2 DescribeSubnets
+func (r *Replayer) DescribeSubnets(ctx context.Context, params *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error) {
+	output := &ec2.DescribeSubnetsOutput{}
+	if err := r.replay("DescribeSubnets", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeVpcPeeringConnections - replays ec2 DescribeVpcPeeringConnections
+func (r *Replayer) DescribeVpcPeeringConnections(ctx context.Context, params *ec2.DescribeVpcPeeringConnectionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcPeeringConnectionsOutput, error) {
+	output := &ec2.DescribeVpcPeeringConnectionsOutput{}
+	if err := r.replay("DescribeVpcPeeringConnections", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeTransitGateways - replays ec2 DescribeTransitGateways
+func (r *Replayer) DescribeTransitGateways(ctx context.Context, params *ec2.DescribeTransitGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTransitGatewaysOutput, error) {
+	output := &ec2.DescribeTransitGatewaysOutput{}
+	if err := r.replay("DescribeTransitGateways", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeTransitGatewayAttachments - replays ec2 DescribeTransitGatewayAttachments
+func (r *Replayer) DescribeTransitGatewayAttachments(ctx context.Context, params *ec2.DescribeTransitGatewayAttachmentsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTransitGatewayAttachmentsOutput, error) {
+	output := &ec2.DescribeTransitGatewayAttachmentsOutput{}
+	if err := r.replay("DescribeTransitGatewayAttachments", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeTransitGatewayVpcAttachments - replays ec2 DescribeTransitGatewayVpcAttachments
+func (r *Replayer) DescribeTransitGatewayVpcAttachments(ctx context.Context, params *ec2.DescribeTransitGatewayVpcAttachmentsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTransitGatewayVpcAttachmentsOutput, error) {
+	output := &ec2.DescribeTransitGatewayVpcAttachmentsOutput{}
+	if err := r.replay("DescribeTransitGatewayVpcAttachments", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeNatGateways - replays ec2 DescribeNatGateways
+func (r *Replayer) DescribeNatGateways(ctx context.Context, params *ec2.DescribeNatGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNatGatewaysOutput, error) {
+	output := &ec2.DescribeNatGatewaysOutput{}
+	if err := r.replay("DescribeNatGateways", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeInternetGateways - replays ec2 DescribeInternetGateways
+func (r *Replayer) DescribeInternetGateways(ctx context.Context, params *ec2.DescribeInternetGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInternetGatewaysOutput, error) {
+	output := &ec2.DescribeInternetGatewaysOutput{}
+	if err := r.replay("DescribeInternetGateways", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeEgressOnlyInternetGateways - replays ec2 DescribeEgressOnlyInternetGateways
+func (r *Replayer) DescribeEgressOnlyInternetGateways(ctx context.Context, params *ec2.DescribeEgressOnlyInternetGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeEgressOnlyInternetGatewaysOutput, error) {
+	output := &ec2.DescribeEgressOnlyInternetGatewaysOutput{}
+	if err := r.replay("DescribeEgressOnlyInternetGateways", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// GetManagedPrefixListEntries - replays ec2 GetManagedPrefixListEntries
+func (r *Replayer) GetManagedPrefixListEntries(ctx context.Context, params *ec2.GetManagedPrefixListEntriesInput, optFns ...func(*ec2.Options)) (*ec2.GetManagedPrefixListEntriesOutput, error) {
+	output := &ec2.GetManagedPrefixListEntriesOutput{}
+	if err := r.replay("GetManagedPrefixListEntries", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// SearchTransitGatewayRoutes - replays ec2 SearchTransitGatewayRoutes
+func (r *Replayer) SearchTransitGatewayRoutes(ctx context.Context, params *ec2.SearchTransitGatewayRoutesInput, optFns ...func(*ec2.Options)) (*ec2.SearchTransitGatewayRoutesOutput, error) {
+	output := &ec2.SearchTransitGatewayRoutesOutput{}
+	if err := r.replay("SearchTransitGatewayRoutes", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeDBInstances - replays rds DescribeDBInstances
+func (r *Replayer) DescribeDBInstances(ctx context.Context, params *rds.DescribeDBInstancesInput, optFns ...func(*rds.Options)) (*rds.DescribeDBInstancesOutput, error) {
+	output := &rds.DescribeDBInstancesOutput{}
+	if err := r.replay("DescribeDBInstances", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeDBClusters - replays rds DescribeDBClusters
+func (r *Replayer) DescribeDBClusters(ctx context.Context, params *rds.DescribeDBClustersInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClustersOutput, error) {
+	output := &rds.DescribeDBClustersOutput{}
+	if err := r.replay("DescribeDBClusters", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeCacheClusters - replays elasticache DescribeCacheClusters
+func (r *Replayer) DescribeCacheClusters(ctx context.Context, params *elasticache.DescribeCacheClustersInput, optFns ...func(*elasticache.Options)) (*elasticache.DescribeCacheClustersOutput, error) {
+	output := &elasticache.DescribeCacheClustersOutput{}
+	if err := r.replay("DescribeCacheClusters", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeLoadBalancers - replays elbv2 DescribeLoadBalancers
+func (r *Replayer) DescribeLoadBalancers(ctx context.Context, params *elasticloadbalancingv2.DescribeLoadBalancersInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeLoadBalancersOutput, error) {
+	output := &elasticloadbalancingv2.DescribeLoadBalancersOutput{}
+	if err := r.replay("DescribeLoadBalancers", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeListeners - replays elbv2 DescribeListeners
+func (r *Replayer) DescribeListeners(ctx context.Context, params *elasticloadbalancingv2.DescribeListenersInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeListenersOutput, error) {
+	output := &elasticloadbalancingv2.DescribeListenersOutput{}
+	if err := r.replay("DescribeListeners", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeTargetGroups - replays elbv2 DescribeTargetGroups
+func (r *Replayer) DescribeTargetGroups(ctx context.Context, params *elasticloadbalancingv2.DescribeTargetGroupsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeTargetGroupsOutput, error) {
+	output := &elasticloadbalancingv2.DescribeTargetGroupsOutput{}
+	if err := r.replay("DescribeTargetGroups", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeTargetGroupAttributes - replays elbv2 DescribeTargetGroupAttributes
+func (r *Replayer) DescribeTargetGroupAttributes(ctx context.Context, params *elasticloadbalancingv2.DescribeTargetGroupAttributesInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeTargetGroupAttributesOutput, error) {
+	output := &elasticloadbalancingv2.DescribeTargetGroupAttributesOutput{}
+	if err := r.replay("DescribeTargetGroupAttributes", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeTargetHealth - replays elbv2 DescribeTargetHealth
+func (r *Replayer) DescribeTargetHealth(ctx context.Context, params *elasticloadbalancingv2.DescribeTargetHealthInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeTargetHealthOutput, error) {
+	output := &elasticloadbalancingv2.DescribeTargetHealthOutput{}
+	if err := r.replay("DescribeTargetHealth", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// ListServices - replays ecs ListServices
+func (r *Replayer) ListServices(ctx context.Context, params *ecs.ListServicesInput, optFns ...func(*ecs.Options)) (*ecs.ListServicesOutput, error) {
+	output := &ecs.ListServicesOutput{}
+	if err := r.replay("ListServices", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// ListTasks - replays ecs ListTasks
+func (r *Replayer) ListTasks(ctx context.Context, params *ecs.ListTasksInput, optFns ...func(*ecs.Options)) (*ecs.ListTasksOutput, error) {
+	output := &ecs.ListTasksOutput{}
+	if err := r.replay("ListTasks", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeTasks - replays ecs DescribeTasks
+func (r *Replayer) DescribeTasks(ctx context.Context, params *ecs.DescribeTasksInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTasksOutput, error) {
+	output := &ecs.DescribeTasksOutput{}
+	if err := r.replay("DescribeTasks", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// GetFunctionConfiguration - replays lambda GetFunctionConfiguration
+func (r *Replayer) GetFunctionConfiguration(ctx context.Context, params *lambda.GetFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionConfigurationOutput, error) {
+	output := &lambda.GetFunctionConfigurationOutput{}
+	if err := r.replay("GetFunctionConfiguration", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// ListFunctions - replays lambda ListFunctions
+func (r *Replayer) ListFunctions(ctx context.Context, params *lambda.ListFunctionsInput, optFns ...func(*lambda.Options)) (*lambda.ListFunctionsOutput, error) {
+	output := &lambda.ListFunctionsOutput{}
+	if err := r.replay("ListFunctions", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// classicLoadBalancerReplayer - classic load balancer api has the same method names as elbv2 so it needs separate type
+type classicLoadBalancerReplayer struct {
+	replayer *Replayer
+}
+
+// DescribeLoadBalancers - replays elb DescribeLoadBalancers
+func (r *classicLoadBalancerReplayer) DescribeLoadBalancers(ctx context.Context, params *elasticloadbalancing.DescribeLoadBalancersInput, optFns ...func(*elasticloadbalancing.Options)) (*elasticloadbalancing.DescribeLoadBalancersOutput, error) {
+	output := &elasticloadbalancing.DescribeLoadBalancersOutput{}
+	if err := r.replayer.replay("DescribeClassicLoadBalancers", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// LookupHost - replays dns resolution of endpoint
+func (r *Replayer) LookupHost(ctx context.Context, host string) ([]string, error) {
+	output := []string{}
+	if err := r.replay("LookupHost", host, &output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
@@ -0,0 +1,70 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// Version - version of the snapshot file format, bumped on breaking changes
+const Version = 1
+
+// Response - single recorded aws api call
+type Response struct {
+	Operation string          `json:"operation"`
+	Input     json.RawMessage `json:"input"`
+	Output    json.RawMessage `json:"output,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// Snapshot - raw aws responses needed to replay the analysis offline
+type Snapshot struct {
+	Version          int        `json:"version"`
+	CreatedAt        time.Time  `json:"created_at"`
+	SourceQuery      string     `json:"source_query"`
+	DestinationQuery string     `json:"destination_query"`
+	Responses        []Response `json:"responses"`
+}
+
+// Save - writes snapshot as json file
+func (s *Snapshot) Save(path string) error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error when serializing snapshot - %s", err)
+	}
+
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("error when writing snapshot to '%s' - %s", path, err)
+	}
+	return nil
+}
+
+// Load - reads snapshot from json file
+func Load(path string) (*Snapshot, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading snapshot '%s' - %s", path, err)
+	}
+
+	s := &Snapshot{}
+	if err := json.Unmarshal(content, s); err != nil {
+		return nil, fmt.Errorf("snapshot '%s' is not valid json - %s", path, err)
+	}
+
+	if s.Version != Version {
+		return nil, fmt.Errorf("snapshot '%s' has version %d, only version %d is supported", path, s.Version, Version)
+	}
+	return s, nil
+}
+
+// responseKey - responses are matched by operation and exact input so paginated calls replay correctly
+// input is compacted as the file is indented when saved
+func responseKey(operation string, input json.RawMessage) string {
+	compacted := &bytes.Buffer{}
+	if err := json.Compact(compacted, input); err != nil {
+		return operation + "|" + string(input)
+	}
+	return operation + "|" + compacted.String()
+}
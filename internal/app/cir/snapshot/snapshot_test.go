@@ -0,0 +1,87 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeClient - only the calls used in tests are implemented, others panic on nil interface
+type fakeClient struct {
+	scanner.EC2API
+	routeTables []types.RouteTable
+}
+
+func (f *fakeClient) DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+	if *params.Filters[0].Name == "vpc-id" {
+		return nil, errors.New("access denied")
+	}
+	return &ec2.DescribeRouteTablesOutput{RouteTables: f.routeTables}, nil
+}
+
+func routeTableQuery(filterName string) *ec2.DescribeRouteTablesInput {
+	return &ec2.DescribeRouteTablesInput{Filters: []types.Filter{{Name: aws.String(filterName), Values: []string{"subnet-1"}}}}
+}
+
+func TestRecordedResponsesAreReplayedFromFile(t *testing.T) {
+	client := &fakeClient{routeTables: []types.RouteTable{{
+		RouteTableId: aws.String("rtb-1"),
+		Routes:       []types.Route{{DestinationCidrBlock: aws.String("10.0.0.0/16"), GatewayId: aws.String("local"), State: types.RouteStateActive}},
+	}}}
+	recorder := NewRecorder(scanner.AwsClients{EC2: client}, "name:source", "name:destination")
+
+	_, err := recorder.DescribeRouteTables(context.Background(), routeTableQuery("association.subnet-id"))
+	assert.Nil(t, err)
+	_, err = recorder.DescribeRouteTables(context.Background(), routeTableQuery("vpc-id"))
+	assert.NotNil(t, err)
+
+	dir, err := ioutil.TempDir("", "cir-snapshot")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot.json")
+	assert.Nil(t, recorder.Snapshot().Save(path))
+
+	loaded, err := Load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "name:source", loaded.SourceQuery)
+	assert.Equal(t, "name:destination", loaded.DestinationQuery)
+
+	replayer := NewReplayer(loaded)
+	output, err := replayer.DescribeRouteTables(context.Background(), routeTableQuery("association.subnet-id"))
+	assert.Nil(t, err)
+	assert.Equal(t, "rtb-1", *output.RouteTables[0].RouteTableId)
+	assert.Equal(t, "local", *output.RouteTables[0].Routes[0].GatewayId)
+	assert.Equal(t, types.RouteStateActive, output.RouteTables[0].Routes[0].State)
+
+	_, err = replayer.DescribeRouteTables(context.Background(), routeTableQuery("vpc-id"))
+	assert.EqualError(t, err, "access denied")
+}
+
+func TestReplayerReturnsErrorForCallMissingInSnapshot(t *testing.T) {
+	replayer := NewReplayer(&Snapshot{Version: Version})
+
+	_, err := replayer.DescribeSubnets(context.Background(), &ec2.DescribeSubnetsInput{SubnetIds: []string{"subnet-1"}})
+
+	assert.NotNil(t, err)
+}
+
+func TestRepeatedCallIsRecordedOnce(t *testing.T) {
+	recorder := NewRecorder(scanner.AwsClients{EC2: &fakeClient{}}, "name:source", "name:destination")
+
+	for i := 0; i < 3; i++ {
+		_, err := recorder.DescribeRouteTables(context.Background(), routeTableQuery("association.subnet-id"))
+		assert.Nil(t, err)
+	}
+	_, err := recorder.DescribeRouteTables(context.Background(), routeTableQuery("vpc-id"))
+	assert.NotNil(t, err)
+
+	assert.Len(t, recorder.Snapshot().Responses, 2)
+}
@@ -0,0 +1,342 @@
+package verify
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/michal-franc/cir/internal/app/cir/analyser"
+	"github.com/michal-franc/cir/internal/app/cir/scanner"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// Expectation - what the assertion requires from every pair of source and destination
+type Expectation string
+
+const (
+	// MustReach - every source has to reach every destination
+	MustReach Expectation = "reachable"
+	// MustNotReach - no source can reach any destination
+	MustNotReach Expectation = "unreachable"
+)
+
+// Assertion - single network intent eg. api tier must reach db on 5432
+type Assertion struct {
+	Name     string      `yaml:"name"`
+	From     string      `yaml:"from"`
+	To       string      `yaml:"to"`
+	Expect   Expectation `yaml:"expect"`
+	Protocol string      `yaml:"protocol"`
+	// for lb destination port selects the listener, all listeners are checked without it
+	Port int32 `yaml:"port"`
+	// echo request (ping) if not set, the same as the default of --icmp-type
+	IcmpType *int32 `yaml:"icmp_type"`
+	IcmpCode int32  `yaml:"icmp_code"`
+}
+
+// defaultIcmpType - echo request (ping)
+const defaultIcmpType = 8
+
+// Expectations - content of the expectations file
+type Expectations struct {
+	Assertions []Assertion `yaml:"assertions"`
+}
+
+// Load - reads expectations from yaml file, assertions are validated and defaults are filled in
+func Load(path string) (*Expectations, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading expectations '%s' - %s", path, err)
+	}
+	return Parse(content)
+}
+
+// Parse - parses expectations yaml, unknown fields are rejected as typo would silently change the assertion
+func Parse(content []byte) (*Expectations, error) {
+	e := &Expectations{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(e); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("expectations are not valid yaml - %s", err)
+	}
+
+	if len(e.Assertions) == 0 {
+		return nil, fmt.Errorf("expectations have no assertions")
+	}
+
+	for i := range e.Assertions {
+		if err := e.Assertions[i].validate(); err != nil {
+			return nil, fmt.Errorf("assertion %d - %s", i+1, err)
+		}
+	}
+	return e, nil
+}
+
+func (a *Assertion) validate() error {
+	if a.From == "" || a.To == "" {
+		return fmt.Errorf("from and to are required")
+	}
+	if a.Name == "" {
+		a.Name = fmt.Sprintf("%s must be %s from %s", a.To, a.Expect, a.From)
+	}
+	if a.Expect != MustReach && a.Expect != MustNotReach {
+		return fmt.Errorf("'%s' expects '%s' - use reachable or unreachable", a.Name, a.Expect)
+	}
+
+	for _, query := range []string{a.From, a.To} {
+		if _, err := scanner.ParseQuery(query); err != nil {
+			return fmt.Errorf("'%s' - %s", a.Name, err)
+		}
+	}
+
+	if a.Protocol == "" {
+		a.Protocol = string(analyser.ProtocolTCP)
+	}
+	protocol, err := analyser.ParseProtocol(a.Protocol)
+	if err != nil {
+		return fmt.Errorf("'%s' - %s", a.Name, err)
+	}
+
+	switch {
+	case protocol == analyser.ProtocolICMP:
+		if a.IcmpType == nil {
+			icmpType := int32(defaultIcmpType)
+			a.IcmpType = &icmpType
+		}
+		if *a.IcmpType < 0 || *a.IcmpType > 255 || a.IcmpCode < 0 || a.IcmpCode > 255 {
+			return fmt.Errorf("'%s' - icmp type and code value out of range 0-255", a.Name)
+		}
+	case a.Port == 0 && isLoadBalancerQuery(a.To):
+	case a.Port <= 0 || a.Port > 65535:
+		return fmt.Errorf("'%s' - port value out of range 1-65535", a.Name)
+	}
+	return nil
+}
+
+func isLoadBalancerQuery(query string) bool {
+	parsedQuery, err := scanner.ParseQuery(query)
+	if err != nil {
+		return false
+	}
+	term, ok := parsedQuery.ServiceTerm()
+	return ok && term.Kind == scanner.QueryLB
+}
+
+// Traffic - traffic checked by the assertion, expects validated assertion
+func (a *Assertion) Traffic() analyser.Traffic {
+	protocol, _ := analyser.ParseProtocol(a.Protocol)
+	if protocol == analyser.ProtocolICMP {
+		return analyser.NewIcmpTraffic(*a.IcmpType, a.IcmpCode)
+	}
+	return analyser.NewPortTraffic(protocol, a.Port)
+}
+
+// Status - outcome of the assertion
+type Status string
+
+const (
+	// StatusHolds - network matches the assertion
+	StatusHolds Status = "holds"
+	// StatusViolated - at least one pair contradicts the assertion
+	StatusViolated Status = "violated"
+	// StatusUnknown - no pair contradicts the assertion but some couldnt be evaluated
+	StatusUnknown Status = "unknown"
+	// StatusError - resources couldnt be scanned or analysed
+	StatusError Status = "error"
+)
+
+// Result - outcome of the assertion with the pairs which decided it
+type Result struct {
+	Assertion Assertion
+	Status    Status
+	Pairs     int
+	// pairs contradicting the assertion, or the ones which couldnt be evaluated if the status is unknown
+	Mismatched int
+	// analysis of the mismatched pairs, one per equivalence group
+	Evidence []analyser.Analysis
+	Err      error
+	Duration time.Duration
+}
+
+// Message - one line explanation of the status
+func (r *Result) Message() string {
+	switch r.Status {
+	case StatusHolds:
+		return fmt.Sprintf("all %d pairs are %s", r.Pairs, r.Assertion.Expect)
+	case StatusError:
+		return r.Err.Error()
+	case StatusUnknown:
+		if r.Pairs == 0 {
+			return "no pairs of source and destination were found"
+		}
+		return fmt.Sprintf("expected %s but %d of %d pairs couldnt be evaluated", r.Assertion.Expect, r.Mismatched, r.Pairs)
+	}
+	if r.Assertion.Expect == MustReach {
+		return fmt.Sprintf("expected reachable but %d of %d pairs are unreachable", r.Mismatched, r.Pairs)
+	}
+	return fmt.Sprintf("expected unreachable but %d of %d pairs are reachable", r.Mismatched, r.Pairs)
+}
+
+// path - verdict of the source reaching the destination with the analysis deciding it
+type path struct {
+	Verdict  analyser.Verdict
+	Evidence []analyser.Analysis
+}
+
+// Evaluate - compares the analysis of the assertion with its expectation
+// unknown pair is evidence only if no pair contradicts the assertion
+func Evaluate(assertion Assertion, listOfAnalysis []analyser.Analysis) Result {
+	paths := []path{}
+	if len(listOfAnalysis) > 0 && listOfAnalysis[0].Leg != "" {
+		paths = clientPaths(listOfAnalysis)
+	} else {
+		for _, a := range listOfAnalysis {
+			paths = append(paths, path{a.Verdict(), []analyser.Analysis{a}})
+		}
+	}
+
+	result := Result{Assertion: assertion, Status: StatusHolds, Pairs: len(paths), Evidence: []analyser.Analysis{}}
+	if len(paths) == 0 {
+		result.Status = StatusUnknown
+		return result
+	}
+
+	contradicting := analyser.VerdictUnreachable
+	if assertion.Expect == MustNotReach {
+		contradicting = analyser.VerdictReachable
+	}
+
+	violations := []path{}
+	unknowns := []path{}
+	for _, p := range paths {
+		switch p.Verdict {
+		case contradicting:
+			violations = append(violations, p)
+		case analyser.VerdictUnknown:
+			unknowns = append(unknowns, p)
+		}
+	}
+
+	switch {
+	case len(violations) > 0:
+		result.Status = StatusViolated
+		result.Mismatched = len(violations)
+		result.Evidence = pathsEvidence(violations)
+	case len(unknowns) > 0:
+		result.Status = StatusUnknown
+		result.Mismatched = len(unknowns)
+		result.Evidence = pathsEvidence(unknowns)
+	}
+	return result
+}
+
+func pathsEvidence(paths []path) []analyser.Analysis {
+	evidence := []analyser.Analysis{}
+	for _, p := range paths {
+		evidence = append(evidence, p.Evidence...)
+	}
+	return analyser.GroupRepresentatives(evidence)
+}
+
+// verdictRank - order of the verdicts from the worst, path is as good as its worst leg
+var verdictRank = map[analyser.Verdict]int{analyser.VerdictUnreachable: 0, analyser.VerdictUnknown: 1, analyser.VerdictReachable: 2}
+
+// bestOf - leg is reachable if any of its pairs is eg. client reaching any load balancer node
+// evidence is the reachable pair or all the pairs which werent reachable
+func bestOf(listOfAnalysis []analyser.Analysis) path {
+	best := path{analyser.VerdictUnreachable, []analyser.Analysis{}}
+	for _, a := range listOfAnalysis {
+		verdict := a.Verdict()
+		if verdict == analyser.VerdictReachable {
+			return path{verdict, []analyser.Analysis{a}}
+		}
+		if verdictRank[verdict] > verdictRank[best.Verdict] {
+			best = path{verdict, []analyser.Analysis{}}
+		}
+		if verdict == best.Verdict {
+			best.Evidence = append(best.Evidence, a)
+		}
+	}
+	return best
+}
+
+// clientPaths - client reaches the service behind load balancer if it reaches the listener and the traffic reaches a target
+// forwarded by the node or from the client itself when its ip is preserved, health checks dont carry client traffic
+func clientPaths(listOfAnalysis []analyser.Analysis) []path {
+	clients := []string{}
+	front := map[string][]analyser.Analysis{}
+	preserved := map[string][]analyser.Analysis{}
+	forwarded := []analyser.Analysis{}
+	for _, a := range listOfAnalysis {
+		client := fmt.Sprintf("%s|%s", a.SourceInterfaceID, a.SourceIP)
+		switch a.Leg {
+		case analyser.LegClientToLoadBalancer:
+			if _, ok := front[client]; !ok {
+				clients = append(clients, client)
+			}
+			front[client] = append(front[client], a)
+		case analyser.LegClientToTarget:
+			preserved[client] = append(preserved[client], a)
+		case analyser.LegLoadBalancerToTarget:
+			forwarded = append(forwarded, a)
+		}
+	}
+
+	paths := []path{}
+	for _, client := range clients {
+		toListener := bestOf(front[client])
+		toTarget := bestOf(append(append([]analyser.Analysis{}, forwarded...), preserved[client]...))
+
+		switch {
+		case toListener.Verdict == analyser.VerdictReachable && toTarget.Verdict == analyser.VerdictReachable:
+			paths = append(paths, path{analyser.VerdictReachable, append(toListener.Evidence, toTarget.Evidence...)})
+		case verdictRank[toListener.Verdict] < verdictRank[toTarget.Verdict]:
+			paths = append(paths, toListener)
+		case verdictRank[toTarget.Verdict] < verdictRank[toListener.Verdict]:
+			paths = append(paths, toTarget)
+		default:
+			paths = append(paths, path{toListener.Verdict, append(toListener.Evidence, toTarget.Evidence...)})
+		}
+	}
+	return paths
+}
+
+// Check - scans the resources of the assertion and evaluates it, load balancer destination is analysed in legs
+func Check(clients scanner.AwsClients, assertion Assertion) Result {
+	started := time.Now()
+	result := check(clients, assertion)
+	result.Duration = time.Since(started)
+	return result
+}
+
+func check(clients scanner.AwsClients, assertion Assertion) Result {
+	data, err := scanner.ScanAws(clients, assertion.From, assertion.To)
+	if err != nil {
+		return Result{Assertion: assertion, Status: StatusError, Err: fmt.Errorf("error when scanning AWS resources - %s", err)}
+	}
+
+	var listOfAnalysis []analyser.Analysis
+	if data.LoadBalancer != nil {
+		port := assertion.Port
+		if port == 0 {
+			port = -1
+		}
+		listOfAnalysis, err = analyser.RunLoadBalancerAnalysis(*data, clients.EC2, port)
+	} else {
+		listOfAnalysis, err = analyser.RunAnalysis(*data, clients.EC2, assertion.Traffic())
+	}
+	if err != nil {
+		return Result{Assertion: assertion, Status: StatusError, Err: fmt.Errorf("error when analysing data - %s", err)}
+	}
+	return Evaluate(assertion, listOfAnalysis)
+}
+
+// Run - checks every assertion in the order of the file
+func Run(clients scanner.AwsClients, expectations Expectations) []Result {
+	results := []Result{}
+	for _, assertion := range expectations.Assertions {
+		results = append(results, Check(clients, assertion))
+	}
+	return results
+}
@@ -0,0 +1,141 @@
+package verify
+
+import (
+	"github.com/michal-franc/cir/internal/app/cir/analyser"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func analysisWithVerdict(status analyser.CheckStatus, group int) analyser.Analysis {
+	c := &analyser.Check{Status: status, Reason: "security group rule"}
+	return analyser.Analysis{
+		CanEscapeSource: c, CanEnterDestination: c, SourceSubnetHasRoute: c, DestinationSubnetHasRoute: c,
+		ConnectionBetweenVPCsIsValid: c, ConnectionBetweenVPCsIsActive: c,
+		TransitGatewayRouteToDestination: c, TransitGatewayRouteToSource: c,
+		SourceNaclAllowsOutbound: c, DestinationNaclAllowsInbound: c, DestinationNaclAllowsReturn: c, SourceNaclAllowsReturn: c,
+		NatGatewayIsAvailable: c, NatSubnetNaclAllowsTraffic: c, InternetGatewayIsReachable: c,
+		Group: group, GroupSize: 1,
+	}
+}
+
+func TestParseFillsDefaultsAndRejectsInvalidAssertions(t *testing.T) {
+	expectations, err := Parse([]byte(`
+assertions:
+  - name: api reaches db
+    from: tag:Tier=api
+    to: tag:Tier=db
+    port: 5432
+    expect: reachable
+  - from: tag:Tier=batch
+    to: tag:Tier=db
+    protocol: icmp
+    expect: unreachable
+`))
+
+	assert.Nil(t, err)
+	assert.Len(t, expectations.Assertions, 2)
+	assert.Equal(t, analyser.NewPortTraffic(analyser.ProtocolTCP, 5432), expectations.Assertions[0].Traffic())
+	assert.Equal(t, "tag:Tier=db must be unreachable from tag:Tier=batch", expectations.Assertions[1].Name)
+	assert.Equal(t, analyser.NewIcmpTraffic(8, 0), expectations.Assertions[1].Traffic())
+
+	invalid := map[string]string{
+		"assertions: []": "expectations have no assertions",
+		"assertions:\n  - {from: name:a, to: name:b, port: 80, expect: maybe}": "assertion 1 - 'name:b must be maybe from name:a' expects 'maybe' - use reachable or unreachable",
+		"assertions:\n  - {from: name:a, to: name:b, expect: reachable}":       "assertion 1 - 'name:b must be reachable from name:a' - port value out of range 1-65535",
+		"assertions:\n  - {from: name:a, expect: reachable}":                   "assertion 1 - from and to are required",
+	}
+	for content, message := range invalid {
+		_, err := Parse([]byte(content))
+		assert.EqualError(t, err, message)
+	}
+
+	_, err = Parse([]byte("assertions:\n  - {from: name:a, to: name:b, prot: 80, expect: reachable}"))
+	assert.Contains(t, err.Error(), "field prot not found")
+
+	_, err = Parse([]byte("assertions:\n  - {from: name:a, to: lb:web, expect: reachable}"))
+	assert.Nil(t, err)
+
+	echoReply, err := Parse([]byte("assertions:\n  - {from: name:a, to: name:b, protocol: icmp, icmp_type: 0, expect: reachable}"))
+	assert.Nil(t, err)
+	assert.Equal(t, analyser.NewIcmpTraffic(0, 0), echoReply.Assertions[0].Traffic())
+}
+
+func TestEvaluateMustReach(t *testing.T) {
+	assertion := Assertion{Name: "api reaches db", Expect: MustReach}
+
+	holds := Evaluate(assertion, []analyser.Analysis{analysisWithVerdict(analyser.CheckPass, 0)})
+	assert.Equal(t, StatusHolds, holds.Status)
+	assert.Equal(t, "all 1 pairs are reachable", holds.Message())
+
+	violated := Evaluate(assertion, []analyser.Analysis{
+		analysisWithVerdict(analyser.CheckPass, 0),
+		analysisWithVerdict(analyser.CheckFail, 1),
+		analysisWithVerdict(analyser.CheckUnknown, 2),
+	})
+	assert.Equal(t, StatusViolated, violated.Status)
+	assert.Len(t, violated.Evidence, 1)
+	assert.Equal(t, "expected reachable but 1 of 3 pairs are unreachable", violated.Message())
+
+	unknown := Evaluate(assertion, []analyser.Analysis{analysisWithVerdict(analyser.CheckUnknown, 0)})
+	assert.Equal(t, StatusUnknown, unknown.Status)
+
+	assert.Equal(t, StatusUnknown, Evaluate(assertion, []analyser.Analysis{}).Status)
+}
+
+func TestEvaluateMustNotReach(t *testing.T) {
+	assertion := Assertion{Name: "batch cant reach db", Expect: MustNotReach}
+
+	holds := Evaluate(assertion, []analyser.Analysis{analysisWithVerdict(analyser.CheckFail, 0)})
+	assert.Equal(t, StatusHolds, holds.Status)
+
+	reachable := analysisWithVerdict(analyser.CheckPass, 1)
+	reachable.GroupSize = 2
+	violated := Evaluate(assertion, []analyser.Analysis{analysisWithVerdict(analyser.CheckFail, 0), reachable, reachable})
+	assert.Equal(t, StatusViolated, violated.Status)
+	assert.Equal(t, "expected unreachable but 2 of 3 pairs are reachable", violated.Message())
+}
+
+func legAnalysis(leg analyser.Leg, client string, status analyser.CheckStatus, group int) analyser.Analysis {
+	a := analysisWithVerdict(status, group)
+	a.Leg = leg
+	a.SourceInterfaceID = client
+	return a
+}
+
+func TestEvaluateLoadBalancerFollowsClientPath(t *testing.T) {
+	mustNotReach := Assertion{Name: "batch cant reach api lb", Expect: MustNotReach}
+	mustReach := Assertion{Name: "web reaches api lb", Expect: MustReach}
+	// client is blocked at the listener while the nodes reach the targets and check their health
+	blocked := []analyser.Analysis{
+		legAnalysis(analyser.LegClientToLoadBalancer, "eni-client", analyser.CheckFail, 0),
+		legAnalysis(analyser.LegLoadBalancerToTarget, "eni-node", analyser.CheckPass, 1),
+		legAnalysis(analyser.LegHealthCheck, "eni-node", analyser.CheckPass, 2),
+	}
+
+	holds := Evaluate(mustNotReach, blocked)
+	assert.Equal(t, StatusHolds, holds.Status)
+	assert.Equal(t, 1, holds.Pairs)
+
+	violated := Evaluate(mustReach, blocked)
+	assert.Equal(t, StatusViolated, violated.Status)
+	assert.Equal(t, []analyser.Analysis{blocked[0]}, violated.Evidence)
+
+	// targets unhealthy for the node but reached by client with preserved ip
+	preserved := []analyser.Analysis{
+		legAnalysis(analyser.LegClientToLoadBalancer, "eni-client", analyser.CheckPass, 0),
+		legAnalysis(analyser.LegClientToTarget, "eni-client", analyser.CheckPass, 1),
+		legAnalysis(analyser.LegHealthCheck, "eni-node", analyser.CheckFail, 2),
+	}
+	reached := Evaluate(mustNotReach, preserved)
+	assert.Equal(t, StatusViolated, reached.Status)
+	assert.Equal(t, "expected unreachable but 1 of 1 pairs are reachable", reached.Message())
+	assert.Len(t, reached.Evidence, 2)
+
+	// preserved client ip of other client doesnt open the path
+	otherClient := []analyser.Analysis{
+		legAnalysis(analyser.LegClientToLoadBalancer, "eni-client", analyser.CheckPass, 0),
+		legAnalysis(analyser.LegClientToTarget, "eni-other", analyser.CheckPass, 1),
+		legAnalysis(analyser.LegClientToTarget, "eni-client", analyser.CheckFail, 2),
+	}
+	assert.Equal(t, StatusHolds, Evaluate(mustNotReach, otherClient).Status)
+}